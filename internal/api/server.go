@@ -0,0 +1,433 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/elasticsearch"
+	"github.com/company/log-trimmer/internal/logger"
+	"github.com/company/log-trimmer/internal/notify"
+)
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// DefaultPlanTTL is how long a plan can be applied after it was created
+const DefaultPlanTTL = 15 * time.Minute
+
+// DefaultRunTTL is how long a finished run can still be looked up
+const DefaultRunTTL = 24 * time.Hour
+
+// maxPlans and maxRuns bound how many plans and finished runs are kept in
+// memory; the oldest are dropped first
+const (
+	maxPlans = 100
+	maxRuns  = 100
+)
+
+// Run statuses
+const (
+	RunRunning   = "running"
+	RunCompleted = "completed"
+)
+
+// Run tracks the application of a plan
+type Run struct {
+	ID        string                        `json:"id"`
+	PlanID    string                        `json:"plan_id"`
+	Policy    string                        `json:"policy"`
+	Status    string                        `json:"status"`
+	StartedAt time.Time                     `json:"started_at"`
+	Report    *elasticsearch.DeletionReport `json:"report,omitempty"`
+	Error     string                        `json:"error,omitempty"`
+
+	finishedAt time.Time
+}
+
+// storedPlan keeps a plan together with the policy it was built for
+type storedPlan struct {
+	plan *elasticsearch.Plan
+	cfg  *config.Config
+}
+
+// Server exposes plan, apply and status operations over a JSON HTTP API
+type Server struct {
+	Client   *elasticsearch.Client
+	Logger   *logger.Logger
+	Policies func() []*config.Config // Current policies, re-read on every request
+	Lock     sync.Locker             // Optional, held while a plan is applied
+	PlanTTL  time.Duration
+	RunTTL   time.Duration
+
+	token    string
+	username string
+	password string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	plans map[string]*storedPlan
+	runs  map[string]*Run
+}
+
+// NewServer creates an API server using the authentication settings of cfg
+func NewServer(cfg *config.Config, client *elasticsearch.Client, log *logger.Logger, policies func() []*config.Config) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		Client:   client,
+		Logger:   log,
+		Policies: policies,
+		PlanTTL:  DefaultPlanTTL,
+		RunTTL:   DefaultRunTTL,
+		token:    cfg.APIToken,
+		username: cfg.APIUsername,
+		password: cfg.APIPassword,
+		ctx:      ctx,
+		cancel:   cancel,
+		plans:    make(map[string]*storedPlan),
+		runs:     make(map[string]*Run),
+	}
+}
+
+// Handler returns the HTTP handler for the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	mux.Handle("/plan", s.authenticate(http.HandlerFunc(s.handlePlan)))
+	mux.Handle("/apply", s.authenticate(http.HandlerFunc(s.handleApply)))
+	mux.Handle("/runs/", s.authenticate(http.HandlerFunc(s.handleRun)))
+	return mux
+}
+
+// Shutdown cancels running applies after their in-flight deletion and waits
+// for them to finish or for ctx to expire
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// authenticate requires a valid bearer token or basic auth credentials
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.authorized(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="log-trimmer", Basic realm="log-trimmer"`)
+		writeError(w, http.StatusUnauthorized, "authentication required")
+	})
+}
+
+// authorized checks the request credentials in constant time
+func (s *Server) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if s.token != "" && strings.HasPrefix(auth, "Bearer ") {
+		given := strings.TrimPrefix(auth, "Bearer ")
+		return subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) == 1
+	}
+	if s.username != "" && s.password != "" {
+		if user, pass, ok := r.BasicAuth(); ok {
+			userOK := subtle.ConstantTimeCompare([]byte(user), []byte(s.username)) == 1
+			passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(s.password)) == 1
+			return userOK && passOK
+		}
+	}
+	return false
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	health, err := s.Client.GetClusterHealth()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("cluster unreachable: %v", err))
+		return
+	}
+	body := map[string]string{
+		"status":         "ready",
+		"cluster_name":   health.ClusterName,
+		"cluster_status": health.Status,
+	}
+	// The elected master is informational; a clone keeps version detection
+	// off the shared client
+	if master, err := s.Client.WithConfig(s.Client.Config).ElectedMaster(); err == nil {
+		body["master_node"] = master
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
+
+// handlePlan runs the analysis for a policy and stores the plan for apply
+func (s *Server) handlePlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	cfg, err := s.findPolicy(r.URL.Query().Get("policy"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	plan, err := s.Client.WithConfig(cfg).BuildPlan()
+	if err != nil {
+		s.Logger.Error("api", "plan", "Failed to build plan", err)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.expirePlans()
+	s.plans[plan.ID] = &storedPlan{plan: plan, cfg: cfg}
+	s.mu.Unlock()
+
+	s.Logger.Info("api", "plan", "Plan created", map[string]interface{}{
+		"plan_id": plan.ID,
+		"policy":  plan.Policy,
+		"count":   len(plan.ToDelete),
+	})
+
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// applyRequest is the body of POST /apply
+type applyRequest struct {
+	PlanID string `json:"plan_id"`
+}
+
+// handleApply starts deleting the indexes of a previously created plan
+func (s *Server) handleApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req applyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PlanID == "" {
+		writeError(w, http.StatusBadRequest, "request body must be JSON with a plan_id")
+		return
+	}
+
+	// Plans are single use so the same deletion cannot be started twice.
+	// Plans of dry run policies are kept so they can still be inspected.
+	s.mu.Lock()
+	s.expirePlans()
+	stored, ok := s.plans[req.PlanID]
+	if ok && stored.cfg.DeleteIndexes {
+		delete(s.plans, req.PlanID)
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("plan %s not found or expired", req.PlanID))
+		return
+	}
+	if !stored.cfg.DeleteIndexes {
+		writeError(w, http.StatusConflict, fmt.Sprintf("policy %s is a dry run; set delete_indexes to apply its plans", stored.plan.Policy))
+		return
+	}
+
+	run := &Run{
+		ID:        newID(),
+		PlanID:    stored.plan.ID,
+		Policy:    stored.plan.Policy,
+		Status:    RunRunning,
+		StartedAt: time.Now(),
+	}
+	s.mu.Lock()
+	s.expireRuns()
+	s.runs[run.ID] = run
+	s.mu.Unlock()
+
+	s.Logger.Info("api", "apply", "Applying plan", map[string]interface{}{
+		"plan_id": run.PlanID,
+		"run_id":  run.ID,
+		"count":   len(stored.plan.ToDelete),
+	})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if s.Lock != nil {
+			s.Lock.Lock()
+			defer s.Lock.Unlock()
+		}
+
+		client := s.Client.WithConfig(stored.cfg)
+		var report *elasticsearch.DeletionReport
+		err := client.WithRunLock(s.ctx, func(ctx context.Context) error {
+			report = client.ApplyPlan(ctx, stored.plan)
+			return nil
+		})
+
+		s.mu.Lock()
+		run.Report = report
+		run.Status = RunCompleted
+		run.finishedAt = time.Now()
+		if err != nil {
+			run.Error = err.Error()
+		}
+		s.mu.Unlock()
+
+		var held *elasticsearch.LockHeldError
+		if !errors.As(err, &held) {
+			record := client.NewAuditRecord(run.ID, run.StartedAt, stored.plan, report, err)
+			if err := client.WriteAuditRecord(record); err != nil {
+				s.Logger.Warn("api", "audit", "Failed to write audit record", map[string]interface{}{
+					"run_id": run.ID,
+					"error":  err.Error(),
+				})
+			}
+
+			notifiers, notifyErr := notify.NewSet(stored.cfg, s.Logger)
+			if notifyErr != nil {
+				s.Logger.Warn("api", "notify", "Failed to set up notifiers", map[string]interface{}{
+					"error": notifyErr.Error(),
+				})
+			}
+			notifiers.Notify(context.Background(), stored.cfg, stored.plan, report, err)
+		}
+	}()
+
+	writeJSON(w, http.StatusAccepted, s.snapshot(run))
+}
+
+// handleRun returns the status and deletion report of a run
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/runs/")
+	s.mu.Lock()
+	s.expireRuns()
+	run, ok := s.runs[id]
+	s.mu.Unlock()
+	if !ok || id == "" {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("run %s not found", id))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.snapshot(run))
+}
+
+// snapshot copies a run under the lock so it can be encoded safely
+func (s *Server) snapshot(run *Run) Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *run
+}
+
+// findPolicy returns the named policy, or the only policy when name is empty
+func (s *Server) findPolicy(name string) (*config.Config, error) {
+	policies := s.Policies()
+	if name == "" {
+		if len(policies) == 1 {
+			return policies[0], nil
+		}
+		return nil, fmt.Errorf("policy parameter is required when %d policies are configured", len(policies))
+	}
+	for _, policy := range policies {
+		if policy.PolicyName == name {
+			return policy, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown policy '%s'", name)
+}
+
+// expirePlans drops plans older than the TTL and then the oldest plans
+// until there is room for a new one; the caller must hold the lock
+func (s *Server) expirePlans() {
+	for id, stored := range s.plans {
+		if time.Since(stored.plan.CreatedAt) > s.PlanTTL {
+			delete(s.plans, id)
+		}
+	}
+	for len(s.plans) >= maxPlans {
+		oldest := ""
+		for id, stored := range s.plans {
+			if oldest == "" || stored.plan.CreatedAt.Before(s.plans[oldest].plan.CreatedAt) {
+				oldest = id
+			}
+		}
+		delete(s.plans, oldest)
+	}
+}
+
+// expireRuns drops finished runs older than the run TTL and then the
+// oldest finished runs until there is room for a new one. Running runs are
+// always kept. The caller must hold the lock.
+func (s *Server) expireRuns() {
+	for id, run := range s.runs {
+		if run.Status == RunCompleted && time.Since(run.finishedAt) > s.RunTTL {
+			delete(s.runs, id)
+		}
+	}
+	for len(s.runs) >= maxRuns {
+		oldest := ""
+		for id, run := range s.runs {
+			if run.Status == RunCompleted && (oldest == "" || run.finishedAt.Before(s.runs[oldest].finishedAt)) {
+				oldest = id
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		delete(s.runs, oldest)
+	}
+}
+
+// newID returns a random identifier for a run
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// writeJSON encodes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
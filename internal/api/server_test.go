@@ -0,0 +1,232 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/elasticsearch"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+// newElasticsearch returns a stand-in cluster with one old and one new index
+func newElasticsearch(t *testing.T, deletes *int32) *httptest.Server {
+	old := time.Now().Add(-30*24*time.Hour).UnixNano() / int64(time.Millisecond)
+	fresh := time.Now().UnixNano() / int64(time.Millisecond)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "DELETE":
+			atomic.AddInt32(deletes, 1)
+			w.Write([]byte(`{"acknowledged":true}`))
+		case r.URL.Path == "/_cluster/health":
+			w.Write([]byte(`{"cluster_name":"test","status":"green"}`))
+		case r.URL.Path == "/":
+			w.Write([]byte(`{"cluster_name":"test","version":{"number":"8.11.0"}}`))
+		case r.URL.Path == "/_data_stream/*":
+			w.Write([]byte(`{"data_streams":[]}`))
+		case strings.HasPrefix(r.URL.Path, "/_cat/indices/"):
+			w.Write([]byte(`[{"index":"logs-old","store.size":"100"},{"index":"logs-new","store.size":"100"}]`))
+		case r.URL.Path == "/logs-old/_settings":
+			fmt.Fprintf(w, `{"logs-old":{"settings":{"index":{"creation_date":"%d"}}}}`, old)
+		case r.URL.Path == "/logs-new/_settings":
+			fmt.Fprintf(w, `{"logs-new":{"settings":{"index":{"creation_date":"%d"}}}}`, fresh)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func newTestAPI(t *testing.T, esURL string, deleteIndexes bool) *httptest.Server {
+	cfg := config.DefaultConfig()
+	cfg.ESHost = esURL
+	cfg.IndexPattern = "logs-*"
+	cfg.MaxAge = "7d"
+	cfg.DeleteIndexes = deleteIndexes
+	cfg.APIToken = "secret"
+
+	policies, err := cfg.ResolvePolicies()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	log, _ := logger.New(logger.DefaultConfig())
+	client := elasticsearch.NewClient(cfg, log)
+	server := NewServer(cfg, client, log, func() []*config.Config { return policies })
+	return httptest.NewServer(server.Handler())
+}
+
+func doRequest(t *testing.T, method, url, token string, body []byte) *http.Response {
+	req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp
+}
+
+func TestPlanApplyRun(t *testing.T) {
+	var deletes int32
+	es := newElasticsearch(t, &deletes)
+	defer es.Close()
+	api := newTestAPI(t, es.URL, true)
+	defer api.Close()
+
+	// Plan
+	resp := doRequest(t, "GET", api.URL+"/plan", "secret", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 from /plan, got %d", resp.StatusCode)
+	}
+	var plan elasticsearch.Plan
+	json.NewDecoder(resp.Body).Decode(&plan)
+	resp.Body.Close()
+	if len(plan.ToDelete) != 1 || plan.ToDelete[0].Name != "logs-old" {
+		t.Fatalf("Expected logs-old in the plan, got %v", plan.ToDelete)
+	}
+
+	// Apply
+	body, _ := json.Marshal(map[string]string{"plan_id": plan.ID})
+	resp = doRequest(t, "POST", api.URL+"/apply", "secret", body)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202 from /apply, got %d", resp.StatusCode)
+	}
+	var run Run
+	json.NewDecoder(resp.Body).Decode(&run)
+	resp.Body.Close()
+
+	// Plans are single use
+	resp = doRequest(t, "POST", api.URL+"/apply", "secret", body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 when re-applying a plan, got %d", resp.StatusCode)
+	}
+
+	// Poll the run until it completes
+	deadline := time.Now().Add(5 * time.Second)
+	for run.Status != RunCompleted && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		resp = doRequest(t, "GET", api.URL+"/runs/"+run.ID, "secret", nil)
+		json.NewDecoder(resp.Body).Decode(&run)
+		resp.Body.Close()
+	}
+	if run.Status != RunCompleted || run.Report == nil || run.Report.Deleted != 1 {
+		t.Fatalf("Expected completed run with one deletion, got %+v", run)
+	}
+	if atomic.LoadInt32(&deletes) != 1 {
+		t.Errorf("Expected one DELETE request, got %d", deletes)
+	}
+}
+
+func TestApplyRefusesDryRunPolicy(t *testing.T) {
+	var deletes int32
+	es := newElasticsearch(t, &deletes)
+	defer es.Close()
+	api := newTestAPI(t, es.URL, false)
+	defer api.Close()
+
+	resp := doRequest(t, "GET", api.URL+"/plan", "secret", nil)
+	var plan elasticsearch.Plan
+	json.NewDecoder(resp.Body).Decode(&plan)
+	resp.Body.Close()
+
+	body, _ := json.Marshal(map[string]string{"plan_id": plan.ID})
+	resp = doRequest(t, "POST", api.URL+"/apply", "secret", body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected 409 from /apply on a dry run policy, got %d", resp.StatusCode)
+	}
+	if atomic.LoadInt32(&deletes) != 0 {
+		t.Errorf("Expected no DELETE requests, got %d", deletes)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	var deletes int32
+	es := newElasticsearch(t, &deletes)
+	defer es.Close()
+	api := newTestAPI(t, es.URL, true)
+	defer api.Close()
+
+	for _, path := range []string{"/plan", "/runs/abc"} {
+		resp := doRequest(t, "GET", api.URL+path, "", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %s without credentials, got %d", path, resp.StatusCode)
+		}
+		resp = doRequest(t, "GET", api.URL+path, "wrong", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %s with a bad token, got %d", path, resp.StatusCode)
+		}
+	}
+
+	// Probes and the OpenAPI document are public
+	for _, path := range []string{"/healthz", "/readyz", "/openapi.json"} {
+		resp := doRequest(t, "GET", api.URL+path, "", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200 for %s, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	var doc struct {
+		Paths map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPIDocument, &doc); err != nil {
+		t.Fatalf("OpenAPI document is not valid JSON: %v", err)
+	}
+	for _, path := range []string{"/plan", "/apply", "/runs/{id}", "/healthz", "/readyz"} {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("Expected OpenAPI document to describe %s", path)
+		}
+	}
+}
+
+func TestPlansAndRunsAreEvicted(t *testing.T) {
+	log, _ := logger.New(logger.DefaultConfig())
+	cfg := config.DefaultConfig()
+	server := NewServer(cfg, elasticsearch.NewClient(cfg, log), log, func() []*config.Config { return nil })
+
+	now := time.Now()
+	for i := 0; i < maxPlans; i++ {
+		id := fmt.Sprintf("plan-%d", i)
+		server.plans[id] = &storedPlan{plan: &elasticsearch.Plan{ID: id, CreatedAt: now.Add(time.Duration(i) * time.Second)}, cfg: cfg}
+	}
+	server.plans["stale"] = &storedPlan{plan: &elasticsearch.Plan{ID: "stale", CreatedAt: now.Add(-time.Hour)}, cfg: cfg}
+	server.expirePlans()
+	if _, ok := server.plans["stale"]; ok {
+		t.Error("Expected the expired plan to be dropped")
+	}
+	if _, ok := server.plans["plan-0"]; ok || len(server.plans) != maxPlans-1 {
+		t.Errorf("Expected the oldest plan to make room for a new one, got %d plans", len(server.plans))
+	}
+
+	server.runs["running"] = &Run{ID: "running", Status: RunRunning}
+	server.runs["old"] = &Run{ID: "old", Status: RunCompleted, finishedAt: now.Add(-48 * time.Hour)}
+	for i := 0; i < maxRuns; i++ {
+		id := fmt.Sprintf("run-%d", i)
+		server.runs[id] = &Run{ID: id, Status: RunCompleted, finishedAt: now.Add(time.Duration(i) * time.Second)}
+	}
+	server.expireRuns()
+	if _, ok := server.runs["old"]; ok {
+		t.Error("Expected the run finished two days ago to be dropped")
+	}
+	if _, ok := server.runs["running"]; !ok {
+		t.Error("Expected the running run to be kept")
+	}
+	if _, ok := server.runs["run-1"]; ok || len(server.runs) != maxRuns-1 {
+		t.Errorf("Expected the oldest finished runs to make room for a new one, got %d runs", len(server.runs))
+	}
+}
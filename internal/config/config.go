@@ -1,20 +1,101 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/company/log-trimmer/internal/logger"
+	"github.com/company/log-trimmer/internal/scheduler"
+	"github.com/company/log-trimmer/pkg/utils"
 )
 
 const (
 	Version = "1.0.0"
 )
 
+// Managed index handling modes for indexes that already have an ILM or ISM
+// policy attached
+const (
+	ManagedSkip     = "skip"
+	ManagedWarn     = "warn"
+	ManagedTakeOver = "take_over"
+)
+
+// Handling modes for closed and red indexes, whose _cat sizes are missing
+// or partial
+const (
+	UnhealthyDelete = "delete" // Treat like any other index
+	UnhealthySkip   = "skip"   // Leave out of the analysis entirely
+	UnhealthyReport = "report" // Count towards totals but never delete
+)
+
+// Actions taken when the cluster health gate fails
+const (
+	HealthGateAbort = "abort"
+	HealthGatePause = "pause"
+)
+
+// Size bases used when measuring index sizes against MaxSize
+const (
+	SizeBasisTotal   = "total"
+	SizeBasisPrimary = "primary"
+)
+
+// Strategies for bringing an index pattern back under MaxSize
+const (
+	SizeStrategyDelete         = "delete"          // Delete the oldest indexes
+	SizeStrategyReduceReplicas = "reduce_replicas" // Drop replicas of the oldest indexes first, then delete
+)
+
+// How soft delete marks an index before it is deleted
+const (
+	SoftDeleteBlock = "block" // Set index.blocks.write
+	SoftDeleteClose = "close" // Close the index
+)
+
+// Output formats for reports printed by commands
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputCSV   = "csv"
+)
+
+// Webhook payload presets
+const (
+	NotifyPresetGeneric = "generic"
+	NotifyPresetSlack   = "slack"
+	NotifyPresetTeams   = "teams"
+)
+
+// Run events a notifier can subscribe to
+const (
+	NotifyOnPlan    = "on_plan"
+	NotifyOnDelete  = "on_delete"
+	NotifyOnFailure = "on_failure"
+	NotifyOnNoop    = "on_noop"
+)
+
+// NotifyTemplateFuncs are available to notifier templates
+var NotifyTemplateFuncs = template.FuncMap{
+	"bytes": utils.FormatBytes,
+	"join":  strings.Join,
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
 // Config holds all application configuration
 type Config struct {
 	// Elasticsearch settings
@@ -28,30 +109,338 @@ type Config struct {
 	MaxAge         string        `json:"max_age" yaml:"max_age"`
 	IndexPattern   string        `json:"index_pattern" yaml:"index_pattern"`
 	DeleteIndexes  bool          `json:"delete_indexes" yaml:"delete_indexes"`
+	AssumeYes      bool          `json:"assume_yes" yaml:"assume_yes"` // Skip the typed confirmation on manual runs
 	MaxSizeBytes   int64         `json:"-" yaml:"-"`
 	MaxAgeDuration time.Duration `json:"-" yaml:"-"`
 
+	// EmptyIndexAge deletes indexes without documents once they are older
+	// than this grace age; MaxDocs limits the total document count
+	EmptyIndexAge         string        `json:"empty_index_age" yaml:"empty_index_age"`
+	EmptyIndexAgeDuration time.Duration `json:"-" yaml:"-"`
+	MaxDocs               int64         `json:"max_docs" yaml:"max_docs"`
+
+	// MaxShards limits the number of primary and replica shard copies held
+	// by matching indexes
+	MaxShards int `json:"max_shards" yaml:"max_shards"`
+
+	// SizeBasis selects whether MaxSize is measured against primary store
+	// size only or total store size including replicas
+	SizeBasis string `json:"size_basis" yaml:"size_basis"`
+
+	// SizeStrategy selects how the size rule reclaims space: "delete" or
+	// "reduce_replicas"
+	SizeStrategy string `json:"size_strategy" yaml:"size_strategy"`
+
+	// TargetDiskPercent trims until the fullest data node is projected to be
+	// under this disk usage percentage
+	TargetDiskPercent float64 `json:"target_disk_percent" yaml:"target_disk_percent"`
+
+	// ManagedIndexes controls what happens to indexes that have an ILM/ISM
+	// policy attached: "skip", "warn" or "take_over"
+	ManagedIndexes string `json:"managed_indexes" yaml:"managed_indexes"`
+
+	// ClosedIndexes and RedIndexes control what happens to closed and red
+	// indexes: "delete", "skip" or "report"
+	ClosedIndexes string `json:"closed_indexes" yaml:"closed_indexes"`
+	RedIndexes    string `json:"red_indexes" yaml:"red_indexes"`
+
+	// HealthGate holds the cluster preconditions checked before the run and
+	// between deletions
+	HealthGate HealthGateConfig `json:"health_gate" yaml:"health_gate"`
+
+	// Soft delete marks indexes on one run and deletes them on a later run
+	// once the grace period has passed
+	SoftDelete          bool          `json:"soft_delete" yaml:"soft_delete"`
+	SoftDeleteAction    string        `json:"soft_delete_action" yaml:"soft_delete_action"` // "block" or "close"
+	GracePeriod         string        `json:"grace_period" yaml:"grace_period"`
+	GracePeriodDuration time.Duration `json:"-" yaml:"-"`
+
+	// Maintenance windows; deletions only run while one is open
+	AllowedWindows []string           `json:"allowed_windows" yaml:"allowed_windows"` // e.g. "Mon-Fri 22:00-06:00" or "0 22 * * 1-5 8h"
+	WindowTimezone string             `json:"window_timezone" yaml:"window_timezone"` // IANA name, required with allowed_windows
+	Windows        *scheduler.Windows `json:"-" yaml:"-"`
+
+	// Deletion rate limits
+	MaxDeletesPerMinute    int           `json:"max_deletes_per_minute" yaml:"max_deletes_per_minute"`
+	MaxBytesPerMinute      string        `json:"max_bytes_per_minute" yaml:"max_bytes_per_minute"` // e.g. "100GB"
+	DeletePause            string        `json:"delete_pause" yaml:"delete_pause"`                 // Minimum pause between deletions
+	MaxBytesPerMinuteBytes int64         `json:"-" yaml:"-"`
+	DeletePauseDuration    time.Duration `json:"-" yaml:"-"`
+
+	// Run lock settings; an empty LockIndex disables locking
+	LockIndex       string        `json:"lock_index" yaml:"lock_index"`
+	LockName        string        `json:"lock_name" yaml:"lock_name"`
+	LockTTL         string        `json:"lock_ttl" yaml:"lock_ttl"`
+	LockTTLDuration time.Duration `json:"-" yaml:"-"`
+
+	// Audit settings
+	AuditIndex string `json:"audit_index" yaml:"audit_index"` // Index that receives one audit record per run
+
+	// Notification settings
+	Notifiers []NotifierConfig `json:"notifiers" yaml:"notifiers"`
+
+	// Daemon settings
+	Schedule       string         `json:"schedule" yaml:"schedule"` // Cron expression or "@every <duration>"
+	Jitter         string         `json:"jitter" yaml:"jitter"`     // Random delay added to each scheduled run
+	JitterDuration time.Duration  `json:"-" yaml:"-"`
+	Policies       []PolicyConfig `json:"policies" yaml:"policies"`
+	PolicyName     string         `json:"-" yaml:"-"` // Set on configs returned by ResolvePolicies
+
+	// Control API settings
+	APIAddr     string `json:"api_addr" yaml:"api_addr"`
+	APIToken    string `json:"api_token" yaml:"api_token"`
+	APIUsername string `json:"api_username" yaml:"api_username"`
+	APIPassword string `json:"api_password" yaml:"api_password"`
+
+	// Metrics settings
+	MetricsAddr     string `json:"metrics_addr" yaml:"metrics_addr"`         // Serve Prometheus metrics on this address
+	MetricsTextfile string `json:"metrics_textfile" yaml:"metrics_textfile"` // Write metrics for the node_exporter textfile collector
+
+	// AsOf evaluates age rules as if the run happened at this time, as
+	// RFC 3339 or YYYY-MM-DD
+	AsOf     string    `json:"as_of" yaml:"as_of"`
+	AsOfTime time.Time `json:"-" yaml:"-"`
+
+	// Simulation settings; an empty DailyGrowth uses the forecast ingest rate
+	SimulateDays     int    `json:"simulate_days" yaml:"simulate_days"`
+	DailyGrowth      string `json:"daily_growth" yaml:"daily_growth"` // e.g. "50GB"
+	DailyGrowthBytes int64  `json:"-" yaml:"-"`
+
+	// Capacity report settings
+	ReportGroupBy      string         `json:"report_group_by" yaml:"report_group_by"` // Regex whose first capture group names the family; empty groups by the prefix before the date
+	ReportSort         string         `json:"report_sort" yaml:"report_sort"`         // Column to sort by, "-" prefix for descending
+	ReportGroupPattern *regexp.Regexp `json:"-" yaml:"-"`
+
 	// Application settings
 	Verbose bool           `json:"verbose" yaml:"verbose"`
+	Output  string         `json:"output" yaml:"output"` // Report format: "table", "json" or "csv"
 	Logger  *logger.Config `json:"logger" yaml:"logger"`
 }
 
+// PolicyConfig overrides the top-level trimming settings for one index
+// pattern. Unset fields inherit the top-level values.
+type PolicyConfig struct {
+	Name          string `json:"name" yaml:"name"`
+	IndexPattern  string `json:"index_pattern" yaml:"index_pattern"`
+	MaxSize       string `json:"max_size" yaml:"max_size"`
+	MaxAge        string `json:"max_age" yaml:"max_age"`
+	Schedule      string `json:"schedule" yaml:"schedule"`
+	Jitter        string `json:"jitter" yaml:"jitter"`
+	ClosedIndexes string `json:"closed_indexes" yaml:"closed_indexes"`
+	RedIndexes    string `json:"red_indexes" yaml:"red_indexes"`
+}
+
+// NotifierConfig describes a webhook that receives run events
+type NotifierConfig struct {
+	Name            string            `json:"name" yaml:"name"`
+	URL             string            `json:"url" yaml:"url"`
+	Preset          string            `json:"preset" yaml:"preset"`                     // "generic", "slack" or "teams"
+	Template        string            `json:"template" yaml:"template"`                 // Go template for the body (generic) or message text (presets)
+	Events          []string          `json:"events" yaml:"events"`                     // Defaults to on_delete and on_failure
+	DeleteThreshold int               `json:"delete_threshold" yaml:"delete_threshold"` // on_delete only fires above this many deletions
+	Headers         map[string]string `json:"headers" yaml:"headers"`
+	Timeout         string            `json:"timeout" yaml:"timeout"`
+	Retries         int               `json:"retries" yaml:"retries"`
+	TimeoutDuration time.Duration     `json:"-" yaml:"-"`
+}
+
+// validate checks the notifier settings and fills in defaults
+func (n *NotifierConfig) validate() error {
+	if n.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+
+	switch n.Preset {
+	case "":
+		n.Preset = NotifyPresetGeneric
+	case NotifyPresetGeneric, NotifyPresetSlack, NotifyPresetTeams:
+	default:
+		return fmt.Errorf("invalid preset '%s': must be one of generic, slack, teams", n.Preset)
+	}
+
+	if len(n.Events) == 0 {
+		n.Events = []string{NotifyOnDelete, NotifyOnFailure}
+	}
+	for _, event := range n.Events {
+		switch event {
+		case NotifyOnPlan, NotifyOnDelete, NotifyOnFailure, NotifyOnNoop:
+		default:
+			return fmt.Errorf("invalid event '%s': must be one of on_plan, on_delete, on_failure, on_noop", event)
+		}
+	}
+
+	if n.Template != "" {
+		if _, err := template.New(n.Name).Funcs(NotifyTemplateFuncs).Parse(n.Template); err != nil {
+			return fmt.Errorf("invalid template: %v", err)
+		}
+	}
+
+	if n.DeleteThreshold < 0 || n.Retries < 0 {
+		return fmt.Errorf("delete_threshold and retries must not be negative")
+	}
+
+	if n.Timeout == "" {
+		n.Timeout = "10s"
+	}
+	duration, err := ParseAge(n.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout '%s': %v", n.Timeout, err)
+	}
+	n.TimeoutDuration = duration
+
+	return nil
+}
+
+// HealthGateConfig describes the cluster health preconditions for deletion
+type HealthGateConfig struct {
+	MinStatus           string        `json:"min_status" yaml:"min_status"` // "green", "yellow" or empty to disable
+	BlockOnRelocating   bool          `json:"block_on_relocating" yaml:"block_on_relocating"`
+	BlockOnInitializing bool          `json:"block_on_initializing" yaml:"block_on_initializing"`
+	MaxPendingTasks     int           `json:"max_pending_tasks" yaml:"max_pending_tasks"` // 0 disables the check
+	Action              string        `json:"action" yaml:"action"`                       // "abort" or "pause"
+	Timeout             string        `json:"timeout" yaml:"timeout"`                     // How long to pause before aborting
+	TimeoutDuration     time.Duration `json:"-" yaml:"-"`
+}
+
+// Enabled reports whether any health precondition is configured
+func (h HealthGateConfig) Enabled() bool {
+	return h.MinStatus != "" || h.BlockOnRelocating || h.BlockOnInitializing || h.MaxPendingTasks > 0
+}
+
 // DefaultConfig returns a configuration with sensible defaults
 func DefaultConfig() *Config {
 	return &Config{
-		ESHost:        "",
-		Username:      "",
-		Password:      "",
-		SkipTLS:       true,
-		MaxSize:       "",
-		MaxAge:        "",
-		IndexPattern:  "vector-*",
-		DeleteIndexes: false,
-		Verbose:       false,
-		Logger:        logger.DefaultConfig(),
+		ESHost:           "",
+		Username:         "",
+		Password:         "",
+		SkipTLS:          true,
+		MaxSize:          "",
+		MaxAge:           "",
+		IndexPattern:     "vector-*",
+		DeleteIndexes:    false,
+		SizeBasis:        SizeBasisTotal,
+		SizeStrategy:     SizeStrategyDelete,
+		SoftDeleteAction: SoftDeleteBlock,
+		GracePeriod:      "1d",
+		SimulateDays:     30,
+		ManagedIndexes:   ManagedSkip,
+		ClosedIndexes:    UnhealthyDelete,
+		RedIndexes:       UnhealthyReport,
+		LockName:         "log-trimmer",
+		LockTTL:          "2m",
+		HealthGate: HealthGateConfig{
+			Action:  HealthGateAbort,
+			Timeout: "10m",
+		},
+		Verbose: false,
+		Output:  OutputTable,
+		Logger:  logger.DefaultConfig(),
 	}
 }
 
+// LoadFromFile loads configuration from a YAML or JSON file, overriding the
+// values already set on c
+func (c *Config) LoadFromFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, c)
+	} else {
+		err = yaml.Unmarshal(data, c)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if c.Logger == nil {
+		c.Logger = logger.DefaultConfig()
+	}
+	return nil
+}
+
+// ResolvePolicies returns one validated configuration per policy, with
+// unset policy fields inherited from c. Without explicit policies the
+// top-level settings form a single policy named after the index pattern.
+func (c *Config) ResolvePolicies() ([]*Config, error) {
+	var resolved []*Config
+	seen := make(map[string]bool)
+	for i, policy := range c.policies() {
+		pc := c.resolvePolicy(policy)
+		if seen[pc.PolicyName] {
+			return nil, fmt.Errorf("policy %d: duplicate policy name '%s'", i+1, pc.PolicyName)
+		}
+		seen[pc.PolicyName] = true
+
+		if err := pc.Validate(); err != nil {
+			return nil, fmt.Errorf("policy '%s': %w", pc.PolicyName, err)
+		}
+		resolved = append(resolved, pc)
+	}
+
+	return resolved, nil
+}
+
+// policies returns the configured policies, or an implicit one covering the
+// top-level settings
+func (c *Config) policies() []PolicyConfig {
+	if len(c.Policies) == 0 {
+		return []PolicyConfig{{Name: c.IndexPattern}}
+	}
+	return c.Policies
+}
+
+// resolvePolicy returns a copy of c with the policy's overrides applied
+func (c *Config) resolvePolicy(policy PolicyConfig) *Config {
+	pc := *c
+	pc.Policies = nil
+	pc.PolicyName = policy.Name
+	if policy.IndexPattern != "" {
+		pc.IndexPattern = policy.IndexPattern
+	}
+	if pc.PolicyName == "" {
+		pc.PolicyName = pc.IndexPattern
+	}
+	if policy.MaxSize != "" {
+		pc.MaxSize = policy.MaxSize
+	}
+	if policy.MaxAge != "" {
+		pc.MaxAge = policy.MaxAge
+	}
+	if policy.Schedule != "" {
+		pc.Schedule = policy.Schedule
+	}
+	if policy.Jitter != "" {
+		pc.Jitter = policy.Jitter
+	}
+	if policy.ClosedIndexes != "" {
+		pc.ClosedIndexes = policy.ClosedIndexes
+	}
+	if policy.RedIndexes != "" {
+		pc.RedIndexes = policy.RedIndexes
+	}
+	return &pc
+}
+
+// Hash returns a short fingerprint of the effective configuration with
+// credentials removed, so audit records can tell which settings a run used
+func (c *Config) Hash() string {
+	redacted := *c
+	redacted.Password = ""
+	redacted.APIToken = ""
+	redacted.APIPassword = ""
+	redacted.Notifiers = nil // Webhook URLs and headers often carry secrets
+
+	data, err := json.Marshal(redacted)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
 // LoadFromEnv loads configuration from environment variables
 func (c *Config) LoadFromEnv() {
 	// Elasticsearch settings
@@ -81,11 +470,197 @@ func (c *Config) LoadFromEnv() {
 	if deleteIndexes := os.Getenv("DELETE_INDEXES"); deleteIndexes != "" {
 		c.DeleteIndexes = strings.ToLower(deleteIndexes) == "true"
 	}
+	if assumeYes := os.Getenv("ASSUME_YES"); assumeYes != "" {
+		c.AssumeYes = strings.ToLower(assumeYes) == "true"
+	}
+	if emptyAge := os.Getenv("EMPTY_INDEX_AGE"); emptyAge != "" {
+		c.EmptyIndexAge = emptyAge
+	}
+	if maxDocs := os.Getenv("MAX_DOCS"); maxDocs != "" {
+		if docs, err := strconv.ParseInt(maxDocs, 10, 64); err == nil {
+			c.MaxDocs = docs
+		}
+	}
+	if maxShards := os.Getenv("MAX_SHARDS"); maxShards != "" {
+		if shards, err := strconv.Atoi(maxShards); err == nil {
+			c.MaxShards = shards
+		}
+	}
+	if sizeBasis := os.Getenv("SIZE_BASIS"); sizeBasis != "" {
+		c.SizeBasis = strings.ToLower(sizeBasis)
+	}
+	if strategy := os.Getenv("SIZE_STRATEGY"); strategy != "" {
+		c.SizeStrategy = strings.ToLower(strategy)
+	}
+	if targetDisk := os.Getenv("TARGET_DISK_PERCENT"); targetDisk != "" {
+		if percent, err := strconv.ParseFloat(strings.TrimSuffix(targetDisk, "%"), 64); err == nil {
+			c.TargetDiskPercent = percent
+		}
+	}
+	if managed := os.Getenv("MANAGED_INDEXES"); managed != "" {
+		c.ManagedIndexes = strings.ToLower(managed)
+	}
+	if closed := os.Getenv("CLOSED_INDEXES"); closed != "" {
+		c.ClosedIndexes = strings.ToLower(closed)
+	}
+	if red := os.Getenv("RED_INDEXES"); red != "" {
+		c.RedIndexes = strings.ToLower(red)
+	}
+
+	// Health gate settings
+	if minStatus := os.Getenv("HEALTH_MIN_STATUS"); minStatus != "" {
+		c.HealthGate.MinStatus = strings.ToLower(minStatus)
+	}
+	if relocating := os.Getenv("HEALTH_BLOCK_ON_RELOCATING"); relocating != "" {
+		c.HealthGate.BlockOnRelocating = strings.ToLower(relocating) == "true"
+	}
+	if initializing := os.Getenv("HEALTH_BLOCK_ON_INITIALIZING"); initializing != "" {
+		c.HealthGate.BlockOnInitializing = strings.ToLower(initializing) == "true"
+	}
+	if pending := os.Getenv("HEALTH_MAX_PENDING_TASKS"); pending != "" {
+		if tasks, err := strconv.Atoi(pending); err == nil {
+			c.HealthGate.MaxPendingTasks = tasks
+		}
+	}
+	if action := os.Getenv("HEALTH_GATE_ACTION"); action != "" {
+		c.HealthGate.Action = strings.ToLower(action)
+	}
+	if timeout := os.Getenv("HEALTH_GATE_TIMEOUT"); timeout != "" {
+		c.HealthGate.Timeout = timeout
+	}
+
+	// Soft delete settings
+	if softDelete := os.Getenv("SOFT_DELETE"); softDelete != "" {
+		c.SoftDelete = strings.ToLower(softDelete) == "true"
+	}
+	if action := os.Getenv("SOFT_DELETE_ACTION"); action != "" {
+		c.SoftDeleteAction = action
+	}
+	if grace := os.Getenv("GRACE_PERIOD"); grace != "" {
+		c.GracePeriod = grace
+	}
+
+	// Maintenance windows, separated by semicolons
+	if windows := os.Getenv("ALLOWED_WINDOWS"); windows != "" {
+		c.AllowedWindows = nil
+		for _, window := range strings.Split(windows, ";") {
+			if window = strings.TrimSpace(window); window != "" {
+				c.AllowedWindows = append(c.AllowedWindows, window)
+			}
+		}
+	}
+	if timezone := os.Getenv("WINDOW_TIMEZONE"); timezone != "" {
+		c.WindowTimezone = timezone
+	}
+
+	// Deletion rate limits
+	if maxDeletes := os.Getenv("MAX_DELETES_PER_MINUTE"); maxDeletes != "" {
+		if value, err := strconv.Atoi(maxDeletes); err == nil {
+			c.MaxDeletesPerMinute = value
+		}
+	}
+	if maxBytes := os.Getenv("MAX_BYTES_PER_MINUTE"); maxBytes != "" {
+		c.MaxBytesPerMinute = maxBytes
+	}
+	if pause := os.Getenv("DELETE_PAUSE"); pause != "" {
+		c.DeletePause = pause
+	}
+
+	// Run lock settings
+	if lockIndex := os.Getenv("LOCK_INDEX"); lockIndex != "" {
+		c.LockIndex = lockIndex
+	}
+	if lockName := os.Getenv("LOCK_NAME"); lockName != "" {
+		c.LockName = lockName
+	}
+	if lockTTL := os.Getenv("LOCK_TTL"); lockTTL != "" {
+		c.LockTTL = lockTTL
+	}
+
+	// Audit settings
+	if auditIndex := os.Getenv("AUDIT_INDEX"); auditIndex != "" {
+		c.AuditIndex = auditIndex
+	}
+
+	// Notification settings
+	if webhookURL := os.Getenv("WEBHOOK_URL"); webhookURL != "" {
+		notifier := NotifierConfig{
+			Name:     "env",
+			URL:      webhookURL,
+			Preset:   os.Getenv("WEBHOOK_PRESET"),
+			Template: os.Getenv("WEBHOOK_TEMPLATE"),
+		}
+		if events := os.Getenv("WEBHOOK_EVENTS"); events != "" {
+			for _, event := range strings.Split(events, ",") {
+				notifier.Events = append(notifier.Events, strings.TrimSpace(event))
+			}
+		}
+		if threshold := os.Getenv("WEBHOOK_DELETE_THRESHOLD"); threshold != "" {
+			if value, err := strconv.Atoi(threshold); err == nil {
+				notifier.DeleteThreshold = value
+			}
+		}
+		c.Notifiers = append(c.Notifiers, notifier)
+	}
+
+	// Daemon settings
+	if schedule := os.Getenv("SCHEDULE"); schedule != "" {
+		c.Schedule = schedule
+	}
+	if jitter := os.Getenv("JITTER"); jitter != "" {
+		c.Jitter = jitter
+	}
+
+	// Control API settings
+	if apiAddr := os.Getenv("API_ADDR"); apiAddr != "" {
+		c.APIAddr = apiAddr
+	}
+	if apiToken := os.Getenv("API_TOKEN"); apiToken != "" {
+		c.APIToken = apiToken
+	}
+	if apiUsername := os.Getenv("API_USERNAME"); apiUsername != "" {
+		c.APIUsername = apiUsername
+	}
+	if apiPassword := os.Getenv("API_PASSWORD"); apiPassword != "" {
+		c.APIPassword = apiPassword
+	}
+
+	// Metrics settings
+	if metricsAddr := os.Getenv("METRICS_ADDR"); metricsAddr != "" {
+		c.MetricsAddr = metricsAddr
+	}
+	if metricsTextfile := os.Getenv("METRICS_TEXTFILE"); metricsTextfile != "" {
+		c.MetricsTextfile = metricsTextfile
+	}
+
+	// Time travel and simulation settings
+	if asOf := os.Getenv("AS_OF"); asOf != "" {
+		c.AsOf = asOf
+	}
+	if days := os.Getenv("SIMULATE_DAYS"); days != "" {
+		if value, err := strconv.Atoi(days); err == nil {
+			c.SimulateDays = value
+		}
+	}
+	if growth := os.Getenv("DAILY_GROWTH"); growth != "" {
+		c.DailyGrowth = growth
+	}
+
+	// Capacity report settings
+	if groupBy := os.Getenv("REPORT_GROUP_BY"); groupBy != "" {
+		c.ReportGroupBy = groupBy
+	}
+	if sortBy := os.Getenv("REPORT_SORT"); sortBy != "" {
+		c.ReportSort = sortBy
+	}
 
 	// Application settings
 	if verbose := os.Getenv("VERBOSE"); verbose != "" {
 		c.Verbose = strings.ToLower(verbose) == "true"
 	}
+	if output := os.Getenv("OUTPUT_FORMAT"); output != "" {
+		c.Output = output
+	}
 
 	// Logger settings
 	if logLevel := os.Getenv("LOG_LEVEL"); logLevel != "" {
@@ -103,41 +678,339 @@ func (c *Config) LoadFromEnv() {
 	}
 }
 
+// FieldError is a validation error for one setting, identified by its key
+// in the config file such as "max_size" or "notifiers[0]". Errors that
+// involve no single setting have an empty key.
+type FieldError struct {
+	Key string
+	Err error
+}
+
+func (e FieldError) Error() string {
+	return e.Err.Error()
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// fieldErrors collects validation errors
+type fieldErrors []FieldError
+
+// add records a validation error for the setting with the given key
+func (e *fieldErrors) add(key, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Key: key, Err: fmt.Errorf(format, args...)})
+}
+
 // Validate validates the configuration and parses computed fields
 func (c *Config) Validate() error {
+	if errs := c.Check(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Check validates the configuration and parses computed fields like
+// Validate, but carries on past the first error and returns all of them
+func (c *Config) Check() []FieldError {
+	var errs fieldErrors
+
 	// Host is required
 	if c.ESHost == "" {
-		return fmt.Errorf("elasticsearch host is required (use --host flag or ES_HOST environment variable)")
+		errs.add("es_host", "elasticsearch host is required (use --host flag or ES_HOST environment variable)")
 	}
 
 	// Parse max size if provided
 	if c.MaxSize != "" {
-		size, err := parseSize(c.MaxSize)
+		size, err := ParseSize(c.MaxSize)
 		if err != nil {
-			return fmt.Errorf("invalid max-size format '%s': %v", c.MaxSize, err)
+			errs.add("max_size", "invalid max-size format '%s': %v", c.MaxSize, err)
 		}
 		c.MaxSizeBytes = size
 	}
 
 	// Parse max age if provided
 	if c.MaxAge != "" {
-		duration, err := parseAge(c.MaxAge)
+		duration, err := ParseAge(c.MaxAge)
 		if err != nil {
-			return fmt.Errorf("invalid max-age format '%s': %v", c.MaxAge, err)
+			errs.add("max_age", "invalid max-age format '%s': %v", c.MaxAge, err)
 		}
 		c.MaxAgeDuration = duration
 	}
 
+	// Parse empty index grace age if provided
+	if c.EmptyIndexAge != "" {
+		duration, err := ParseAge(c.EmptyIndexAge)
+		if err != nil {
+			errs.add("empty_index_age", "invalid empty-index-age format '%s': %v", c.EmptyIndexAge, err)
+		}
+		c.EmptyIndexAgeDuration = duration
+	}
+
+	// Validate document budget
+	if c.MaxDocs < 0 {
+		errs.add("max_docs", "invalid max-docs %d: must not be negative", c.MaxDocs)
+	}
+
+	// Validate shard budget
+	if c.MaxShards < 0 {
+		errs.add("max_shards", "invalid max-shards %d: must not be negative", c.MaxShards)
+	}
+
+	// Validate size basis
+	switch c.SizeBasis {
+	case "":
+		c.SizeBasis = SizeBasisTotal
+	case SizeBasisTotal, SizeBasisPrimary:
+	default:
+		errs.add("size_basis", "invalid size-basis '%s': must be one of primary, total", c.SizeBasis)
+	}
+
+	// Validate size strategy; replicas do not count towards the primary
+	// size basis, so dropping them cannot bring it under MaxSize
+	switch c.SizeStrategy {
+	case "":
+		c.SizeStrategy = SizeStrategyDelete
+	case SizeStrategyDelete:
+	case SizeStrategyReduceReplicas:
+		if c.SizeBasis != SizeBasisTotal {
+			errs.add("size_strategy", "size-strategy reduce_replicas requires size-basis total")
+		}
+	default:
+		errs.add("size_strategy", "invalid size-strategy '%s': must be one of delete, reduce_replicas", c.SizeStrategy)
+	}
+
+	// Validate disk usage target
+	if c.TargetDiskPercent < 0 || c.TargetDiskPercent >= 100 {
+		errs.add("target_disk_percent", "invalid target-disk-percent %.1f: must be between 0 and 100", c.TargetDiskPercent)
+	}
+
+	// Validate managed index handling
+	switch c.ManagedIndexes {
+	case "":
+		c.ManagedIndexes = ManagedSkip
+	case ManagedSkip, ManagedWarn, ManagedTakeOver:
+	default:
+		errs.add("managed_indexes", "invalid managed-indexes mode '%s': must be one of skip, warn, take_over", c.ManagedIndexes)
+	}
+
+	// Validate closed and red index handling
+	switch c.ClosedIndexes {
+	case "":
+		c.ClosedIndexes = UnhealthyDelete
+	case UnhealthyDelete, UnhealthySkip, UnhealthyReport:
+	default:
+		errs.add("closed_indexes", "invalid closed-indexes mode '%s': must be one of delete, skip, report", c.ClosedIndexes)
+	}
+	switch c.RedIndexes {
+	case "":
+		c.RedIndexes = UnhealthyReport
+	case UnhealthyDelete, UnhealthySkip, UnhealthyReport:
+	default:
+		errs.add("red_indexes", "invalid red-indexes mode '%s': must be one of delete, skip, report", c.RedIndexes)
+	}
+
+	// Validate health gate
+	switch c.HealthGate.MinStatus {
+	case "", "green", "yellow":
+	default:
+		errs.add("health_gate.min_status", "invalid health min-status '%s': must be green or yellow", c.HealthGate.MinStatus)
+	}
+	switch c.HealthGate.Action {
+	case "":
+		c.HealthGate.Action = HealthGateAbort
+	case HealthGateAbort, HealthGatePause:
+	default:
+		errs.add("health_gate.action", "invalid health gate action '%s': must be abort or pause", c.HealthGate.Action)
+	}
+	if c.HealthGate.Timeout != "" {
+		duration, err := ParseAge(c.HealthGate.Timeout)
+		if err != nil {
+			errs.add("health_gate.timeout", "invalid health gate timeout '%s': %v", c.HealthGate.Timeout, err)
+		}
+		c.HealthGate.TimeoutDuration = duration
+	}
+
+	// Validate report output format
+	switch c.Output {
+	case "":
+		c.Output = OutputTable
+	case OutputTable, OutputJSON, OutputCSV:
+	default:
+		errs.add("output", "invalid output format '%s': must be table, json or csv", c.Output)
+	}
+
+	// Parse the as-of time and simulation settings
+	c.AsOfTime = time.Time{}
+	if c.AsOf != "" {
+		asOf, err := ParseTime(c.AsOf)
+		if err != nil {
+			errs.add("as_of", "invalid as-of '%s': %v", c.AsOf, err)
+		}
+		c.AsOfTime = asOf
+		if c.DeleteIndexes {
+			errs.add("as_of", "as-of can only be used for dry runs, not with delete-indexes")
+		}
+	}
+	if c.SimulateDays < 0 {
+		errs.add("simulate_days", "invalid simulate-days %d: must not be negative", c.SimulateDays)
+	}
+	if c.DailyGrowth != "" {
+		size, err := ParseSize(c.DailyGrowth)
+		if err != nil {
+			errs.add("daily_growth", "invalid daily-growth format '%s': %v", c.DailyGrowth, err)
+		}
+		c.DailyGrowthBytes = size
+	}
+
+	// Compile the capacity report grouping
+	c.ReportGroupPattern = nil
+	if c.ReportGroupBy != "" {
+		pattern, err := regexp.Compile(c.ReportGroupBy)
+		if err != nil {
+			errs.add("report_group_by", "invalid report-group-by regex '%s': %v", c.ReportGroupBy, err)
+		} else if pattern.NumSubexp() < 1 {
+			errs.add("report_group_by", "report-group-by regex '%s' needs a capture group", c.ReportGroupBy)
+		} else {
+			c.ReportGroupPattern = pattern
+		}
+	}
+
+	// Parse soft delete settings
+	switch c.SoftDeleteAction {
+	case "":
+		c.SoftDeleteAction = SoftDeleteBlock
+	case SoftDeleteBlock, SoftDeleteClose:
+	default:
+		errs.add("soft_delete_action", "invalid soft-delete-action '%s': must be block or close", c.SoftDeleteAction)
+	}
+	if c.SoftDelete {
+		if c.GracePeriod == "" {
+			c.GracePeriod = "1d"
+		}
+		duration, err := ParseAge(c.GracePeriod)
+		if err != nil {
+			errs.add("grace_period", "invalid grace-period format '%s': %v", c.GracePeriod, err)
+		}
+		c.GracePeriodDuration = duration
+	}
+
+	// Parse maintenance windows
+	c.Windows = nil
+	if len(c.AllowedWindows) > 0 {
+		windows, err := scheduler.ParseWindows(c.AllowedWindows, c.WindowTimezone)
+		if err != nil {
+			errs.add("allowed_windows", "invalid allowed-windows: %v", err)
+		}
+		c.Windows = windows
+	}
+
+	// Parse deletion rate limits
+	if c.MaxDeletesPerMinute < 0 {
+		errs.add("max_deletes_per_minute", "invalid max-deletes-per-minute %d: must not be negative", c.MaxDeletesPerMinute)
+	}
+	if c.MaxBytesPerMinute != "" {
+		size, err := ParseSize(c.MaxBytesPerMinute)
+		if err != nil {
+			errs.add("max_bytes_per_minute", "invalid max-bytes-per-minute format '%s': %v", c.MaxBytesPerMinute, err)
+		}
+		c.MaxBytesPerMinuteBytes = size
+	}
+	if c.DeletePause != "" {
+		duration, err := ParseAge(c.DeletePause)
+		if err != nil {
+			errs.add("delete_pause", "invalid delete-pause format '%s': %v", c.DeletePause, err)
+		}
+		c.DeletePauseDuration = duration
+	}
+
+	// Parse run lock settings
+	if c.LockIndex != "" {
+		if c.LockName == "" {
+			c.LockName = "log-trimmer"
+		}
+		if c.LockTTL == "" {
+			c.LockTTL = "2m"
+		}
+		duration, err := ParseAge(c.LockTTL)
+		if err != nil {
+			errs.add("lock_ttl", "invalid lock-ttl format '%s': %v", c.LockTTL, err)
+		} else if duration < 3*time.Second {
+			errs.add("lock_ttl", "lock-ttl must be at least 3s, got %s", c.LockTTL)
+		}
+		c.LockTTLDuration = duration
+	}
+
+	// Parse schedule jitter if provided
+	if c.Jitter != "" {
+		duration, err := ParseAge(c.Jitter)
+		if err != nil {
+			errs.add("jitter", "invalid jitter format '%s': %v", c.Jitter, err)
+		}
+		c.JitterDuration = duration
+	}
+
+	// Validate notifiers
+	for i := range c.Notifiers {
+		if err := c.Notifiers[i].validate(); err != nil {
+			name := c.Notifiers[i].Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			errs = append(errs, FieldError{Key: fmt.Sprintf("notifiers[%d]", i), Err: fmt.Errorf("notifier %s: %w", name, err)})
+		}
+	}
+
+	// The control API must never be exposed without authentication
+	if c.APIAddr != "" && c.APIToken == "" && (c.APIUsername == "" || c.APIPassword == "") {
+		errs.add("api_addr", "api-addr requires either an api-token or api-username and api-password")
+	}
+
 	// Must specify at least one constraint
-	if c.MaxSize == "" && c.MaxAge == "" {
-		return fmt.Errorf("must specify at least one of --max-size/MAX_SIZE or --max-age/MAX_AGE")
+	if c.MaxSize == "" && c.MaxAge == "" && c.EmptyIndexAge == "" && c.MaxDocs == 0 && c.MaxShards == 0 && c.TargetDiskPercent == 0 {
+		errs.add("", "must specify at least one of --max-size/MAX_SIZE, --max-age/MAX_AGE, --empty-index-age/EMPTY_INDEX_AGE, --max-docs/MAX_DOCS, --max-shards/MAX_SHARDS or --target-disk-percent/TARGET_DISK_PERCENT")
 	}
 
-	return nil
+	c.checkConflicts(&errs)
+	return errs
+}
+
+// checkConflicts reports settings that are valid on their own but do not
+// work together
+func (c *Config) checkConflicts(errs *fieldErrors) {
+	// Dropping replicas is how the size rule reclaims space, so the
+	// strategy does nothing without a size limit
+	if c.SizeStrategy == SizeStrategyReduceReplicas && c.MaxSize == "" {
+		errs.add("size_strategy", "size-strategy reduce_replicas has no effect without max-size")
+	}
+
+	// Soft deleted indexes are closed, and closed indexes that are skipped
+	// or only reported are never selected again, so they would never be
+	// deleted
+	if c.SoftDelete && c.SoftDeleteAction == SoftDeleteClose && c.ClosedIndexes != UnhealthyDelete {
+		errs.add("closed_indexes", "closed-indexes %s conflicts with soft-delete-action close: closed indexes would never be deleted", c.ClosedIndexes)
+	}
+
+	if (c.APIUsername == "") != (c.APIPassword == "") {
+		errs.add("api_username", "api-username and api-password must be set together")
+	}
+
+	if c.Logger != nil {
+		switch c.Logger.Level {
+		case "", logger.LevelDebug, logger.LevelInfo, logger.LevelWarn, logger.LevelError, logger.LevelFatal:
+		default:
+			errs.add("logger.level", "invalid log level '%s': must be one of debug, info, warn, error, fatal", c.Logger.Level)
+		}
+		switch c.Logger.Format {
+		case "", "console", "json":
+		default:
+			errs.add("logger.format", "invalid log format '%s': must be console or json", c.Logger.Format)
+		}
+	}
 }
 
-// parseSize parses a size string like "10GB" into bytes
-func parseSize(sizeStr string) (int64, error) {
+// ParseSize parses a size string like "10GB" into bytes
+func ParseSize(sizeStr string) (int64, error) {
 	re := regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([KMGT]?B?)$`)
 	matches := re.FindStringSubmatch(strings.ToUpper(sizeStr))
 	if len(matches) != 3 {
@@ -170,8 +1043,8 @@ func parseSize(sizeStr string) (int64, error) {
 	return int64(value * float64(multiplier)), nil
 }
 
-// parseAge parses an age string like "7d" into a duration
-func parseAge(ageStr string) (time.Duration, error) {
+// ParseAge parses an age string like "7d" into a duration
+func ParseAge(ageStr string) (time.Duration, error) {
 	re := regexp.MustCompile(`^(\d+)\s*([smhdw])$`)
 	matches := re.FindStringSubmatch(strings.ToLower(ageStr))
 	if len(matches) != 3 {
@@ -203,3 +1076,14 @@ func parseAge(ageStr string) (time.Duration, error) {
 
 	return duration, nil
 }
+
+// ParseTime parses an RFC 3339 timestamp or a YYYY-MM-DD date in UTC
+func ParseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time format, expected format like '2024-03-01' or '2024-03-01T12:00:00Z'")
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/company/log-trimmer/internal/logger"
 )
@@ -61,7 +62,7 @@ func TestParseSize(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		result, err := parseSize(tt.input)
+		result, err := ParseSize(tt.input)
 		if tt.wantErr && err == nil {
 			t.Errorf("Expected error for input '%s'", tt.input)
 		}
@@ -70,3 +71,254 @@ func TestParseSize(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateManagedIndexes(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ESHost = "https://localhost:9200"
+	cfg.MaxAge = "7d"
+
+	if cfg.ManagedIndexes != ManagedSkip {
+		t.Errorf("Expected default managed mode 'skip', got %s", cfg.ManagedIndexes)
+	}
+
+	cfg.ManagedIndexes = "ignore"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for invalid managed-indexes mode")
+	}
+}
+
+func TestValidateDocumentRules(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ESHost = "https://localhost:9200"
+	cfg.EmptyIndexAge = "1d"
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected empty-index-age alone to be a valid constraint, got: %v", err)
+	}
+	if cfg.EmptyIndexAgeDuration != 24*time.Hour {
+		t.Errorf("Expected 24h grace age, got %v", cfg.EmptyIndexAgeDuration)
+	}
+
+	cfg.EmptyIndexAge = "soon"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for invalid empty-index-age")
+	}
+}
+
+func TestResolvePolicies(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ESHost = "https://localhost:9200"
+	cfg.MaxAge = "7d"
+
+	policies, err := cfg.ResolvePolicies()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(policies) != 1 || policies[0].PolicyName != "vector-*" {
+		t.Fatalf("Expected an implicit policy named after the pattern, got %d", len(policies))
+	}
+
+	cfg.Policies = []PolicyConfig{
+		{Name: "app", IndexPattern: "app-*"},
+		{Name: "app", IndexPattern: "other-*"},
+	}
+	if _, err := cfg.ResolvePolicies(); err == nil {
+		t.Error("Expected error for duplicate policy names")
+	}
+}
+
+func TestHashIgnoresCredentials(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Password = "secret"
+	other := DefaultConfig()
+	other.Password = "different"
+
+	if cfg.Hash() != other.Hash() {
+		t.Error("Expected hash to ignore the password")
+	}
+
+	other.MaxAge = "90d"
+	if cfg.Hash() == other.Hash() {
+		t.Error("Expected hash to change with retention settings")
+	}
+}
+
+func TestValidateNotifiers(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ESHost = "http://localhost:9200"
+	cfg.MaxAge = "7d"
+	cfg.Notifiers = []NotifierConfig{{Name: "slack", URL: "https://hooks.example.com/x", Preset: NotifyPresetSlack}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected notifier to be valid, got %v", err)
+	}
+	if n := cfg.Notifiers[0]; len(n.Events) != 2 || n.TimeoutDuration != 10*time.Second {
+		t.Errorf("Expected default events and timeout, got %+v", n)
+	}
+
+	cfg.Notifiers[0].Events = []string{"on_success"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for unknown event")
+	}
+
+	cfg.Notifiers[0].Events = nil
+	cfg.Notifiers[0].Preset = "discord"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for unknown preset")
+	}
+
+	cfg.Notifiers[0].Preset = NotifyPresetGeneric
+	cfg.Notifiers[0].Template = `{"text": "{{ .Summary | bytes }"}`
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for a template that does not parse")
+	}
+	cfg.Notifiers[0].Template = `{{ frobnicate .Planned }}`
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for a template calling an unknown function")
+	}
+	cfg.Notifiers[0].Template = `{"indexes": {{ json .Indexes }}, "size": "{{ bytes .DeletedBytes }}"}`
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected template to be valid, got %v", err)
+	}
+}
+
+func TestValidateAllowedWindows(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ESHost = "http://localhost:9200"
+	cfg.MaxAge = "7d"
+	cfg.AllowedWindows = []string{"Mon-Fri 22:00-06:00"}
+
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for windows without a time zone")
+	}
+
+	cfg.WindowTimezone = "America/New_York"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected windows to be valid, got %v", err)
+	}
+	if cfg.Windows == nil {
+		t.Error("Expected parsed windows to be set")
+	}
+}
+
+func TestValidateSoftDelete(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ESHost = "http://localhost:9200"
+	cfg.MaxAge = "7d"
+	cfg.SoftDelete = true
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected soft delete defaults to be valid, got %v", err)
+	}
+	if cfg.GracePeriodDuration != 24*time.Hour || cfg.SoftDeleteAction != SoftDeleteBlock {
+		t.Errorf("Expected 1d grace period with write block, got %s / %s", cfg.GracePeriodDuration, cfg.SoftDeleteAction)
+	}
+
+	cfg.SoftDeleteAction = "freeze"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for unknown soft delete action")
+	}
+}
+
+func TestValidateReportGroupBy(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ESHost = "http://localhost:9200"
+	cfg.MaxAge = "7d"
+
+	cfg.ReportGroupBy = `^logs-\w+-`
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for regex without a capture group")
+	}
+
+	cfg.ReportGroupBy = `([a-z`
+	if err := cfg.Validate(); err == nil || cfg.ReportGroupPattern != nil {
+		t.Errorf("Expected error for invalid regex, got %v", err)
+	}
+
+	cfg.ReportGroupBy = `^logs-(\w+)-`
+	if err := cfg.Validate(); err != nil || cfg.ReportGroupPattern == nil {
+		t.Errorf("Expected regex to compile, got %v", err)
+	}
+
+	cfg.Output = "xml"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for unknown output format")
+	}
+}
+
+func TestUnhealthyIndexModes(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ESHost = "https://localhost:9200"
+	cfg.MaxAge = "7d"
+	cfg.Policies = []PolicyConfig{
+		{Name: "app", IndexPattern: "app-*", ClosedIndexes: UnhealthySkip},
+		{Name: "audit", IndexPattern: "audit-*"},
+	}
+
+	policies, err := cfg.ResolvePolicies()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if policies[0].ClosedIndexes != UnhealthySkip || policies[0].RedIndexes != UnhealthyReport {
+		t.Errorf("Expected app to skip closed and report red indexes, got %s/%s", policies[0].ClosedIndexes, policies[0].RedIndexes)
+	}
+	if policies[1].ClosedIndexes != UnhealthyDelete {
+		t.Errorf("Expected audit to inherit closed mode 'delete', got %s", policies[1].ClosedIndexes)
+	}
+
+	cfg.Policies = nil
+	cfg.RedIndexes = "ignore"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for invalid red-indexes mode")
+	}
+}
+
+func TestValidateSizeStrategy(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ESHost = "https://localhost:9200"
+	cfg.MaxSize = "100GB"
+
+	cfg.SizeStrategy = SizeStrategyReduceReplicas
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cfg.SizeBasis = SizeBasisPrimary
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for reduce_replicas with the primary size basis")
+	}
+
+	cfg.SizeBasis = SizeBasisTotal
+	cfg.SizeStrategy = "shrink"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for invalid size-strategy")
+	}
+}
+
+func TestValidateAsOfAndSimulation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ESHost = "https://localhost:9200"
+	cfg.MaxAge = "7d"
+	cfg.AsOf = "2024-03-01"
+	cfg.DailyGrowth = "50GB"
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !cfg.AsOfTime.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected as-of time %v", cfg.AsOfTime)
+	}
+	if cfg.DailyGrowthBytes != 50*1024*1024*1024 {
+		t.Errorf("Unexpected daily growth %d", cfg.DailyGrowthBytes)
+	}
+
+	cfg.DeleteIndexes = true
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for as-of with delete-indexes")
+	}
+
+	cfg.DeleteIndexes = false
+	cfg.AsOf = "last tuesday"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for invalid as-of")
+	}
+}
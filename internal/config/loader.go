@@ -0,0 +1,563 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of places a setting can come from
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// redactedValue replaces secrets in shown configurations
+const redactedValue = "[REDACTED]"
+
+// Source is where the value of a setting came from
+type Source struct {
+	Kind string `json:"kind"`
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	Name string `json:"name,omitempty"` // Environment variable or flag name
+}
+
+// String returns the source as "file:line", "env NAME", "flag --name" or
+// "default"
+func (s Source) String() string {
+	switch s.Kind {
+	case SourceFile:
+		if s.Line > 0 {
+			return fmt.Sprintf("%s:%d", s.File, s.Line)
+		}
+		return s.File
+	case SourceEnv:
+		return "env " + s.Name
+	case SourceFlag:
+		return "flag --" + s.Name
+	case SourceDefault:
+		return SourceDefault
+	default:
+		return "config"
+	}
+}
+
+// Problem is a configuration error and the place it was found
+type Problem struct {
+	Key     string `json:"key,omitempty"`
+	Source  Source `json:"source"`
+	Message string `json:"message"`
+}
+
+// String formats the problem like a compiler error, e.g.
+// "config.yaml:4: max_size: invalid max-size format '5XB'"
+func (p Problem) String() string {
+	if p.Key == "" {
+		return fmt.Sprintf("%s: %s", p.Source, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Source, p.Key, p.Message)
+}
+
+// envKind is the type of value an environment variable holds
+type envKind int
+
+const (
+	envString envKind = iota
+	envInt
+	envFloat
+	envBool
+)
+
+// check reports whether value parses the way LoadFromEnv parses it; invalid
+// numbers and booleans are otherwise ignored or read as false
+func (k envKind) check(value string) error {
+	switch k {
+	case envInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("invalid value '%s': expected a whole number", value)
+		}
+	case envFloat:
+		if _, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64); err != nil {
+			return fmt.Errorf("invalid value '%s': expected a number", value)
+		}
+	case envBool:
+		if lower := strings.ToLower(value); lower != "true" && lower != "false" {
+			return fmt.Errorf("invalid value '%s': expected true or false", value)
+		}
+	}
+	return nil
+}
+
+// envSetting maps an environment variable read by LoadFromEnv to the key
+// of the setting it sets. "notifiers[]" is the notifier WEBHOOK_URL adds.
+type envSetting struct {
+	name string
+	key  string
+	kind envKind
+}
+
+// envSettings lists the environment variables read by LoadFromEnv
+var envSettings = []envSetting{
+	{"ES_HOST", "es_host", envString},
+	{"ES_USERNAME", "username", envString},
+	{"ES_PASSWORD", "password", envString},
+	{"ES_SKIP_TLS", "skip_tls", envBool},
+	{"MAX_SIZE", "max_size", envString},
+	{"MAX_AGE", "max_age", envString},
+	{"INDEX_PATTERN", "index_pattern", envString},
+	{"DELETE_INDEXES", "delete_indexes", envBool},
+	{"ASSUME_YES", "assume_yes", envBool},
+	{"EMPTY_INDEX_AGE", "empty_index_age", envString},
+	{"MAX_DOCS", "max_docs", envInt},
+	{"MAX_SHARDS", "max_shards", envInt},
+	{"SIZE_BASIS", "size_basis", envString},
+	{"SIZE_STRATEGY", "size_strategy", envString},
+	{"TARGET_DISK_PERCENT", "target_disk_percent", envFloat},
+	{"MANAGED_INDEXES", "managed_indexes", envString},
+	{"CLOSED_INDEXES", "closed_indexes", envString},
+	{"RED_INDEXES", "red_indexes", envString},
+	{"HEALTH_MIN_STATUS", "health_gate.min_status", envString},
+	{"HEALTH_BLOCK_ON_RELOCATING", "health_gate.block_on_relocating", envBool},
+	{"HEALTH_BLOCK_ON_INITIALIZING", "health_gate.block_on_initializing", envBool},
+	{"HEALTH_MAX_PENDING_TASKS", "health_gate.max_pending_tasks", envInt},
+	{"HEALTH_GATE_ACTION", "health_gate.action", envString},
+	{"HEALTH_GATE_TIMEOUT", "health_gate.timeout", envString},
+	{"SOFT_DELETE", "soft_delete", envBool},
+	{"SOFT_DELETE_ACTION", "soft_delete_action", envString},
+	{"GRACE_PERIOD", "grace_period", envString},
+	{"ALLOWED_WINDOWS", "allowed_windows", envString},
+	{"WINDOW_TIMEZONE", "window_timezone", envString},
+	{"MAX_DELETES_PER_MINUTE", "max_deletes_per_minute", envInt},
+	{"MAX_BYTES_PER_MINUTE", "max_bytes_per_minute", envString},
+	{"DELETE_PAUSE", "delete_pause", envString},
+	{"LOCK_INDEX", "lock_index", envString},
+	{"LOCK_NAME", "lock_name", envString},
+	{"LOCK_TTL", "lock_ttl", envString},
+	{"AUDIT_INDEX", "audit_index", envString},
+	{"WEBHOOK_URL", "notifiers[]", envString},
+	{"WEBHOOK_PRESET", "notifiers[].preset", envString},
+	{"WEBHOOK_TEMPLATE", "notifiers[].template", envString},
+	{"WEBHOOK_EVENTS", "notifiers[].events", envString},
+	{"WEBHOOK_DELETE_THRESHOLD", "notifiers[].delete_threshold", envInt},
+	{"SCHEDULE", "schedule", envString},
+	{"JITTER", "jitter", envString},
+	{"API_ADDR", "api_addr", envString},
+	{"API_TOKEN", "api_token", envString},
+	{"API_USERNAME", "api_username", envString},
+	{"API_PASSWORD", "api_password", envString},
+	{"METRICS_ADDR", "metrics_addr", envString},
+	{"METRICS_TEXTFILE", "metrics_textfile", envString},
+	{"AS_OF", "as_of", envString},
+	{"SIMULATE_DAYS", "simulate_days", envInt},
+	{"DAILY_GROWTH", "daily_growth", envString},
+	{"REPORT_GROUP_BY", "report_group_by", envString},
+	{"REPORT_SORT", "report_sort", envString},
+	{"VERBOSE", "verbose", envBool},
+	{"OUTPUT_FORMAT", "output", envString},
+	{"LOG_LEVEL", "logger.level", envString},
+	{"LOG_FORMAT", "logger.format", envString},
+	{"LOG_OUTPUT", "logger.output", envString},
+	{"LOG_FILE", "logger.file_path", envString},
+	{"LOG_FILE", "logger.enable_file", envString},
+}
+
+// flagSettings maps command line flags to the keys of the settings they set
+var flagSettings = map[string]string{
+	"host":           "es_host",
+	"username":       "username",
+	"password":       "password",
+	"max-age":        "max_age",
+	"max-size":       "max_size",
+	"pattern":        "index_pattern",
+	"delete-indexes": "delete_indexes",
+	"yes":            "assume_yes",
+	"verbose":        "verbose",
+	"skip-tls":       "skip_tls",
+	"log-level":      "logger.level",
+	"log-format":     "logger.format",
+	"log-file":       "logger.file_path",
+	"as-of":          "as_of",
+	"days":           "simulate_days",
+	"daily-growth":   "daily_growth",
+}
+
+// Loader builds a configuration from defaults, a config file, environment
+// variables and command line flags. It remembers where each setting came
+// from and collects every problem instead of stopping at the first.
+type Loader struct {
+	Config   *Config
+	Sources  map[string]Source // Keyed by setting, e.g. "max_size" or "policies[0].max_age"
+	Problems []Problem         // Problems found while loading
+
+	lines map[string]map[int]string // Setting key at each line of each loaded file
+}
+
+// NewLoader returns a loader holding the default configuration
+func NewLoader() *Loader {
+	return &Loader{
+		Config:  DefaultConfig(),
+		Sources: make(map[string]Source),
+		lines:   make(map[string]map[int]string),
+	}
+}
+
+// record sets the source of a setting. A list or section set as a whole
+// replaces the sources of everything in it.
+func (l *Loader) record(key string, source Source) {
+	for existing := range l.Sources {
+		if strings.HasPrefix(existing, key+"[") || strings.HasPrefix(existing, key+".") {
+			delete(l.Sources, existing)
+		}
+	}
+	l.Sources[key] = source
+}
+
+// Source returns where a setting came from. Settings inside a list item
+// that were not set themselves come from the item.
+func (l *Loader) Source(key string) Source {
+	for key != "" {
+		if source, ok := l.Sources[key]; ok {
+			return source
+		}
+		if !strings.HasSuffix(key, "]") {
+			key = key[:strings.LastIndex(key, "]")+1]
+			continue
+		}
+		key = key[:strings.LastIndex(key, "[")]
+	}
+	return Source{Kind: SourceDefault}
+}
+
+// LoadFile loads a YAML or JSON config file like LoadFromFile, recording
+// the line of every setting and reporting unknown settings and values of
+// the wrong type
+func (l *Loader) LoadFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.Problems = append(l.Problems, Problem{Source: Source{Kind: SourceFile, File: path}, Message: err.Error()})
+		return
+	}
+
+	// JSON is valid YAML, so both formats are walked as YAML nodes
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		l.fileProblem(path, data, err)
+		return
+	}
+	l.lines[path] = make(map[int]string)
+	if len(root.Content) > 0 {
+		l.walkFile(path, root.Content[0], reflect.TypeOf(Config{}), "")
+	}
+
+	if err := l.Config.LoadFromFile(path); err != nil {
+		l.fileProblem(path, data, err)
+	}
+}
+
+// walkFile records the sources of the settings under node and reports keys
+// that do not match a field of t
+func (l *Loader) walkFile(path string, node *yaml.Node, t reflect.Type, prefix string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		// Maps such as notifier headers accept any key
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name, value := node.Content[i], node.Content[i+1]
+			key := joinKey(prefix, name.Value)
+			source := Source{Kind: SourceFile, File: path, Line: name.Line}
+			field, ok := fields[name.Value]
+			if !ok {
+				l.Problems = append(l.Problems, Problem{Key: key, Source: source, Message: "unknown setting"})
+				continue
+			}
+			l.Sources[key] = source
+			l.lines[path][name.Line] = key
+			l.walkFile(path, value, field.Type, key)
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice {
+			return
+		}
+		for i, item := range node.Content {
+			key := fmt.Sprintf("%s[%d]", prefix, i)
+			l.Sources[key] = Source{Kind: SourceFile, File: path, Line: item.Line}
+			if _, ok := l.lines[path][item.Line]; !ok {
+				l.lines[path][item.Line] = key
+			}
+			l.walkFile(path, item, t.Elem(), key)
+		}
+	}
+}
+
+// yamlFields returns the fields of a struct type by their YAML key
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// joinKey appends a setting name to a parent key
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// lineRe finds the line number in YAML parser and decoder errors
+var lineRe = regexp.MustCompile(`line (\d+): (.*)`)
+
+// fileProblem reports a file parse error, with one problem per line for
+// YAML type errors
+func (l *Loader) fileProblem(path string, data []byte, err error) {
+	var messages []string
+	var typeErr *yaml.TypeError
+	var jsonTypeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		messages = typeErr.Errors
+	case errors.As(err, &jsonTypeErr):
+		line := offsetLine(data, jsonTypeErr.Offset)
+		messages = []string{fmt.Sprintf("line %d: cannot use %s as %s", line, jsonTypeErr.Value, jsonTypeErr.Type)}
+	case errors.As(err, &syntaxErr):
+		messages = []string{fmt.Sprintf("line %d: %s", offsetLine(data, syntaxErr.Offset), syntaxErr.Error())}
+	default:
+		messages = []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+
+	for _, message := range messages {
+		problem := Problem{Source: Source{Kind: SourceFile, File: path}, Message: message}
+		if match := lineRe.FindStringSubmatch(message); match != nil {
+			problem.Source.Line, _ = strconv.Atoi(match[1])
+			problem.Key = l.lines[path][problem.Source.Line]
+			problem.Message = match[2]
+		}
+		l.Problems = append(l.Problems, problem)
+	}
+}
+
+// offsetLine returns the line holding a byte offset
+func offsetLine(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return strings.Count(string(data[:offset]), "\n") + 1
+}
+
+// LoadEnv loads environment variables like LoadFromEnv, recording which
+// settings they set and reporting values LoadFromEnv would ignore
+func (l *Loader) LoadEnv() {
+	notifier := fmt.Sprintf("notifiers[%d]", len(l.Config.Notifiers))
+	l.Config.LoadFromEnv()
+
+	for _, setting := range envSettings {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		source := Source{Kind: SourceEnv, Name: setting.name}
+
+		key := setting.key
+		if strings.HasPrefix(key, "notifiers[]") {
+			if os.Getenv("WEBHOOK_URL") == "" {
+				l.Problems = append(l.Problems, Problem{Source: source, Message: "has no effect without WEBHOOK_URL"})
+				continue
+			}
+			key = notifier + strings.TrimPrefix(key, "notifiers[]")
+		}
+
+		l.record(key, source)
+		if err := setting.kind.check(value); err != nil {
+			l.Problems = append(l.Problems, Problem{Key: key, Source: source, Message: err.Error()})
+		}
+	}
+}
+
+// RecordFlag records that a command line flag, already applied to Config,
+// set its setting
+func (l *Loader) RecordFlag(name string) {
+	if key, ok := flagSettings[name]; ok {
+		l.record(key, Source{Kind: SourceFlag, Name: name})
+	}
+}
+
+// Validate checks every policy resolved from the loaded configuration and
+// returns the problems found while loading followed by the validation
+// errors, each located where the offending setting was set
+func (l *Loader) Validate() []Problem {
+	problems := append([]Problem(nil), l.Problems...)
+	seen := make(map[string]bool)
+	add := func(key, message string) {
+		problem := Problem{Key: key, Source: l.Source(key), Message: message}
+		if key == "" {
+			problem.Source = Source{}
+		}
+		if !seen[problem.String()] {
+			seen[problem.String()] = true
+			problems = append(problems, problem)
+		}
+	}
+
+	// Settings a policy overrides are reported at the policy, everything
+	// else at the top level where all policies inherit it from
+	names := make(map[string]bool)
+	for i, policy := range l.Config.policies() {
+		pc := l.Config.resolvePolicy(policy)
+		prefix := ""
+		if len(l.Config.Policies) > 0 {
+			prefix = fmt.Sprintf("policies[%d]", i)
+			if names[pc.PolicyName] {
+				add(prefix+".name", fmt.Sprintf("duplicate policy name '%s'", pc.PolicyName))
+			}
+			names[pc.PolicyName] = true
+		}
+
+		for _, err := range pc.Check() {
+			key := err.Key
+			switch {
+			case prefix != "" && key == "":
+				key = prefix
+			case prefix != "" && overrides(policy, key):
+				key = joinKey(prefix, key)
+			}
+			add(key, err.Err.Error())
+		}
+	}
+	return problems
+}
+
+// overrides reports whether a policy sets the top-level setting key
+func overrides(policy PolicyConfig, key string) bool {
+	field, ok := yamlFields(reflect.TypeOf(policy))[key]
+	return ok && key != "name" && reflect.ValueOf(policy).FieldByIndex(field.Index).String() != ""
+}
+
+// Show writes the configuration with secrets redacted: as JSON next to the
+// source of each setting when format is json, and otherwise as YAML with
+// the sources as line comments
+func (l *Loader) Show(w io.Writer, format string) error {
+	redacted := l.redacted()
+	var root yaml.Node
+	if err := root.Encode(redacted); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	sources := make(map[string]string)
+	annotate(&root, "", func(key string, node *yaml.Node) {
+		source := l.Source(key).String()
+		sources[key] = source
+		node.LineComment = source
+	})
+
+	if format == OutputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(map[string]interface{}{
+			"config":  redacted,
+			"sources": sources,
+		})
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(&root)
+}
+
+// annotate calls fn with the key and node of every scalar or empty value
+// under node
+func annotate(node *yaml.Node, key string, fn func(string, *yaml.Node)) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			fn(key, node)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			annotate(node.Content[i+1], joinKey(key, node.Content[i].Value), fn)
+		}
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			fn(key, node)
+		}
+		for i, item := range node.Content {
+			annotate(item, fmt.Sprintf("%s[%d]", key, i), fn)
+		}
+	default:
+		fn(key, node)
+	}
+}
+
+// redacted returns a copy of the configuration with the defaults Validate
+// fills in applied, and passwords, tokens, webhook paths and header values
+// replaced
+func (l *Loader) redacted() *Config {
+	c := *l.Config
+	c.Notifiers = append([]NotifierConfig(nil), l.Config.Notifiers...)
+	c.Check() // Only for the defaults, Validate reports the errors
+	if u, err := url.Parse(c.ESHost); err == nil {
+		c.ESHost = u.Redacted()
+	}
+	for _, secret := range []*string{&c.Password, &c.APIToken, &c.APIPassword} {
+		if *secret != "" {
+			*secret = redactedValue
+		}
+	}
+
+	for i, notifier := range c.Notifiers {
+		// Webhook URLs such as Slack's carry the token in the path
+		if u, err := url.Parse(notifier.URL); err == nil && u.Host != "" {
+			notifier.URL = fmt.Sprintf("%s://%s/%s", u.Scheme, u.Host, redactedValue)
+		} else if notifier.URL != "" {
+			notifier.URL = redactedValue
+		}
+		if notifier.Headers != nil {
+			headers := make(map[string]string, len(notifier.Headers))
+			for name := range notifier.Headers {
+				headers[name] = redactedValue
+			}
+			notifier.Headers = headers
+		}
+		c.Notifiers[i] = notifier
+	}
+	return &c
+}
+
+// PrintProblems prints one line per problem and returns the number printed
+func PrintProblems(w io.Writer, problems []Problem) int {
+	for _, problem := range problems {
+		fmt.Fprintln(w, problem)
+	}
+	if len(problems) == 0 {
+		fmt.Fprintln(w, "Configuration is valid")
+	} else {
+		fmt.Fprintf(w, "%d problems found\n", len(problems))
+	}
+	return len(problems)
+}
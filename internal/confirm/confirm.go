@@ -0,0 +1,236 @@
+package confirm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/company/log-trimmer/internal/elasticsearch"
+	"github.com/company/log-trimmer/pkg/utils"
+)
+
+// ErrNotConfirmed is returned when the operator declines the plan
+var ErrNotConfirmed = errors.New("deletion not confirmed")
+
+// ErrNotInteractive is returned when confirmation is required but stdin is
+// not a terminal
+var ErrNotInteractive = errors.New("refusing to delete without confirmation: stdin is not a terminal, pass --yes to skip the prompt")
+
+// confirmColumns are the table columns printed for the plan under review
+var confirmColumns = []string{"#", "DELETE", "INDEX", "CREATED", "TOTAL SIZE", "PRIMARY SIZE", "DOCS", "SHARDS", "REASON"}
+
+// confirmWidths are the column widths used when printing the plan
+var confirmWidths = []int{4, 6, 40, 20, 12, 12, 10, 8, 16}
+
+// Prompt asks the operator to review and confirm a deletion plan
+type Prompt struct {
+	In          io.Reader
+	Out         io.Writer
+	Interactive bool // Whether In is a terminal
+}
+
+// New returns a prompt reading from stdin and writing to stdout
+func New() *Prompt {
+	return &Prompt{
+		In:          os.Stdin,
+		Out:         os.Stdout,
+		Interactive: isTerminal(os.Stdin),
+	}
+}
+
+// isTerminal reports whether f is a character device such as a TTY
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// reductionColumns are the table columns printed for replica reductions
+// under review
+var reductionColumns = []string{"#", "APPLY", "INDEX", "REPLICAS", "TOTAL SIZE", "PRIMARY SIZE", "RECLAIMED"}
+
+// reductionWidths are the column widths used when printing reductions
+var reductionWidths = []int{4, 6, 40, 10, 12, 12, 12}
+
+// Confirm shows the plan, lets the operator uncheck individual deletions
+// and replica reductions and then requires them to type the cluster name.
+// It returns a copy of the plan holding only the actions that remain
+// selected. With AssumeYes set the plan is returned unchanged.
+func (p *Prompt) Confirm(client *elasticsearch.Client, plan *elasticsearch.Plan) (*elasticsearch.Plan, error) {
+	if client.Config.AssumeYes || !plan.HasActions() {
+		return plan, nil
+	}
+	if !p.Interactive {
+		return nil, ErrNotInteractive
+	}
+
+	health, err := client.GetClusterHealth()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster name for confirmation: %w", err)
+	}
+
+	// Deletions are numbered first, followed by the replica reductions
+	selected := make([]bool, len(plan.ToDelete)+len(plan.Result.Reductions))
+	for i := range selected {
+		selected[i] = true
+	}
+
+	reader := bufio.NewReader(p.In)
+	p.printPlan(plan, selected)
+	for {
+		fmt.Fprint(p.Out, "Enter numbers or ranges to toggle (e.g. 2 5-7), 'list' to show the plan, or press Enter to continue: ")
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return nil, ErrNotConfirmed
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if line == "list" {
+			p.printPlan(plan, selected)
+			continue
+		}
+		if err := toggle(selected, line); err != nil {
+			fmt.Fprintf(p.Out, "%v\n", err)
+			continue
+		}
+		p.printSummary(plan, selected)
+	}
+
+	chosen := chosenPlan(plan, selected)
+	if !chosen.HasActions() {
+		fmt.Fprintln(p.Out, "Nothing selected, no changes made.")
+		return nil, ErrNotConfirmed
+	}
+
+	action := fmt.Sprintf("delete %d indexes", len(chosen.ToDelete))
+	if len(chosen.Result.Reductions) > 0 {
+		action += fmt.Sprintf(" and drop the replicas of %d", len(chosen.Result.Reductions))
+	}
+	fmt.Fprintf(p.Out, "Type the cluster name (%s) to %s: ", health.ClusterName, action)
+	answer, _ := reader.ReadString('\n')
+	if strings.TrimSpace(answer) != health.ClusterName {
+		return nil, ErrNotConfirmed
+	}
+
+	return chosen, nil
+}
+
+// chosenPlan copies plan keeping only the selected deletions and replica
+// reductions
+func chosenPlan(plan *elasticsearch.Plan, selected []bool) *elasticsearch.Plan {
+	chosen := *plan
+	chosen.ToDelete = nil
+	chosen.Result.Reductions = nil
+	chosen.Result.ReducedSize = 0
+
+	for i, index := range plan.ToDelete {
+		if selected[i] {
+			chosen.ToDelete = append(chosen.ToDelete, index)
+		}
+	}
+	offset := len(plan.ToDelete)
+	for i, reduction := range plan.Result.Reductions {
+		if selected[offset+i] {
+			chosen.Result.Reductions = append(chosen.Result.Reductions, reduction)
+			chosen.Result.ReducedSize += reduction.ReclaimedBytes
+		}
+	}
+	chosen.Result.ToDelete = len(chosen.ToDelete)
+	return &chosen
+}
+
+// checkbox renders a selection state
+func checkbox(selected bool) string {
+	if selected {
+		return "[x]"
+	}
+	return "[ ]"
+}
+
+// printPlan prints the numbered deletions and replica reductions with each
+// one's selection state
+func (p *Prompt) printPlan(plan *elasticsearch.Plan, selected []bool) {
+	if len(plan.ToDelete) > 0 {
+		utils.FprintTableHeader(p.Out, confirmColumns, confirmWidths)
+		for i, index := range plan.ToDelete {
+			row := append([]string{strconv.Itoa(i + 1), checkbox(selected[i])}, elasticsearch.PlanRow(index, plan.Result.Reasons[index.Name])...)
+			utils.FprintTableRow(p.Out, row, confirmWidths)
+		}
+		utils.FprintTableFooter(p.Out, confirmWidths)
+	}
+
+	if len(plan.Result.Reductions) > 0 {
+		offset := len(plan.ToDelete)
+		utils.FprintTableHeader(p.Out, reductionColumns, reductionWidths)
+		for i, reduction := range plan.Result.Reductions {
+			n := offset + i
+			row := append([]string{strconv.Itoa(n + 1), checkbox(selected[n])}, elasticsearch.ReductionRow(reduction)...)
+			utils.FprintTableRow(p.Out, row, reductionWidths)
+		}
+		utils.FprintTableFooter(p.Out, reductionWidths)
+	}
+	p.printSummary(plan, selected)
+}
+
+// printSummary prints the number and size of the selected deletions and
+// replica reductions
+func (p *Prompt) printSummary(plan *elasticsearch.Plan, selected []bool) {
+	var count int
+	var bytes int64
+	for i, index := range plan.ToDelete {
+		if selected[i] {
+			count++
+			bytes += index.SizeBytes
+		}
+	}
+	fmt.Fprintf(p.Out, "Selected: %d of %d indexes, %s", count, len(plan.ToDelete), utils.FormatBytes(bytes))
+
+	if len(plan.Result.Reductions) > 0 {
+		count, bytes = 0, 0
+		offset := len(plan.ToDelete)
+		for i, reduction := range plan.Result.Reductions {
+			if selected[offset+i] {
+				count++
+				bytes += reduction.ReclaimedBytes
+			}
+		}
+		fmt.Fprintf(p.Out, "; %d of %d replica reductions, %s reclaimed", count, len(plan.Result.Reductions), utils.FormatBytes(bytes))
+	}
+	fmt.Fprintln(p.Out)
+}
+
+// toggle flips the selection of the 1-based positions and ranges in input
+func toggle(selected []bool, input string) error {
+	var positions []int
+	for _, field := range strings.Fields(strings.ReplaceAll(input, ",", " ")) {
+		start, end := field, field
+		if i := strings.Index(field, "-"); i > 0 {
+			start, end = field[:i], field[i+1:]
+		}
+
+		from, err := strconv.Atoi(start)
+		if err != nil {
+			return fmt.Errorf("invalid selection '%s'", field)
+		}
+		to, err := strconv.Atoi(end)
+		if err != nil || to < from || from < 1 || to > len(selected) {
+			return fmt.Errorf("invalid selection '%s': must be between 1 and %d", field, len(selected))
+		}
+		for n := from; n <= to; n++ {
+			positions = append(positions, n-1)
+		}
+	}
+
+	for _, position := range positions {
+		selected[position] = !selected[position]
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package confirm
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/elasticsearch"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+func newTestClient(t *testing.T, assumeYes bool) *elasticsearch.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"cluster_name":"prod-logs","status":"green"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{ESHost: server.URL, AssumeYes: assumeYes}
+	log, _ := logger.New(logger.DefaultConfig())
+	return elasticsearch.NewClient(cfg, log)
+}
+
+// newPlan returns a plan deleting four indexes
+func newPlan() *elasticsearch.Plan {
+	return &elasticsearch.Plan{ToDelete: []elasticsearch.IndexInfo{{Name: "logs-1"}, {Name: "logs-2"}, {Name: "logs-3"}, {Name: "logs-4"}}}
+}
+
+func TestConfirmTogglesAndRequiresClusterName(t *testing.T) {
+	client := newTestClient(t, false)
+	prompt := &Prompt{
+		In:          strings.NewReader("2\n3-4\n4\n\nprod-logs\n"),
+		Out:         &bytes.Buffer{},
+		Interactive: true,
+	}
+
+	chosen, err := prompt.Confirm(client, newPlan())
+	if err != nil {
+		t.Fatalf("Expected confirmation to succeed, got %v", err)
+	}
+	if len(chosen.ToDelete) != 2 || chosen.ToDelete[0].Name != "logs-1" || chosen.ToDelete[1].Name != "logs-4" {
+		t.Errorf("Expected logs-1 and logs-4 to remain selected, got %+v", chosen.ToDelete)
+	}
+}
+
+func TestConfirmRejectsWrongClusterName(t *testing.T) {
+	client := newTestClient(t, false)
+	prompt := &Prompt{In: strings.NewReader("\nstaging\n"), Out: &bytes.Buffer{}, Interactive: true}
+
+	if _, err := prompt.Confirm(client, newPlan()); !errors.Is(err, ErrNotConfirmed) {
+		t.Errorf("Expected ErrNotConfirmed, got %v", err)
+	}
+}
+
+func TestConfirmWithoutTerminal(t *testing.T) {
+	prompt := &Prompt{In: strings.NewReader(""), Out: &bytes.Buffer{}}
+
+	if _, err := prompt.Confirm(newTestClient(t, false), newPlan()); !errors.Is(err, ErrNotInteractive) {
+		t.Errorf("Expected ErrNotInteractive, got %v", err)
+	}
+
+	plan := newPlan()
+	chosen, err := prompt.Confirm(newTestClient(t, true), plan)
+	if err != nil || chosen != plan {
+		t.Errorf("Expected --yes to keep the whole plan, got %+v and %v", chosen, err)
+	}
+}
+
+func TestConfirmReplicaReductions(t *testing.T) {
+	client := newTestClient(t, false)
+	out := &bytes.Buffer{}
+	prompt := &Prompt{In: strings.NewReader("6\n\nprod-logs\n"), Out: out, Interactive: true}
+
+	plan := newPlan()
+	plan.Result.Reductions = []elasticsearch.ReplicaReduction{
+		{Index: "logs-5", Replicas: 1, ReclaimedBytes: 100},
+		{Index: "logs-6", Replicas: 1, ReclaimedBytes: 200},
+	}
+	plan.Result.ReducedSize = 300
+
+	chosen, err := prompt.Confirm(client, plan)
+	if err != nil {
+		t.Fatalf("Expected confirmation to succeed, got %v", err)
+	}
+	if len(chosen.ToDelete) != 4 {
+		t.Errorf("Expected all deletions to remain selected, got %+v", chosen.ToDelete)
+	}
+	if len(chosen.Result.Reductions) != 1 || chosen.Result.Reductions[0].Index != "logs-5" || chosen.Result.ReducedSize != 100 {
+		t.Errorf("Expected only the logs-5 reduction to remain, got %+v", chosen.Result.Reductions)
+	}
+	if len(plan.Result.Reductions) != 2 {
+		t.Error("Expected the original plan to be left unchanged")
+	}
+	if !strings.Contains(out.String(), "logs-6") || !strings.Contains(out.String(), "RECLAIMED") {
+		t.Errorf("Expected the plan tables to be written to the prompt output, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "1 of 2 replica reductions") {
+		t.Errorf("Expected the summary to count replica reductions, got:\n%s", out.String())
+	}
+}
+
+func TestToggleRejectsOutOfRange(t *testing.T) {
+	selected := []bool{true, true}
+	if err := toggle(selected, "3"); err == nil {
+		t.Error("Expected error for position out of range")
+	}
+	if err := toggle(selected, "x"); err == nil {
+		t.Error("Expected error for non-numeric selection")
+	}
+	if !selected[0] || !selected[1] {
+		t.Error("Expected selection to be unchanged after an invalid toggle")
+	}
+}
@@ -0,0 +1,355 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/elasticsearch"
+	"github.com/company/log-trimmer/internal/logger"
+	"github.com/company/log-trimmer/internal/notify"
+	"github.com/company/log-trimmer/internal/scheduler"
+)
+
+// job is a scheduled policy
+type job struct {
+	cfg      *config.Config
+	schedule scheduler.Schedule
+	next     time.Time
+}
+
+// Daemon runs the plan/apply cycle of every policy on its schedule, sharing
+// a single Elasticsearch client. Cycles never overlap.
+type Daemon struct {
+	ConfigPath string
+	Client     *elasticsearch.Client
+	Logger     *logger.Logger
+
+	// OnCycle, when set, is called after every completed cycle
+	OnCycle func(plan *elasticsearch.Plan, report *elasticsearch.DeletionReport, err error)
+
+	cycleMu sync.Mutex // Held while a cycle runs
+	jobsMu  sync.Mutex // Guards jobs
+	jobs    []*job
+	reload  chan struct{}
+}
+
+// New creates a daemon for the policies in cfg. configPath is re-read on
+// Reload; it may be empty when configuration only comes from the
+// environment.
+func New(cfg *config.Config, configPath string, client *elasticsearch.Client, log *logger.Logger) (*Daemon, error) {
+	d := &Daemon{
+		ConfigPath: configPath,
+		Client:     client,
+		Logger:     log,
+		reload:     make(chan struct{}, 1),
+	}
+
+	jobs, err := buildJobs(cfg, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	d.jobs = jobs
+
+	return d, nil
+}
+
+// buildJobs resolves the policies of cfg and schedules their first run
+func buildJobs(cfg *config.Config, now time.Time) ([]*job, error) {
+	policies, err := cfg.ResolvePolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*job
+	for _, policy := range policies {
+		if policy.Schedule == "" {
+			return nil, fmt.Errorf("policy '%s': a schedule is required in serve mode", policy.PolicyName)
+		}
+		schedule, err := scheduler.Parse(policy.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("policy '%s': %w", policy.PolicyName, err)
+		}
+
+		j := &job{cfg: policy, schedule: schedule}
+		if j.next, err = nextRun(j, now); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
+
+// nextRun returns the next activation of a job, including jitter. A
+// schedule with no further activation is an error, never a reason to run
+// straight away.
+func nextRun(j *job, now time.Time) (time.Time, error) {
+	next := j.schedule.Next(now)
+	if next.IsZero() {
+		return next, fmt.Errorf("policy '%s': schedule '%s' has no activation after %s", j.cfg.PolicyName, j.cfg.Schedule, now.Format(time.RFC3339))
+	}
+	if j.cfg.JitterDuration > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(j.cfg.JitterDuration))))
+	}
+	return next, nil
+}
+
+// Serve runs the daemon until SIGTERM or SIGINT. SIGHUP reloads the
+// configuration file without restarting. On shutdown the running cycle is
+// cancelled after its in-flight deletion completes.
+func (d *Daemon) Serve() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGHUP {
+				d.Logger.Info("daemon", "signal", "Received SIGHUP, reloading configuration")
+				d.RequestReload()
+				continue
+			}
+			d.Logger.Info("daemon", "signal", "Received shutdown signal, finishing in-flight work", map[string]interface{}{
+				"signal": sig.String(),
+			})
+			cancel()
+			return
+		}
+	}()
+
+	return d.Run(ctx)
+}
+
+// RequestReload asks the run loop to reload the configuration file
+func (d *Daemon) RequestReload() {
+	select {
+	case d.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Run executes scheduled cycles until ctx is cancelled
+func (d *Daemon) Run(ctx context.Context) error {
+	d.Logger.Info("daemon", "start", "Starting scheduler", map[string]interface{}{
+		"count": len(d.jobs),
+	})
+
+	for {
+		j := d.nextJob()
+		if j == nil {
+			return fmt.Errorf("no policy has a scheduled run left")
+		}
+		wait := time.Until(j.next)
+		d.Logger.Debug("daemon", "schedule", "Waiting for next cycle", map[string]interface{}{
+			"policy":   j.cfg.PolicyName,
+			"next_run": j.next,
+		})
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			d.Logger.Info("daemon", "stop", "Scheduler stopped")
+			return nil
+		case <-d.reload:
+			timer.Stop()
+			if err := d.Reload(); err != nil {
+				d.Logger.Error("daemon", "reload", "Failed to reload configuration, keeping previous settings", err)
+			}
+			continue
+		case <-timer.C:
+		}
+
+		d.RunCycle(ctx, j.cfg)
+
+		// Schedule from the completion time so a slow cycle skips missed
+		// activations instead of running back to back
+		next, err := nextRun(j, time.Now())
+		if err != nil {
+			d.Logger.Error("daemon", "schedule", "No further runs scheduled for policy", err, map[string]interface{}{
+				"policy": j.cfg.PolicyName,
+			})
+		}
+		d.jobsMu.Lock()
+		j.next = next
+		d.jobsMu.Unlock()
+	}
+}
+
+// nextJob returns the job due soonest, or nil when no job has a run
+// scheduled. Jobs whose schedule has run out keep a zero next run.
+func (d *Daemon) nextJob() *job {
+	d.jobsMu.Lock()
+	defer d.jobsMu.Unlock()
+
+	var next *job
+	for _, j := range d.jobs {
+		if j.next.IsZero() {
+			continue
+		}
+		if next == nil || j.next.Before(next.next) {
+			next = j
+		}
+	}
+	return next
+}
+
+// Reload re-reads the configuration file and the environment and replaces
+// the scheduled policies. The old policies stay in place if the new
+// configuration is invalid.
+func (d *Daemon) Reload() error {
+	cfg := config.DefaultConfig()
+	if d.ConfigPath != "" {
+		if err := cfg.LoadFromFile(d.ConfigPath); err != nil {
+			return err
+		}
+	}
+	cfg.LoadFromEnv()
+
+	jobs, err := buildJobs(cfg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	d.jobsMu.Lock()
+	d.jobs = jobs
+	d.jobsMu.Unlock()
+
+	d.Logger.Success("daemon", "reload", "Configuration reloaded", map[string]interface{}{
+		"count": len(jobs),
+	})
+	return nil
+}
+
+// CycleLock returns the lock held while a cycle runs, so other callers such
+// as the control API can avoid overlapping with scheduled cycles
+func (d *Daemon) CycleLock() sync.Locker {
+	return &d.cycleMu
+}
+
+// Policies returns the currently scheduled policy configurations
+func (d *Daemon) Policies() []*config.Config {
+	d.jobsMu.Lock()
+	defer d.jobsMu.Unlock()
+
+	policies := make([]*config.Config, len(d.jobs))
+	for i, j := range d.jobs {
+		policies[i] = j.cfg
+	}
+	return policies
+}
+
+// RunCycle builds the plan for one policy and applies it when deletion is
+// enabled. Cycles are serialised, so a call waits for any running cycle.
+func (d *Daemon) RunCycle(ctx context.Context, cfg *config.Config) (*elasticsearch.Plan, *elasticsearch.DeletionReport, error) {
+	d.cycleMu.Lock()
+	defer d.cycleMu.Unlock()
+
+	// A cycle that outlasts its maintenance window resumes in the next one
+	client := d.Client.WithConfig(cfg)
+	client.PauseOutsideWindow = true
+	d.Logger.Info("daemon", "cycle", "Starting cycle", map[string]interface{}{
+		"policy":  cfg.PolicyName,
+		"pattern": cfg.IndexPattern,
+	})
+
+	start := time.Now()
+	var plan *elasticsearch.Plan
+	var report *elasticsearch.DeletionReport
+	err := client.WithRunLock(ctx, func(ctx context.Context) error {
+		var err error
+		plan, err = client.BuildPlan()
+		if err != nil {
+			return err
+		}
+
+		if cfg.DeleteIndexes && plan.HasActions() {
+			report = client.ApplyPlan(ctx, plan)
+		} else {
+			client.Metrics.RecordRun(true, time.Now())
+		}
+		return nil
+	})
+	if err != nil {
+		var held *elasticsearch.LockHeldError
+		if errors.As(err, &held) {
+			d.Logger.Warn("daemon", "cycle", "Skipping cycle, run lock is held elsewhere", map[string]interface{}{
+				"policy": cfg.PolicyName,
+				"holder": held.Holder.Owner,
+				"host":   held.Holder.Host,
+			})
+		} else {
+			d.Logger.Error("daemon", "cycle", "Cycle failed", err, map[string]interface{}{
+				"policy": cfg.PolicyName,
+			})
+			client.Metrics.RecordRun(false, time.Now())
+			d.audit(client, start, plan, report, err)
+			d.sendNotifications(cfg, plan, report, err)
+		}
+		d.notify(plan, report, err)
+		return plan, report, err
+	}
+
+	d.Logger.Info("daemon", "cycle", "Cycle complete", map[string]interface{}{
+		"policy":            cfg.PolicyName,
+		"indexes_to_delete": len(plan.ToDelete),
+		"dry_run":           !cfg.DeleteIndexes,
+	})
+
+	if cfg.MetricsTextfile != "" {
+		if err := client.Metrics.WriteTextfile(cfg.MetricsTextfile); err != nil {
+			d.Logger.Warn("daemon", "metrics", "Failed to write metrics textfile", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	d.audit(client, start, plan, report, nil)
+	d.sendNotifications(cfg, plan, report, nil)
+	d.notify(plan, report, nil)
+	return plan, report, nil
+}
+
+// audit writes the audit record of a cycle; failures are logged but do not
+// fail the cycle
+func (d *Daemon) audit(client *elasticsearch.Client, start time.Time, plan *elasticsearch.Plan, report *elasticsearch.DeletionReport, runErr error) {
+	record := client.NewAuditRecord("", start, plan, report, runErr)
+	if err := client.WriteAuditRecord(record); err != nil {
+		d.Logger.Warn("daemon", "audit", "Failed to write audit record", map[string]interface{}{
+			"run_id": record.RunID,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// sendNotifications delivers the cycle's events to the policy's webhooks.
+// Delivery uses its own context so a shutdown still reports the final cycle.
+func (d *Daemon) sendNotifications(cfg *config.Config, plan *elasticsearch.Plan, report *elasticsearch.DeletionReport, runErr error) {
+	notifiers, err := notify.NewSet(cfg, d.Logger)
+	if err != nil {
+		d.Logger.Warn("daemon", "notify", "Failed to set up notifiers", map[string]interface{}{
+			"policy": cfg.PolicyName,
+			"error":  err.Error(),
+		})
+		return
+	}
+	notifiers.Notify(context.Background(), cfg, plan, report, runErr)
+}
+
+// notify invokes the OnCycle hook if one is set
+func (d *Daemon) notify(plan *elasticsearch.Plan, report *elasticsearch.DeletionReport, err error) {
+	if d.OnCycle != nil {
+		d.OnCycle(plan, report, err)
+	}
+}
@@ -0,0 +1,220 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/elasticsearch"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+// fastSchedule fires a few milliseconds after each activation
+type fastSchedule struct{}
+
+func (fastSchedule) Next(after time.Time) time.Time {
+	return after.Add(5 * time.Millisecond)
+}
+
+// exhaustedSchedule never fires again
+type exhaustedSchedule struct{}
+
+func (exhaustedSchedule) Next(after time.Time) time.Time {
+	return time.Time{}
+}
+
+func newTestServer(t *testing.T, deletes *int32) *httptest.Server {
+	old := time.Now().Add(-30*24*time.Hour).UnixNano() / int64(time.Millisecond)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "DELETE":
+			atomic.AddInt32(deletes, 1)
+			w.Write([]byte(`{"acknowledged":true}`))
+		case strings.HasPrefix(r.URL.Path, "/_cat/indices/"):
+			w.Write([]byte(`[{"index":"logs-old","store.size":"100","pri.store.size":"50"}]`))
+		case r.URL.Path == "/":
+			w.Write([]byte(`{"cluster_name":"test","version":{"number":"8.11.0"}}`))
+		case r.URL.Path == "/_data_stream/*":
+			w.Write([]byte(`{"data_streams":[]}`))
+		case strings.HasSuffix(r.URL.Path, "/_settings"):
+			fmt.Fprintf(w, `{"logs-old":{"settings":{"index":{"creation_date":"%d"}}}}`, old)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func newTestConfig(host string) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.ESHost = host
+	cfg.IndexPattern = "logs-*"
+	cfg.MaxAge = "7d"
+	cfg.Schedule = "@every 1h"
+	return cfg
+}
+
+func TestRunCycle(t *testing.T) {
+	var deletes int32
+	server := newTestServer(t, &deletes)
+	defer server.Close()
+
+	cfg := newTestConfig(server.URL)
+	cfg.DeleteIndexes = true
+	log, _ := logger.New(logger.DefaultConfig())
+	client := elasticsearch.NewClient(cfg, log)
+
+	d, err := New(cfg, "", client, log)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	plan, report, err := d.RunCycle(context.Background(), d.jobs[0].cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plan.ToDelete) != 1 || report == nil || report.Deleted != 1 {
+		t.Fatalf("Expected one index to be deleted, got plan %d / report %+v", len(plan.ToDelete), report)
+	}
+	if atomic.LoadInt32(&deletes) != 1 {
+		t.Errorf("Expected one DELETE request, got %d", deletes)
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	var deletes int32
+	server := newTestServer(t, &deletes)
+	defer server.Close()
+
+	cfg := newTestConfig(server.URL)
+	log, _ := logger.New(logger.DefaultConfig())
+	client := elasticsearch.NewClient(cfg, log)
+
+	d, err := New(cfg, "", client, log)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	d.jobs[0].schedule = fastSchedule{}
+	d.jobs[0].next = time.Now()
+
+	var cycles int32
+	var running int32
+	ctx, cancel := context.WithCancel(context.Background())
+	d.OnCycle = func(plan *elasticsearch.Plan, report *elasticsearch.DeletionReport, err error) {
+		if atomic.AddInt32(&running, 1) > 1 {
+			t.Error("Cycles overlapped")
+		}
+		if atomic.AddInt32(&cycles, 1) == 3 {
+			cancel()
+		}
+		atomic.AddInt32(&running, -1)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Scheduler did not stop after cancellation")
+	}
+
+	if atomic.LoadInt32(&cycles) < 3 {
+		t.Errorf("Expected at least 3 cycles, got %d", cycles)
+	}
+	if atomic.LoadInt32(&deletes) != 0 {
+		t.Errorf("Expected dry-run cycles not to delete, got %d deletions", deletes)
+	}
+}
+
+func TestReload(t *testing.T) {
+	log, _ := logger.New(logger.DefaultConfig())
+	cfg := newTestConfig("https://localhost:9200")
+	client := elasticsearch.NewClient(cfg, log)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `es_host: https://localhost:9200
+max_age: 7d
+schedule: "0 3 * * *"
+policies:
+  - name: app
+    index_pattern: app-*
+  - name: audit
+    index_pattern: audit-*
+    max_age: 90d
+    schedule: "@every 6h"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	d, err := New(cfg, path, client, log)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := d.Reload(); err != nil {
+		t.Fatalf("Unexpected reload error: %v", err)
+	}
+	if len(d.jobs) != 2 || d.jobs[1].cfg.MaxAgeDuration != 90*24*time.Hour {
+		t.Fatalf("Expected 2 policies with audit overriding max age, got %d", len(d.jobs))
+	}
+
+	// An invalid file keeps the previous policies
+	os.WriteFile(path, []byte("es_host: https://localhost:9200\nmax_age: 7d\nschedule: nonsense\n"), 0644)
+	if err := d.Reload(); err == nil {
+		t.Error("Expected invalid schedule to fail reload")
+	}
+	if len(d.jobs) != 2 {
+		t.Errorf("Expected previous policies to be kept, got %d", len(d.jobs))
+	}
+}
+
+func TestRunRefusesExhaustedSchedule(t *testing.T) {
+	var deletes int32
+	server := newTestServer(t, &deletes)
+	defer server.Close()
+
+	cfg := newTestConfig(server.URL)
+	log, _ := logger.New(logger.DefaultConfig())
+	d, err := New(cfg, "", elasticsearch.NewClient(cfg, log), log)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	j := d.jobs[0]
+	j.schedule = exhaustedSchedule{}
+	if _, err := nextRun(j, time.Now()); err == nil {
+		t.Error("Expected an error for a schedule without further runs")
+	}
+
+	var cycles int32
+	d.OnCycle = func(*elasticsearch.Plan, *elasticsearch.DeletionReport, error) {
+		atomic.AddInt32(&cycles, 1)
+	}
+	j.next = time.Now()
+
+	done := make(chan error, 1)
+	go func() { done <- d.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected Run to fail once no policy has a run left")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run kept cycling on an exhausted schedule")
+	}
+	if n := atomic.LoadInt32(&cycles); n != 1 {
+		t.Errorf("Expected exactly one cycle, got %d", n)
+	}
+}
@@ -0,0 +1,182 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/company/log-trimmer/internal/ratelimit"
+	"github.com/company/log-trimmer/pkg/utils"
+)
+
+// DeletionOutcome records the result of deleting a single index
+type DeletionOutcome struct {
+	Index     string        `json:"index"`
+	SizeBytes int64         `json:"size_bytes"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+	Throttled time.Duration `json:"throttled,omitempty"` // Rate limit delay before this deletion
+	Action    string        `json:"action,omitempty"`    // ActionReduceReplicas, or empty for a deletion
+}
+
+// DeletionReport summarises a deletion run
+type DeletionReport struct {
+	StartTime    time.Time         `json:"start_time"`
+	EndTime      time.Time         `json:"end_time"`
+	Outcomes     []DeletionOutcome `json:"outcomes"`
+	Deleted      int               `json:"deleted"`
+	Failed       int               `json:"failed"`
+	DeletedBytes int64             `json:"deleted_bytes"`
+	NotAttempted []string          `json:"not_attempted,omitempty"`
+	BlockedBy    []string          `json:"blocked_by,omitempty"`
+	Aborted      bool              `json:"aborted"`
+	Throttled    time.Duration     `json:"throttled"`          // Total rate limit delay
+	Deferred     bool              `json:"deferred,omitempty"` // Stopped because no maintenance window was open
+	Marked       []string          `json:"marked,omitempty"`   // Soft delete: newly marked for deletion
+	Pending      []string          `json:"pending,omitempty"`  // Soft delete: marked, grace period not over
+	Reduced      int               `json:"reduced,omitempty"`  // Indexes whose replicas were dropped
+	ReducedBytes int64             `json:"reduced_bytes,omitempty"`
+}
+
+// DeleteIndexes deletes the given indexes in order, waiting for the
+// configured rate limits and checking the maintenance windows and cluster
+// health gate before each deletion. Failed deletions are recorded and the
+// run continues; a failing health gate or a cancelled context stops the run
+// and leaves the rest of the plan untouched, and a closed maintenance
+// window defers it. A deletion already in flight is always allowed to
+// finish.
+func (c *Client) DeleteIndexes(ctx context.Context, indexes []IndexInfo) *DeletionReport {
+	report := &DeletionReport{StartTime: time.Now()}
+	limiter := ratelimit.New(c.Config.MaxDeletesPerMinute, c.Config.MaxBytesPerMinuteBytes, c.Config.DeletePauseDuration)
+
+	for i, index := range indexes {
+		var throttled time.Duration
+		err := ctx.Err()
+		if err == nil {
+			throttled, err = limiter.Wait(ctx, index.SizeBytes)
+			if throttled > 0 {
+				report.Throttled += throttled
+				c.Logger.Debug("elasticsearch", "delete_indexes", "Throttled deletion", map[string]interface{}{
+					"index": index.Name,
+					"delay": throttled.String(),
+				})
+			}
+		}
+		if err == nil {
+			err = c.waitForWindow(ctx, i > 0)
+		}
+		if err == nil {
+			err = c.WaitForHealthGate(ctx)
+		}
+		if err != nil {
+			report.block(err)
+			for _, remaining := range indexes[i:] {
+				report.NotAttempted = append(report.NotAttempted, remaining.Name)
+			}
+			break
+		}
+
+		start := time.Now()
+		err = c.DeleteIndex(index.Name)
+		limiter.Done()
+		outcome := DeletionOutcome{
+			Index:     index.Name,
+			SizeBytes: index.SizeBytes,
+			Duration:  time.Since(start),
+			Throttled: throttled,
+		}
+		if err != nil {
+			outcome.Error = err.Error()
+			report.Failed++
+			c.Metrics.AddDeletionFailure()
+		} else {
+			report.Deleted++
+			report.DeletedBytes += index.SizeBytes
+			c.Metrics.AddDeleted(index.SizeBytes)
+		}
+		report.Outcomes = append(report.Outcomes, outcome)
+	}
+
+	report.EndTime = time.Now()
+	c.Metrics.RecordRun(report.Failed == 0 && !report.Aborted, report.EndTime)
+
+	c.Logger.Info("elasticsearch", "delete_indexes", "Deletion run finished", map[string]interface{}{
+		"deleted":       report.Deleted,
+		"failed":        report.Failed,
+		"deleted_bytes": report.DeletedBytes,
+		"not_attempted": len(report.NotAttempted),
+		"aborted":       report.Aborted,
+		"throttled":     report.Throttled.String(),
+		"deferred":      report.Deferred,
+	})
+
+	return report
+}
+
+// block records why a run stopped before finishing: a closed maintenance
+// window defers it, anything else aborts it
+func (r *DeletionReport) block(err error) {
+	var gateErr *HealthGateError
+	var windowErr *WindowClosedError
+	switch {
+	case errors.As(err, &gateErr):
+		r.BlockedBy = gateErr.Conditions
+		r.Aborted = true
+	case errors.As(err, &windowErr):
+		r.BlockedBy = []string{windowErr.Error()}
+		r.Deferred = true
+	default:
+		r.BlockedBy = []string{err.Error()}
+		r.Aborted = true
+	}
+}
+
+// reportColumns are the table columns printed for each deletion outcome
+var reportColumns = []string{"INDEX", "SIZE", "DURATION", "THROTTLED", "RESULT"}
+
+// reportWidths are the column widths used by PrintReport
+var reportWidths = []int{40, 12, 10, 10, 30}
+
+// PrintReport prints the outcome of each deletion followed by a summary
+func PrintReport(report *DeletionReport) {
+	utils.PrintTableHeader(reportColumns, reportWidths)
+	for _, outcome := range report.Outcomes {
+		result := "deleted"
+		if outcome.Action == ActionReduceReplicas {
+			result = "replicas dropped"
+		}
+		if outcome.Error != "" {
+			result = "failed: " + outcome.Error
+		}
+		utils.PrintTableRow([]string{
+			outcome.Index,
+			utils.FormatBytes(outcome.SizeBytes),
+			outcome.Duration.Round(time.Millisecond).String(),
+			outcome.Throttled.Round(time.Millisecond).String(),
+			result,
+		}, reportWidths)
+	}
+	utils.PrintTableFooter(reportWidths)
+
+	fmt.Printf("Deleted: %d, failed: %d, reclaimed: %s\n", report.Deleted, report.Failed, utils.FormatBytes(report.DeletedBytes))
+	if report.Reduced > 0 {
+		fmt.Printf("Replicas dropped: %d, reclaimed: %s\n", report.Reduced, utils.FormatBytes(report.ReducedBytes))
+	}
+	if len(report.Marked) > 0 {
+		fmt.Printf("Marked for deletion: %s\n", strings.Join(report.Marked, ", "))
+	}
+	if len(report.Pending) > 0 {
+		fmt.Printf("Pending deletion (grace period): %s\n", strings.Join(report.Pending, ", "))
+	}
+	if report.Throttled > 0 {
+		fmt.Printf("Throttled by rate limits for %s\n", report.Throttled.Round(time.Second).String())
+	}
+	if report.Aborted {
+		fmt.Printf("Aborted, %d indexes not attempted: %s\n", len(report.NotAttempted), strings.Join(report.BlockedBy, "; "))
+	}
+	if report.Deferred {
+		fmt.Printf("Deferred, %d indexes not attempted: %s\n", len(report.NotAttempted), strings.Join(report.BlockedBy, "; "))
+	}
+}
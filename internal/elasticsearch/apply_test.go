@@ -0,0 +1,98 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/logger"
+	"github.com/company/log-trimmer/internal/scheduler"
+)
+
+func TestDeleteIndexesRecordsThrottling(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"acknowledged":true}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ESHost: server.URL, DeletePauseDuration: 20 * time.Millisecond}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	report := client.DeleteIndexes(context.Background(), []IndexInfo{{Name: "logs-1"}, {Name: "logs-2"}, {Name: "logs-3"}})
+	if report.Deleted != 3 {
+		t.Fatalf("Expected 3 deletions, got %+v", report)
+	}
+	if report.Outcomes[0].Throttled != 0 {
+		t.Errorf("Expected the first deletion not to be throttled, got %s", report.Outcomes[0].Throttled)
+	}
+	if report.Outcomes[1].Throttled == 0 || report.Outcomes[2].Throttled == 0 {
+		t.Errorf("Expected later deletions to be throttled, got %+v", report.Outcomes)
+	}
+	if report.Throttled < 30*time.Millisecond {
+		t.Errorf("Expected total throttling of about 40ms, got %s", report.Throttled)
+	}
+}
+
+func TestDeleteIndexesStopsWhenCancelledWhileThrottled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"acknowledged":true}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ESHost: server.URL, DeletePauseDuration: time.Hour}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	report := client.DeleteIndexes(ctx, []IndexInfo{{Name: "logs-1"}, {Name: "logs-2"}})
+	if report.Deleted != 1 || !report.Aborted || len(report.NotAttempted) != 1 {
+		t.Errorf("Expected the run to stop while waiting for the pause, got %+v", report)
+	}
+}
+
+func TestDeleteIndexesDefersOutsideWindow(t *testing.T) {
+	var deletes int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deletes++
+		w.Write([]byte(`{"acknowledged":true}`))
+	}))
+	defer server.Close()
+
+	// A one-minute window on 29 February is as good as closed
+	cfg := &config.Config{ESHost: server.URL, AllowedWindows: []string{"0 0 29 2 * 1m"}, WindowTimezone: "UTC"}
+	windows, err := scheduler.ParseWindows(cfg.AllowedWindows, cfg.WindowTimezone)
+	if err != nil {
+		t.Fatalf("Expected window to parse, got %v", err)
+	}
+	cfg.Windows = windows
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	report := client.DeleteIndexes(context.Background(), []IndexInfo{{Name: "logs-1"}, {Name: "logs-2"}})
+	if deletes != 0 || !report.Deferred || report.Aborted || len(report.NotAttempted) != 2 {
+		t.Errorf("Expected the whole run to be deferred, got %d deletes and %+v", deletes, report)
+	}
+}
+
+func TestWaitForWindowRefusesWithoutNextOpening(t *testing.T) {
+	windows, err := scheduler.ParseWindows(nil, "UTC")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(&config.Config{Windows: windows}, log)
+	client.PauseOutsideWindow = true
+
+	var closed *WindowClosedError
+	if err := client.waitForWindow(context.Background(), true); !errors.As(err, &closed) {
+		t.Errorf("Expected a WindowClosedError when no window opens again, got %v", err)
+	}
+}
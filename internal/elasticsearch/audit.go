@@ -0,0 +1,290 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/company/log-trimmer/pkg/utils"
+)
+
+// AuditRecord is the document indexed into the audit index after each run
+type AuditRecord struct {
+	RunID      string          `json:"run_id"`
+	Host       string          `json:"host"`
+	User       string          `json:"user"`
+	ConfigHash string          `json:"config_hash"`
+	Policy     string          `json:"policy"`
+	Pattern    string          `json:"pattern"`
+	DryRun     bool            `json:"dry_run"`
+	StartTime  time.Time       `json:"start_time"`
+	EndTime    time.Time       `json:"end_time"`
+	DurationMs int64           `json:"duration_ms"`
+	Plan       AuditPlan       `json:"plan"`
+	Deletions  []AuditDeletion `json:"deletions,omitempty"`
+	Deleted    int             `json:"deleted"`
+	Failed     int             `json:"failed"`
+	Aborted    bool            `json:"aborted"`
+	BlockedBy  []string        `json:"blocked_by,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// AuditPlan is the deletion plan as stored in an audit record. Indexes are
+// stored as a list rather than keyed by name so the audit index mapping
+// stays fixed.
+type AuditPlan struct {
+	ID           string             `json:"id"`
+	CreatedAt    time.Time          `json:"created_at"`
+	Indexes      []AuditIndex       `json:"indexes"`
+	TotalIndexes int                `json:"total_indexes"`
+	TotalSize    int64              `json:"total_size"`
+	DeletedSize  int64              `json:"deleted_size"`
+	SizeBasis    string             `json:"size_basis"`
+	Skipped      []SkippedIndex     `json:"skipped,omitempty"`
+	Uncertain    []string           `json:"uncertain,omitempty"`
+	Reductions   []ReplicaReduction `json:"reductions,omitempty"`
+}
+
+// AuditIndex describes one index selected by the plan
+type AuditIndex struct {
+	Name          string    `json:"name"`
+	UUID          string    `json:"uuid"`
+	SizeBytes     int64     `json:"size_bytes"`
+	PrimaryBytes  int64     `json:"primary_bytes"`
+	DocsCount     int64     `json:"docs_count"`
+	CreationDate  time.Time `json:"creation_date"`
+	Reasons       []string  `json:"reasons"`
+	SizeUncertain bool      `json:"size_uncertain,omitempty"`
+}
+
+// AuditDeletion records the outcome of one DeleteIndex call or replica
+// reduction
+type AuditDeletion struct {
+	Index        string    `json:"index"`
+	UUID         string    `json:"uuid"`
+	SizeBytes    int64     `json:"size_bytes"`
+	DocsCount    int64     `json:"docs_count"`
+	CreationDate time.Time `json:"creation_date"`
+	Error        string    `json:"error,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+	Action       string    `json:"action,omitempty"` // ActionReduceReplicas, or empty for a deletion
+}
+
+// currentUser returns the name of the user running the process
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// NewAuditRecord builds the audit record for a run. report is nil for dry
+// runs and for runs that failed before deleting; runErr is set when the run
+// failed before or during deletion. A run ID is generated when runID is
+// empty.
+func (c *Client) NewAuditRecord(runID string, start time.Time, plan *Plan, report *DeletionReport, runErr error) *AuditRecord {
+	if runID == "" {
+		runID = newPlanID()
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	record := &AuditRecord{
+		RunID:      runID,
+		Host:       host,
+		User:       currentUser(),
+		ConfigHash: c.Config.Hash(),
+		Policy:     c.Config.PolicyName,
+		Pattern:    c.Config.IndexPattern,
+		DryRun:     !c.Config.DeleteIndexes,
+		StartTime:  start,
+		EndTime:    time.Now(),
+	}
+	if record.Policy == "" {
+		record.Policy = c.Config.IndexPattern
+	}
+	record.DurationMs = record.EndTime.Sub(start).Milliseconds()
+	if runErr != nil {
+		record.Error = runErr.Error()
+	}
+
+	indexes := make(map[string]IndexInfo)
+	if plan != nil {
+		record.Plan = AuditPlan{
+			ID:           plan.ID,
+			CreatedAt:    plan.CreatedAt,
+			TotalIndexes: plan.Result.TotalIndexes,
+			TotalSize:    plan.Result.TotalSize,
+			DeletedSize:  plan.Result.DeletedSize,
+			SizeBasis:    plan.Result.SizeBasis,
+			Skipped:      plan.Result.Skipped,
+			Uncertain:    plan.Result.Uncertain,
+			Reductions:   plan.Result.Reductions,
+		}
+		for _, index := range plan.ToDelete {
+			indexes[index.Name] = index
+			record.Plan.Indexes = append(record.Plan.Indexes, AuditIndex{
+				Name:          index.Name,
+				UUID:          index.UUID,
+				SizeBytes:     index.SizeBytes,
+				PrimaryBytes:  index.PrimaryBytes,
+				DocsCount:     index.DocsCount,
+				CreationDate:  index.CreationDate,
+				Reasons:       plan.Result.Reasons[index.Name],
+				SizeUncertain: index.SizeUncertain,
+			})
+		}
+	}
+
+	if report != nil {
+		record.Deleted = report.Deleted
+		record.Failed = report.Failed
+		record.Aborted = report.Aborted
+		record.BlockedBy = report.BlockedBy
+		for _, outcome := range report.Outcomes {
+			index := indexes[outcome.Index]
+			record.Deletions = append(record.Deletions, AuditDeletion{
+				Index:        outcome.Index,
+				UUID:         index.UUID,
+				SizeBytes:    outcome.SizeBytes,
+				DocsCount:    index.DocsCount,
+				CreationDate: index.CreationDate,
+				Error:        outcome.Error,
+				DurationMs:   outcome.Duration.Milliseconds(),
+				Action:       outcome.Action,
+			})
+		}
+	}
+
+	return record
+}
+
+// WriteAuditRecord indexes record into the configured audit index. It does
+// nothing when no audit index is configured.
+func (c *Client) WriteAuditRecord(record *AuditRecord) error {
+	if c.Config.AuditIndex == "" {
+		return nil
+	}
+
+	path := fmt.Sprintf("/%s/_doc/%s?refresh=true", c.Config.AuditIndex, record.RunID)
+	resp, err := c.makeRequestWithBody("PUT", path, record)
+	if err != nil {
+		c.Logger.Error("audit", "write", "Failed to write audit record", err)
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		err := fmt.Errorf("failed to write audit record with status %d: %s", resp.StatusCode, string(body))
+		c.Logger.Error("audit", "write", "Audit record rejected", err)
+		return err
+	}
+
+	c.Logger.Info("audit", "write", "Audit record written", map[string]interface{}{
+		"run_id": record.RunID,
+		"index":  c.Config.AuditIndex,
+	})
+	return nil
+}
+
+// auditSearchResponse is the subset of a search response used by History
+type auditSearchResponse struct {
+	Hits struct {
+		Hits []struct {
+			Source AuditRecord `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// History returns up to limit audit records, newest first. An empty policy
+// returns runs of every policy.
+func (c *Client) History(policy string, limit int) ([]AuditRecord, error) {
+	if c.Config.AuditIndex == "" {
+		return nil, fmt.Errorf("no audit index configured")
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := map[string]interface{}{
+		"size": limit,
+		"sort": []interface{}{
+			map[string]interface{}{"start_time": map[string]string{"order": "desc"}},
+		},
+	}
+	if policy != "" {
+		query["query"] = map[string]interface{}{
+			"term": map[string]interface{}{"policy.keyword": policy},
+		}
+	}
+
+	path := fmt.Sprintf("/%s/_search", c.Config.AuditIndex)
+	resp, err := c.makeRequestWithBody("POST", path, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search audit index: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// No runs have been recorded yet
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to search audit index with status %d", resp.StatusCode)
+	}
+
+	var result auditSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode audit records: %w", err)
+	}
+
+	records := make([]AuditRecord, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		records = append(records, hit.Source)
+	}
+	return records, nil
+}
+
+// historyColumns are the table columns printed by PrintHistory
+var historyColumns = []string{"RUN ID", "STARTED", "POLICY", "USER@HOST", "PLANNED", "DELETED", "FAILED", "DURATION"}
+
+// historyWidths are the column widths used by PrintHistory
+var historyWidths = []int{18, 20, 20, 28, 8, 8, 8, 10}
+
+// PrintHistory prints past runs as a table
+func PrintHistory(records []AuditRecord) {
+	utils.PrintTableHeader(historyColumns, historyWidths)
+	for _, record := range records {
+		deleted := fmt.Sprintf("%d", record.Deleted)
+		if record.DryRun {
+			deleted = "dry-run"
+		}
+		utils.PrintTableRow([]string{
+			record.RunID,
+			record.StartTime.Local().Format("2006-01-02 15:04"),
+			record.Policy,
+			record.User + "@" + record.Host,
+			fmt.Sprintf("%d", len(record.Plan.Indexes)),
+			deleted,
+			fmt.Sprintf("%d", record.Failed),
+			runDuration(record),
+		}, historyWidths)
+	}
+	utils.PrintTableFooter(historyWidths)
+}
+
+// runDuration formats how long a run took. utils.FormatDuration formats
+// ages ("5m ago") and does not fit here.
+func runDuration(record AuditRecord) string {
+	return (time.Duration(record.DurationMs) * time.Millisecond).Round(time.Second).String()
+}
@@ -0,0 +1,119 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+func TestNewAuditRecord(t *testing.T) {
+	cfg := &config.Config{IndexPattern: "logs-*", PolicyName: "app", DeleteIndexes: true}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	plan := &Plan{
+		ID: "plan-1",
+		ToDelete: []IndexInfo{
+			{Name: "logs-1", UUID: "uuid-1", SizeBytes: 100, DocsCount: 10, CreationDate: created},
+			{Name: "logs-2", UUID: "uuid-2", SizeBytes: 200, DocsCount: 20, CreationDate: created},
+		},
+		Result: AnalysisResult{Reasons: map[string][]string{"logs-1": {ReasonAge}, "logs-2": {ReasonAge, ReasonSize}}},
+	}
+	report := &DeletionReport{
+		Deleted: 1,
+		Failed:  1,
+		Outcomes: []DeletionOutcome{
+			{Index: "logs-1", SizeBytes: 100},
+			{Index: "logs-2", SizeBytes: 200, Error: "boom"},
+		},
+	}
+
+	record := client.NewAuditRecord("run-1", time.Now(), plan, report, nil)
+	if record.RunID != "run-1" || record.Policy != "app" || record.DryRun {
+		t.Errorf("Unexpected record header: %+v", record)
+	}
+	if len(record.Plan.Indexes) != 2 || len(record.Plan.Indexes[1].Reasons) != 2 {
+		t.Errorf("Expected plan indexes with reasons, got %+v", record.Plan.Indexes)
+	}
+	if len(record.Deletions) != 2 || record.Deletions[1].UUID != "uuid-2" || record.Deletions[1].Error != "boom" {
+		t.Errorf("Expected deletion outcomes joined with index details, got %+v", record.Deletions)
+	}
+
+	// A real run that fails before deleting has no report but is no dry run
+	failed := client.NewAuditRecord("", time.Now(), plan, nil, errors.New("failed"))
+	if failed.RunID == "" || failed.DryRun || failed.Error != "failed" {
+		t.Errorf("Expected generated run ID, real run and error, got %+v", failed)
+	}
+
+	dryCfg := *cfg
+	dryCfg.DeleteIndexes = false
+	dryRun := client.WithConfig(&dryCfg).NewAuditRecord("", time.Now(), plan, nil, nil)
+	if !dryRun.DryRun {
+		t.Errorf("Expected a dry run record, got %+v", dryRun)
+	}
+}
+
+func TestWriteAuditRecordAndHistory(t *testing.T) {
+	var stored []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/trimmer-audit/_doc/"):
+			stored, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"result":"created"}`))
+		case r.Method == "POST" && r.URL.Path == "/trimmer-audit/_search":
+			w.Write([]byte(`{"hits":{"hits":[{"_source":` + string(stored) + `}]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ESHost: server.URL, AuditIndex: "trimmer-audit", IndexPattern: "logs-*"}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	record := client.NewAuditRecord("run-1", time.Now(), &Plan{ID: "plan-1"}, &DeletionReport{Deleted: 3}, nil)
+	if err := client.WriteAuditRecord(record); err != nil {
+		t.Fatalf("Expected audit record to be written, got %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(stored, &doc); err != nil || doc["run_id"] != "run-1" {
+		t.Fatalf("Expected indexed document with run_id, got %s", stored)
+	}
+
+	records, err := client.History("", 10)
+	if err != nil {
+		t.Fatalf("Expected history to succeed, got %v", err)
+	}
+	if len(records) != 1 || records[0].Deleted != 3 {
+		t.Errorf("Expected one past run with 3 deletions, got %+v", records)
+	}
+}
+
+func TestRunDuration(t *testing.T) {
+	for _, tt := range []struct {
+		ms       int64
+		expected string
+	}{
+		{0, "0s"},
+		{1400, "1s"},
+		{300000, "5m0s"},
+		{5400000, "1h30m0s"},
+	} {
+		if got := runDuration(AuditRecord{DurationMs: tt.ms}); got != tt.expected {
+			t.Errorf("For %dms, expected %q, got %q", tt.ms, tt.expected, got)
+		}
+	}
+}
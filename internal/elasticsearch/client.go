@@ -1,6 +1,7 @@
 package elasticsearch
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -14,29 +15,73 @@ import (
 
 	"github.com/company/log-trimmer/internal/config"
 	"github.com/company/log-trimmer/internal/logger"
+	"github.com/company/log-trimmer/internal/metrics"
 )
 
 // IndexInfo represents metadata about an Elasticsearch index
 type IndexInfo struct {
-	Name         string    `json:"index"`
-	Health       string    `json:"health"`
-	Status       string    `json:"status"`
-	UUID         string    `json:"uuid"`
-	Primary      string    `json:"pri"`
-	Replica      string    `json:"rep"`
-	DocsCount    int64     `json:"docs.count,string"`
-	DocsDeleted  int64     `json:"docs.deleted,string"`
-	StoreSize    string    `json:"store.size"`
-	PrimarySize  string    `json:"pri.store.size"`
-	SizeBytes    int64     // Calculated from StoreSize
-	CreationDate time.Time // Calculated from index metadata
+	Name          string    `json:"index"`
+	Health        string    `json:"health"`
+	Status        string    `json:"status"`
+	UUID          string    `json:"uuid"`
+	Primary       string    `json:"pri"`
+	Replica       string    `json:"rep"`
+	DocsCount     int64     `json:"docs.count,string"`
+	DocsDeleted   int64     `json:"docs.deleted,string"`
+	StoreSize     string    `json:"store.size"`
+	PrimarySize   string    `json:"pri.store.size"`
+	SizeBytes     int64     // Calculated from StoreSize
+	PrimaryBytes  int64     // Calculated from PrimarySize
+	Primaries     int       // Parsed from Primary
+	Replicas      int       // Parsed from Replica
+	CreationDate  time.Time // Calculated from index metadata
+	ManagedBy     string    // "ilm" or "ism" when a lifecycle policy is attached
+	PolicyName    string    // Name of the attached lifecycle policy
+	SizeSource    string    // Where the sizes came from: "cat", "stats" or "estimate"
+	SizeUncertain bool      // Sizes are estimated or partial, as for closed and red indexes
+	WriteIndexOf  string    // Data stream this index is the write index of
+	// RemoteSnapshot marks a partially mounted searchable snapshot, whose
+	// data lives in the snapshot repository with only a cache on the nodes
+	RemoteSnapshot bool
 }
 
+// Index states reported by _cat/indices
+const (
+	StatusClose = "close"
+	HealthRed   = "red"
+)
+
+// IsClosed reports whether the index is closed
+func (i IndexInfo) IsClosed() bool {
+	return i.Status == StatusClose
+}
+
+// IsRed reports whether the index has unassigned primary shards
+func (i IndexInfo) IsRed() bool {
+	return i.Health == HealthRed
+}
+
+// ShardCopies returns the number of primary and replica shard copies
+func (i IndexInfo) ShardCopies() int {
+	return i.Primaries * (1 + i.Replicas)
+}
+
+// Lifecycle managers that can own an index
+const (
+	ManagedByILM = "ilm"
+	ManagedByISM = "ism"
+)
+
 // ClusterInfo represents overall cluster information
 type ClusterInfo struct {
-	ClusterName string `json:"cluster_name"`
-	Status      string `json:"status"`
-	NodeCount   int    `json:"number_of_nodes"`
+	ClusterName        string `json:"cluster_name"`
+	Status             string `json:"status"`
+	NodeCount          int    `json:"number_of_nodes"`
+	ActiveShards       int    `json:"active_shards"`
+	RelocatingShards   int    `json:"relocating_shards"`
+	InitializingShards int    `json:"initializing_shards"`
+	UnassignedShards   int    `json:"unassigned_shards"`
+	PendingTasks       int    `json:"number_of_pending_tasks"`
 }
 
 // Client wraps HTTP client for Elasticsearch operations
@@ -45,6 +90,16 @@ type Client struct {
 	HTTPClient *http.Client
 	Config     *config.Config
 	Logger     *logger.Logger
+	Server     *ServerInfo       // Set by Connect
+	Metrics    *metrics.Registry // Optional, nil disables metrics
+
+	// PauseOutsideWindow makes a deletion run that outlasts its maintenance
+	// window wait for the next window instead of deferring the rest
+	PauseOutsideWindow bool
+
+	// Clock returns the time analysis is evaluated at. When nil the
+	// configured as-of time is used, or the current time without one.
+	Clock func() time.Time
 }
 
 // NewClient creates a new Elasticsearch client
@@ -65,8 +120,33 @@ func NewClient(cfg *config.Config, log *logger.Logger) *Client {
 	}
 }
 
-// makeRequest makes an HTTP request to Elasticsearch
+// now returns the time analysis is evaluated at
+func (c *Client) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock()
+	}
+	if !c.Config.AsOfTime.IsZero() {
+		return c.Config.AsOfTime
+	}
+	return time.Now()
+}
+
+// WithConfig returns a copy of the client using cfg, sharing the underlying
+// HTTP connections, detected server info and metrics
+func (c *Client) WithConfig(cfg *config.Config) *Client {
+	clone := *c
+	clone.Config = cfg
+	return &clone
+}
+
+// makeRequest makes an HTTP request to Elasticsearch without a body
 func (c *Client) makeRequest(method, path string) (*http.Response, error) {
+	return c.makeRequestWithBody(method, path, nil)
+}
+
+// makeRequestWithBody makes an HTTP request to Elasticsearch with body
+// encoded as JSON; a nil body sends no content
+func (c *Client) makeRequestWithBody(method, path string, body interface{}) (*http.Response, error) {
 	url := c.BaseURL + path
 
 	c.Logger.Debug("elasticsearch", "request", "Making request", map[string]interface{}{
@@ -74,10 +154,22 @@ func (c *Client) makeRequest(method, path string) (*http.Response, error) {
 		"url":    url,
 	})
 
-	req, err := http.NewRequest(method, url, nil)
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request body: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	if c.Config.Username != "" && c.Config.Password != "" {
 		req.SetBasicAuth(c.Config.Username, c.Config.Password)
@@ -86,7 +178,9 @@ func (c *Client) makeRequest(method, path string) (*http.Response, error) {
 		})
 	}
 
+	start := time.Now()
 	resp, err := c.HTTPClient.Do(req)
+	c.Metrics.ObserveRequest(endpointLabel(method, path), time.Since(start))
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -99,6 +193,35 @@ func (c *Client) makeRequest(method, path string) (*http.Response, error) {
 	return resp, nil
 }
 
+// endpointLabel reduces a request path to a low-cardinality endpoint name
+// for metrics, dropping index names and query strings
+func endpointLabel(method, path string) string {
+	if idx := strings.Index(path, "?"); idx != -1 {
+		path = path[:idx]
+	}
+
+	// Keep API segments such as "_settings" plus the sub-API that follows
+	// grouping endpoints like "_cat/indices" or "_cluster/health"
+	var parts []string
+	previous := ""
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		switch {
+		case strings.HasPrefix(segment, "_"):
+			parts = append(parts, segment)
+		case previous == "_cat" || previous == "_cluster" || previous == "_ism":
+			parts = append(parts, segment)
+		}
+		previous = segment
+	}
+	if len(parts) == 0 {
+		if path == "/" || path == "" {
+			return method + " /"
+		}
+		return method + " /{index}"
+	}
+	return method + " /" + strings.Join(parts, "/")
+}
+
 // GetClusterHealth retrieves cluster health information
 func (c *Client) GetClusterHealth() (*ClusterInfo, error) {
 	c.Logger.Info("elasticsearch", "cluster_health", "Retrieving cluster health information")
@@ -133,12 +256,46 @@ func (c *Client) GetClusterHealth() (*ClusterInfo, error) {
 	return &clusterInfo, nil
 }
 
+// ElectedMaster returns the name of the elected master node, which
+// OpenSearch 2 calls the cluster manager
+func (c *Client) ElectedMaster() (string, error) {
+	if err := c.EnsureConnected(); err != nil {
+		return "", err
+	}
+
+	column := c.CatColumn("master")
+	resp, err := c.makeRequest("GET", "/_cat/nodes?format=json&h=name,"+column)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("failed to get nodes with status %d", resp.StatusCode)
+	}
+
+	var nodes []map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		return "", fmt.Errorf("failed to decode nodes response: %w", err)
+	}
+	for _, node := range nodes {
+		if node[column] == "*" {
+			return node["name"], nil
+		}
+	}
+	return "", fmt.Errorf("no elected %s node", column)
+}
+
 // GetIndexes retrieves indexes matching the given pattern
 func (c *Client) GetIndexes(pattern string) ([]IndexInfo, error) {
 	c.Logger.Info("elasticsearch", "get_indexes", "Retrieving indexes", map[string]interface{}{
 		"pattern": pattern,
 	})
 
+	if err := c.EnsureConnected(); err != nil {
+		return nil, err
+	}
+
 	path := fmt.Sprintf("/_cat/indices/%s?format=json&bytes=b", pattern)
 	resp, err := c.makeRequest("GET", path)
 	if err != nil {
@@ -175,21 +332,29 @@ func (c *Client) GetIndexes(pattern string) ([]IndexInfo, error) {
 			})
 		}
 	}
+	c.resolveUncertainSizes(indexes)
+
+	// Without the data streams no index is marked as a write index; the
+	// cluster still refuses to delete one
+	if err := c.markWriteIndexes(indexes); err != nil {
+		c.Logger.Warn("elasticsearch", "data_streams", "Could not get data streams, write indexes are not marked", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
 
 	return indexes, nil
 }
 
 // enrichIndexInfo adds computed fields to index information
 func (c *Client) enrichIndexInfo(index *IndexInfo) error {
-	// Parse size from string format to bytes
-	if sizeBytes, err := strconv.ParseInt(index.StoreSize, 10, 64); err == nil {
-		index.SizeBytes = sizeBytes
-	} else {
-		// Fallback to parsing human-readable format
-		if sizeBytes, err := parseESSize(index.StoreSize); err == nil {
-			index.SizeBytes = sizeBytes
-		}
-	}
+	// Parse sizes from string format to bytes
+	index.SizeBytes = parseStoreSize(index.StoreSize)
+	index.PrimaryBytes = parseStoreSize(index.PrimarySize)
+	index.SizeSource = SizeSourceCat
+
+	// Parse shard counts
+	index.Primaries, _ = strconv.Atoi(index.Primary)
+	index.Replicas, _ = strconv.Atoi(index.Replica)
 
 	// Get index settings to determine creation date
 	path := fmt.Sprintf("/%s/_settings", index.Name)
@@ -208,22 +373,135 @@ func (c *Client) enrichIndexInfo(index *IndexInfo) error {
 		return err
 	}
 
+	indexSettings, ok := settings[index.Name].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	settingsObj, ok := indexSettings["settings"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
 	// Extract creation date
-	if indexSettings, ok := settings[index.Name].(map[string]interface{}); ok {
-		if settingsObj, ok := indexSettings["settings"].(map[string]interface{}); ok {
-			if indexObj, ok := settingsObj["index"].(map[string]interface{}); ok {
-				if creationDateStr, ok := indexObj["creation_date"].(string); ok {
-					if creationTimestamp, err := strconv.ParseInt(creationDateStr, 10, 64); err == nil {
-						index.CreationDate = time.Unix(0, creationTimestamp*int64(time.Millisecond))
-					}
-				}
-			}
+	if creationDateStr, ok := nestedString(settingsObj, "index", "creation_date"); ok {
+		if creationTimestamp, err := strconv.ParseInt(creationDateStr, 10, 64); err == nil {
+			index.CreationDate = time.Unix(0, creationTimestamp*int64(time.Millisecond))
+		}
+	}
+
+	if c.Server != nil && c.Supports(CapabilitySearchableSnapshots) {
+		index.RemoteSnapshot = remoteSnapshot(settingsObj)
+	}
+
+	// Detect an attached ILM or ISM lifecycle policy
+	if policy, ok := nestedString(settingsObj, "index", "lifecycle", "name"); ok && policy != "" {
+		index.ManagedBy = ManagedByILM
+		index.PolicyName = policy
+	} else if policy, ok := nestedString(settingsObj, "index", "plugins", "index_state_management", "policy_id"); ok && policy != "" {
+		index.ManagedBy = ManagedByISM
+		index.PolicyName = policy
+	} else if policy, ok := nestedString(settingsObj, "index", "opendistro", "index_state_management", "policy_id"); ok && policy != "" {
+		index.ManagedBy = ManagedByISM
+		index.PolicyName = policy
+	} else if c.Server != nil && c.Supports(CapabilityISM) {
+		// Recent OpenSearch releases no longer mirror the ISM policy into
+		// the index settings, so ask the ISM plugin directly
+		policy, err := c.explainISMPolicy(index.Name)
+		if err != nil {
+			return err
+		}
+		if policy != "" {
+			index.ManagedBy = ManagedByISM
+			index.PolicyName = policy
 		}
 	}
 
 	return nil
 }
 
+// remoteSnapshot reports whether index settings describe a partially
+// mounted searchable snapshot: store type "snapshot" with partial set on
+// Elasticsearch, or store type "remote_snapshot" on OpenSearch
+func remoteSnapshot(settings map[string]interface{}) bool {
+	storeType, _ := nestedString(settings, "index", "store", "type")
+	switch storeType {
+	case "remote_snapshot":
+		return true
+	case "snapshot":
+		partial, _ := nestedString(settings, "index", "store", "snapshot", "partial")
+		return partial == "true"
+	}
+	return false
+}
+
+// explainISMPolicy returns the ISM policy attached to an index, if any
+func (c *Client) explainISMPolicy(indexName string) (string, error) {
+	if err := c.requireCapability(CapabilityISM); err != nil {
+		return "", err
+	}
+
+	path := fmt.Sprintf("/_plugins/_ism/explain/%s", indexName)
+	resp, err := c.makeRequest("GET", path)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("failed to explain ISM policy with status %d", resp.StatusCode)
+	}
+
+	var explain map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&explain); err != nil {
+		return "", err
+	}
+
+	indexExplain, ok := explain[indexName].(map[string]interface{})
+	if !ok {
+		return "", nil
+	}
+	if policy, ok := indexExplain["index.plugins.index_state_management.policy_id"].(string); ok {
+		return policy, nil
+	}
+	if policy, ok := indexExplain["policy_id"].(string); ok {
+		return policy, nil
+	}
+	return "", nil
+}
+
+// parseStoreSize parses a _cat store size, which is a plain byte count when
+// requested with bytes=b and human-readable otherwise
+func parseStoreSize(size string) int64 {
+	if sizeBytes, err := strconv.ParseInt(size, 10, 64); err == nil {
+		return sizeBytes
+	}
+	// Fallback to parsing human-readable format
+	if sizeBytes, err := parseESSize(size); err == nil {
+		return sizeBytes
+	}
+	return 0
+}
+
+// nestedString walks a decoded JSON object along keys and returns the string
+// found at the end of the path
+func nestedString(obj map[string]interface{}, keys ...string) (string, bool) {
+	current := obj
+	for i, key := range keys {
+		value, ok := current[key]
+		if !ok {
+			return "", false
+		}
+		if i == len(keys)-1 {
+			str, ok := value.(string)
+			return str, ok
+		}
+		if current, ok = value.(map[string]interface{}); !ok {
+			return "", false
+		}
+	}
+	return "", false
+}
+
 // DeleteIndex deletes the specified index
 func (c *Client) DeleteIndex(indexName string) error {
 	c.Logger.Info("elasticsearch", "delete_index", "Deleting index", map[string]interface{}{
@@ -271,19 +549,44 @@ func (c *Client) AnalyzeIndexes(indexes []IndexInfo) ([]IndexInfo, AnalysisResul
 
 	var toDelete []IndexInfo
 	var totalSize int64
-
-	// Calculate current total size
-	for _, index := range indexes {
-		totalSize += index.SizeBytes
+	matched := len(indexes)
+
+	// Skipped closed and red indexes are left out of the totals, while
+	// report-only ones count towards them but are never deleted
+	counted, indexes, unhealthy := c.filterUnhealthyIndexes(indexes)
+
+	// Calculate current total size using the configured size basis
+	totalShards := 0
+	var totalDocs int64
+	var uncertain []string
+	for _, index := range counted {
+		totalSize += c.indexSize(index)
+		totalShards += index.ShardCopies()
+		totalDocs += index.DocsCount
+		if index.SizeUncertain {
+			uncertain = append(uncertain, index.Name)
+		}
 	}
 
 	result := AnalysisResult{
-		TotalIndexes: len(indexes),
+		TotalIndexes: len(counted),
 		TotalSize:    totalSize,
 		ToDelete:     0,
 		DeletedSize:  0,
+		SizeBasis:    c.sizeBasis(),
+		TotalShards:  totalShards,
+		Uncertain:    uncertain,
 	}
 
+	// Managed indexes still count towards the total size, but are only
+	// considered for deletion when the config allows it
+	indexes, result.Skipped = c.filterManagedIndexes(indexes)
+	result.Skipped = append(unhealthy, result.Skipped...)
+	indexes, writes := c.filterWriteIndexes(indexes)
+	result.Skipped = append(result.Skipped, writes...)
+
+	c.Metrics.SetPolicyState(c.policyLabel(), matched, totalSize)
+
 	c.Logger.Info("analysis", "current_state", "Current cluster state", map[string]interface{}{
 		"total_indexes": len(indexes),
 		"total_size":    totalSize,
@@ -291,11 +594,12 @@ func (c *Client) AnalyzeIndexes(indexes []IndexInfo) ([]IndexInfo, AnalysisResul
 
 	// Apply age filter first
 	if c.Config.MaxAgeDuration > 0 {
-		cutoffTime := time.Now().Add(-c.Config.MaxAgeDuration)
+		cutoffTime := c.now().Add(-c.Config.MaxAgeDuration)
 		for _, index := range indexes {
 			if index.CreationDate.Before(cutoffTime) {
 				toDelete = append(toDelete, index)
-				result.DeletedSize += index.SizeBytes
+				result.DeletedSize += c.indexSize(index)
+				result.addReason(index.Name, ReasonAge)
 			}
 		}
 		c.Logger.Info("analysis", "age_filter", "Applied age filter", map[string]interface{}{
@@ -317,6 +621,12 @@ func (c *Client) AnalyzeIndexes(indexes []IndexInfo) ([]IndexInfo, AnalysisResul
 		// Calculate how much we're already deleting from age filter
 		deletedSize := result.DeletedSize
 
+		// Dropping replicas of the oldest indexes is tried before deleting
+		// any; deletion only covers what the reductions cannot reclaim
+		if c.Config.SizeStrategy == config.SizeStrategyReduceReplicas && deletedSize < excessSize {
+			c.planReplicaReductions(indexes, toDelete, excessSize-deletedSize, &result)
+		}
+
 		for _, index := range indexes {
 			// Skip if already marked for deletion by age
 			alreadyMarked := false
@@ -327,32 +637,316 @@ func (c *Client) AnalyzeIndexes(indexes []IndexInfo) ([]IndexInfo, AnalysisResul
 				}
 			}
 
-			if !alreadyMarked && (deletedSize < excessSize) {
+			if !alreadyMarked && (deletedSize+result.ReducedSize < excessSize) {
 				toDelete = append(toDelete, index)
-				deletedSize += index.SizeBytes
+				result.dropReduction(index.Name)
+				deletedSize += c.indexSize(index)
+				result.addReason(index.Name, ReasonSize)
 			}
 		}
 
 		result.DeletedSize = deletedSize
 	}
 
+	// Apply document count rules
+	if c.Config.EmptyIndexAgeDuration > 0 {
+		toDelete = c.applyEmptyIndexRule(indexes, toDelete, &result)
+	}
+	if c.Config.MaxDocs > 0 {
+		result.TotalDocs = totalDocs
+		toDelete = c.applyDocBudget(indexes, toDelete, &result)
+	}
+
+	// Apply shard budget
+	if c.Config.MaxShards > 0 {
+		toDelete = c.applyShardBudget(indexes, toDelete, &result)
+	}
+
+	// Apply disk watermark filter
+	if c.Config.TargetDiskPercent > 0 {
+		before := len(toDelete)
+		selected, diskResult, err := c.applyDiskTarget(indexes, toDelete)
+		if err != nil {
+			c.Logger.Error("analysis", "disk_filter", "Could not evaluate disk usage target, skipping disk filter", err)
+		} else {
+			for _, index := range selected[before:] {
+				result.DeletedSize += c.indexSize(index)
+				result.addReason(index.Name, ReasonDisk)
+			}
+			toDelete = selected
+			result.DiskTarget = diskResult
+		}
+	}
+
 	result.ToDelete = len(toDelete)
+	for _, index := range toDelete {
+		// Later rules can select an index whose replicas were to be dropped
+		result.dropReduction(index.Name)
+		result.ReclaimedTotal += index.SizeBytes
+		result.ReclaimedPrimary += index.PrimaryBytes
+		result.DeletedShards += index.ShardCopies()
+		result.DeletedDocs += index.DocsCount
+	}
+	result.ReclaimedTotal += result.ReducedSize
+	result.ProjectedSize = totalSize - result.DeletedSize - result.ReducedSize
 
 	c.Logger.Info("analysis", "result", "Analysis complete", map[string]interface{}{
-		"total_indexes":     result.TotalIndexes,
-		"indexes_to_delete": result.ToDelete,
-		"size_to_delete":    result.DeletedSize,
+		"total_indexes":      result.TotalIndexes,
+		"indexes_to_delete":  result.ToDelete,
+		"size_to_delete":     result.DeletedSize,
+		"size_basis":         result.SizeBasis,
+		"indexes_skipped":    len(result.Skipped),
+		"replica_reductions": len(result.Reductions),
+		"projected_size":     result.ProjectedSize,
 	})
 
 	return toDelete, result
 }
 
-// AnalysisResult contains the results of index analysis
+// filterManagedIndexes removes indexes owned by an ILM/ISM policy from the
+// deletion candidates according to the configured managed index mode
+func (c *Client) filterManagedIndexes(indexes []IndexInfo) ([]IndexInfo, []SkippedIndex) {
+	mode := c.Config.ManagedIndexes
+	if mode == "" {
+		mode = config.ManagedSkip
+	}
+
+	var candidates []IndexInfo
+	var skipped []SkippedIndex
+	for _, index := range indexes {
+		if index.ManagedBy == "" {
+			candidates = append(candidates, index)
+			continue
+		}
+
+		fields := map[string]interface{}{
+			"index":      index.Name,
+			"managed_by": index.ManagedBy,
+			"policy":     index.PolicyName,
+		}
+
+		switch mode {
+		case config.ManagedWarn:
+			c.Logger.Warn("analysis", "managed_index", "Index is managed by a lifecycle policy but will be trimmed", fields)
+			candidates = append(candidates, index)
+		case config.ManagedTakeOver:
+			c.Logger.Debug("analysis", "managed_index", "Taking over lifecycle-managed index", fields)
+			candidates = append(candidates, index)
+		default:
+			c.Logger.Info("analysis", "managed_index", "Skipping lifecycle-managed index", fields)
+			skipped = append(skipped, SkippedIndex{
+				Name:   index.Name,
+				Reason: fmt.Sprintf("managed by %s policy %s", strings.ToUpper(index.ManagedBy), index.PolicyName),
+				Policy: index.PolicyName,
+			})
+		}
+	}
+
+	return candidates, skipped
+}
+
+// applyEmptyIndexRule marks indexes without documents for deletion once they
+// are older than the empty index grace age. Indexes with uncertain sizes are
+// left alone, as a closed or red index reports no reliable document count.
+func (c *Client) applyEmptyIndexRule(indexes, toDelete []IndexInfo, result *AnalysisResult) []IndexInfo {
+	marked := make(map[string]bool)
+	for _, index := range toDelete {
+		marked[index.Name] = true
+	}
+
+	cutoffTime := c.now().Add(-c.Config.EmptyIndexAgeDuration)
+	emptyDeletes := 0
+	for _, index := range indexes {
+		if index.SizeUncertain || index.DocsCount != 0 || !index.CreationDate.Before(cutoffTime) {
+			continue
+		}
+		// An index can be both old and empty; record both reasons
+		result.addReason(index.Name, ReasonEmpty)
+		emptyDeletes++
+		if marked[index.Name] {
+			continue
+		}
+		toDelete = append(toDelete, index)
+		marked[index.Name] = true
+		result.DeletedSize += c.indexSize(index)
+	}
+
+	c.Logger.Info("analysis", "empty_filter", "Applied empty index filter", map[string]interface{}{
+		"empty_index_age": c.Config.EmptyIndexAge,
+		"cutoff_time":     cutoffTime,
+		"empty_deletes":   emptyDeletes,
+	})
+
+	return toDelete
+}
+
+// applyDocBudget marks the oldest indexes for deletion until the document
+// count of the matching indexes fits within MaxDocs. Like the size total,
+// result.TotalDocs covers every counted index, including skipped ones.
+// Indexes with uncertain sizes are not selected, as their document counts
+// are missing or partial.
+func (c *Client) applyDocBudget(indexes, toDelete []IndexInfo, result *AnalysisResult) []IndexInfo {
+	var deletedDocs int64
+	marked := make(map[string]bool)
+	for _, index := range toDelete {
+		marked[index.Name] = true
+		deletedDocs += index.DocsCount
+	}
+	totalDocs := result.TotalDocs
+
+	if totalDocs <= c.Config.MaxDocs {
+		return toDelete
+	}
+
+	excessDocs := totalDocs - c.Config.MaxDocs
+	c.Logger.Warn("analysis", "docs_filter", "Total document count exceeds limit", map[string]interface{}{
+		"total_docs":  totalDocs,
+		"max_docs":    c.Config.MaxDocs,
+		"excess_docs": excessDocs,
+	})
+
+	for _, index := range indexes {
+		if deletedDocs >= excessDocs {
+			break
+		}
+		if marked[index.Name] || index.SizeUncertain {
+			continue
+		}
+		toDelete = append(toDelete, index)
+		marked[index.Name] = true
+		deletedDocs += index.DocsCount
+		result.DeletedSize += c.indexSize(index)
+		result.addReason(index.Name, ReasonDocs)
+	}
+
+	return toDelete
+}
+
+// applyShardBudget marks the oldest indexes for deletion until the shard
+// copies of the matching indexes fit within MaxShards
+func (c *Client) applyShardBudget(indexes, toDelete []IndexInfo, result *AnalysisResult) []IndexInfo {
+	marked := make(map[string]bool)
+	deletedShards := 0
+	for _, index := range toDelete {
+		marked[index.Name] = true
+		deletedShards += index.ShardCopies()
+	}
+
+	if result.TotalShards > c.Config.MaxShards {
+		excessShards := result.TotalShards - c.Config.MaxShards
+		c.Logger.Warn("analysis", "shard_filter", "Total shard count exceeds limit", map[string]interface{}{
+			"total_shards":  result.TotalShards,
+			"max_shards":    c.Config.MaxShards,
+			"excess_shards": excessShards,
+		})
+
+		for _, index := range indexes {
+			if deletedShards >= excessShards {
+				break
+			}
+			if marked[index.Name] {
+				continue
+			}
+			toDelete = append(toDelete, index)
+			marked[index.Name] = true
+			deletedShards += index.ShardCopies()
+			result.DeletedSize += c.indexSize(index)
+			result.addReason(index.Name, ReasonShards)
+		}
+	}
+
+	c.Logger.Info("analysis", "shard_filter", "Applied shard budget", map[string]interface{}{
+		"max_shards":       c.Config.MaxShards,
+		"total_shards":     result.TotalShards,
+		"projected_shards": result.TotalShards - deletedShards,
+	})
+
+	return toDelete
+}
+
+// sizeBasis returns the configured size basis, defaulting to total
+func (c *Client) sizeBasis() string {
+	if c.Config.SizeBasis == config.SizeBasisPrimary {
+		return config.SizeBasisPrimary
+	}
+	return config.SizeBasisTotal
+}
+
+// indexSize returns the size of an index according to the size basis
+func (c *Client) indexSize(index IndexInfo) int64 {
+	if c.sizeBasis() == config.SizeBasisPrimary {
+		return index.PrimaryBytes
+	}
+	return index.SizeBytes
+}
+
+// AnalysisResult contains the results of index analysis. TotalSize and
+// DeletedSize are measured in SizeBasis; ReclaimedTotal and ReclaimedPrimary
+// report the projected reclaimed space for both bases.
 type AnalysisResult struct {
-	TotalIndexes int   `json:"total_indexes"`
-	TotalSize    int64 `json:"total_size"`
-	ToDelete     int   `json:"to_delete"`
-	DeletedSize  int64 `json:"deleted_size"`
+	TotalIndexes     int            `json:"total_indexes"`
+	TotalSize        int64          `json:"total_size"`
+	ToDelete         int            `json:"to_delete"`
+	DeletedSize      int64          `json:"deleted_size"`
+	SizeBasis        string         `json:"size_basis"`
+	ReclaimedTotal   int64          `json:"reclaimed_total"`
+	ReclaimedPrimary int64          `json:"reclaimed_primary"`
+	TotalShards      int            `json:"total_shards"`
+	DeletedShards    int            `json:"deleted_shards"`
+	TotalDocs        int64          `json:"total_docs,omitempty"`
+	DeletedDocs      int64          `json:"deleted_docs"`
+	Skipped          []SkippedIndex `json:"skipped,omitempty"`
+
+	// Uncertain lists counted indexes whose size is estimated or partial
+	Uncertain []string `json:"uncertain,omitempty"`
+
+	// Reductions lists indexes whose replicas are dropped instead of the
+	// indexes being deleted; ReducedSize is what they reclaim
+	Reductions  []ReplicaReduction `json:"reductions,omitempty"`
+	ReducedSize int64              `json:"reduced_size,omitempty"`
+
+	// ProjectedSize is TotalSize after the deletions and reductions
+	ProjectedSize int64 `json:"projected_size"`
+
+	// Reasons lists the rules that selected each index, keyed by index name
+	Reasons map[string][]string `json:"reasons,omitempty"`
+
+	// Cluster-wide shard copies before and after deletion, only reported
+	// when a shard budget is configured
+	ClusterShards          int `json:"cluster_shards,omitempty"`
+	ProjectedClusterShards int `json:"projected_cluster_shards,omitempty"`
+
+	DiskTarget *DiskTargetResult `json:"disk_target,omitempty"`
+}
+
+// Reasons an index can be selected for deletion
+const (
+	ReasonAge    = "age"
+	ReasonSize   = "size"
+	ReasonEmpty  = "empty"
+	ReasonDocs   = "docs"
+	ReasonShards = "shards"
+	ReasonDisk   = "disk"
+)
+
+// addReason records that a rule selected the named index for deletion
+func (r *AnalysisResult) addReason(name, reason string) {
+	if r.Reasons == nil {
+		r.Reasons = make(map[string][]string)
+	}
+	for _, existing := range r.Reasons[name] {
+		if existing == reason {
+			return
+		}
+	}
+	r.Reasons[name] = append(r.Reasons[name], reason)
+}
+
+// SkippedIndex describes an index that matched the pattern but was left alone
+type SkippedIndex struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	Policy string `json:"policy,omitempty"`
 }
 
 // parseESSize parses Elasticsearch size format
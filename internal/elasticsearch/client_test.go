@@ -4,10 +4,15 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/esfake"
 	"github.com/company/log-trimmer/internal/logger"
+	"github.com/company/log-trimmer/internal/metrics"
 )
 
 func TestNewClient(t *testing.T) {
@@ -94,3 +99,414 @@ func TestParseESSize(t *testing.T) {
 		}
 	}
 }
+
+func TestEnrichIndexInfoDetectsLifecyclePolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/ilm-index/_settings":
+			w.Write([]byte(`{"ilm-index":{"settings":{"index":{"creation_date":"1700000000000","lifecycle":{"name":"logs-policy"}}}}}`))
+		case "/ism-index/_settings":
+			w.Write([]byte(`{"ism-index":{"settings":{"index":{"creation_date":"1700000000000","plugins":{"index_state_management":{"policy_id":"hot-warm"}}}}}}`))
+		default:
+			w.Write([]byte(`{"plain-index":{"settings":{"index":{"creation_date":"1700000000000"}}}}`))
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ESHost: server.URL, SkipTLS: true}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	tests := []struct {
+		name      string
+		managedBy string
+		policy    string
+	}{
+		{"ilm-index", ManagedByILM, "logs-policy"},
+		{"ism-index", ManagedByISM, "hot-warm"},
+		{"plain-index", "", ""},
+	}
+
+	for _, tt := range tests {
+		index := IndexInfo{Name: tt.name, StoreSize: "1024"}
+		if err := client.enrichIndexInfo(&index); err != nil {
+			t.Fatalf("Unexpected error for %s: %v", tt.name, err)
+		}
+		if index.ManagedBy != tt.managedBy || index.PolicyName != tt.policy {
+			t.Errorf("For %s, expected managed by %q/%q, got %q/%q", tt.name, tt.managedBy, tt.policy, index.ManagedBy, index.PolicyName)
+		}
+		if index.CreationDate.IsZero() {
+			t.Errorf("Expected creation date to be set for %s", tt.name)
+		}
+	}
+}
+
+func TestAnalyzeIndexesManagedModes(t *testing.T) {
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	indexes := []IndexInfo{
+		{Name: "managed", CreationDate: old, ManagedBy: ManagedByILM, PolicyName: "logs-policy"},
+		{Name: "unmanaged", CreationDate: old},
+	}
+
+	log, _ := logger.New(logger.DefaultConfig())
+
+	tests := []struct {
+		mode        string
+		wantDelete  int
+		wantSkipped int
+	}{
+		{config.ManagedSkip, 1, 1},
+		{config.ManagedWarn, 2, 0},
+		{config.ManagedTakeOver, 2, 0},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{MaxAgeDuration: 7 * 24 * time.Hour, ManagedIndexes: tt.mode}
+		client := NewClient(cfg, log)
+
+		toDelete, result := client.AnalyzeIndexes(append([]IndexInfo(nil), indexes...))
+		if len(toDelete) != tt.wantDelete {
+			t.Errorf("Mode %s: expected %d deletions, got %d", tt.mode, tt.wantDelete, len(toDelete))
+		}
+		if len(result.Skipped) != tt.wantSkipped {
+			t.Errorf("Mode %s: expected %d skipped, got %d", tt.mode, tt.wantSkipped, len(result.Skipped))
+		}
+		if tt.wantSkipped > 0 && result.Skipped[0].Policy != "logs-policy" {
+			t.Errorf("Mode %s: expected skipped policy 'logs-policy', got %s", tt.mode, result.Skipped[0].Policy)
+		}
+	}
+}
+
+func TestAnalyzeIndexesSizeBasis(t *testing.T) {
+	now := time.Now()
+	indexes := []IndexInfo{
+		{Name: "logs-1", CreationDate: now.Add(-2 * time.Hour), SizeBytes: 200, PrimaryBytes: 100},
+		{Name: "logs-2", CreationDate: now.Add(-time.Hour), SizeBytes: 200, PrimaryBytes: 100},
+		{Name: "logs-3", CreationDate: now, SizeBytes: 200, PrimaryBytes: 100},
+	}
+
+	log, _ := logger.New(logger.DefaultConfig())
+
+	tests := []struct {
+		basis      string
+		wantDelete int
+	}{
+		{config.SizeBasisTotal, 2},
+		{config.SizeBasisPrimary, 1},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{MaxSizeBytes: 250, SizeBasis: tt.basis}
+		client := NewClient(cfg, log)
+
+		toDelete, result := client.AnalyzeIndexes(append([]IndexInfo(nil), indexes...))
+		if len(toDelete) != tt.wantDelete {
+			t.Errorf("Basis %s: expected %d deletions, got %d", tt.basis, tt.wantDelete, len(toDelete))
+		}
+		if result.SizeBasis != tt.basis {
+			t.Errorf("Expected size basis %s, got %s", tt.basis, result.SizeBasis)
+		}
+		if result.ReclaimedTotal != int64(tt.wantDelete)*200 || result.ReclaimedPrimary != int64(tt.wantDelete)*100 {
+			t.Errorf("Basis %s: unexpected reclaimed sizes %d/%d", tt.basis, result.ReclaimedTotal, result.ReclaimedPrimary)
+		}
+	}
+}
+
+func TestParseStoreSize(t *testing.T) {
+	if got := parseStoreSize("2048"); got != 2048 {
+		t.Errorf("Expected 2048, got %d", got)
+	}
+	if got := parseStoreSize("1kb"); got != 1024 {
+		t.Errorf("Expected 1024, got %d", got)
+	}
+	if got := parseStoreSize(""); got != 0 {
+		t.Errorf("Expected 0 for empty size, got %d", got)
+	}
+}
+
+func TestAnalyzeIndexesShardBudget(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	indexes := []IndexInfo{
+		{Name: "logs-3", CreationDate: now, Primaries: 2, Replicas: 1},
+		{Name: "logs-1", CreationDate: now.Add(-2 * time.Hour), Primaries: 2, Replicas: 1},
+		{Name: "logs-2", CreationDate: now.Add(-time.Hour), Primaries: 2, Replicas: 1},
+	}
+
+	cfg := &config.Config{ESHost: server.URL, MaxShards: 5}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	toDelete, result := client.AnalyzeIndexes(indexes)
+	if len(toDelete) != 2 || toDelete[0].Name != "logs-1" || toDelete[1].Name != "logs-2" {
+		t.Fatalf("Expected the two oldest indexes to be deleted, got %v", toDelete)
+	}
+	if result.TotalShards != 12 || result.DeletedShards != 8 {
+		t.Errorf("Expected 8 of 12 shard copies deleted, got %d of %d", result.DeletedShards, result.TotalShards)
+	}
+	if atomic.LoadInt32(&requests) != 0 {
+		t.Errorf("Expected the analysis to make no requests, got %d", requests)
+	}
+}
+
+func TestBuildPlanProjectsClusterShards(t *testing.T) {
+	server, err := esfake.Start(esfake.Fixture{
+		Health: esfake.Health{UnassignedShards: 2},
+		Indexes: []esfake.Index{
+			{Name: "logs-1", Age: "3h", Primaries: 2, Replicas: 1},
+			{Name: "logs-2", Age: "2h", Primaries: 2, Replicas: 1},
+			{Name: "logs-3", Age: "1h", Primaries: 2, Replicas: 1},
+			{Name: "other", Primaries: 1, Replicas: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start fake cluster: %v", err)
+	}
+	defer server.Close()
+
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(&config.Config{ESHost: server.URL, IndexPattern: "logs-*", MaxShards: 5}, log)
+
+	plan, err := client.BuildPlan()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if plan.Result.ClusterShards != 14 || plan.Result.ProjectedClusterShards != 6 {
+		t.Errorf("Expected cluster shards 14 -> 6, got %d -> %d", plan.Result.ClusterShards, plan.Result.ProjectedClusterShards)
+	}
+}
+
+func TestAnalyzeIndexesDocBudgetCountsSkippedIndexes(t *testing.T) {
+	now := time.Now()
+	indexes := []IndexInfo{
+		{Name: "managed", CreationDate: now.Add(-3 * time.Hour), DocsCount: 600, ManagedBy: ManagedByILM, PolicyName: "logs"},
+		{Name: "logs-1", CreationDate: now.Add(-2 * time.Hour), DocsCount: 300},
+		{Name: "logs-2", CreationDate: now.Add(-time.Hour), DocsCount: 300},
+	}
+
+	cfg := &config.Config{MaxDocs: 1000}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	toDelete, result := client.AnalyzeIndexes(indexes)
+	if result.TotalDocs != 1200 {
+		t.Errorf("Expected managed docs to count towards the total, got %d", result.TotalDocs)
+	}
+	if len(toDelete) != 1 || toDelete[0].Name != "logs-1" {
+		t.Errorf("Expected logs-1 to be deleted, got %v", toDelete)
+	}
+}
+
+func TestAnalyzeIndexesDocumentRules(t *testing.T) {
+	now := time.Now()
+	indexes := []IndexInfo{
+		{Name: "empty-old", CreationDate: now.Add(-48 * time.Hour), DocsCount: 0},
+		{Name: "full-old", CreationDate: now.Add(-36 * time.Hour), DocsCount: 600},
+		{Name: "full-new", CreationDate: now.Add(-2 * time.Hour), DocsCount: 600},
+		{Name: "empty-new", CreationDate: now.Add(-time.Hour), DocsCount: 0},
+	}
+
+	cfg := &config.Config{EmptyIndexAgeDuration: 24 * time.Hour, MaxDocs: 1000}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	toDelete, result := client.AnalyzeIndexes(indexes)
+	if len(toDelete) != 2 || toDelete[0].Name != "empty-old" || toDelete[1].Name != "full-old" {
+		t.Fatalf("Expected empty-old and full-old to be deleted, got %v", toDelete)
+	}
+	if reasons := result.Reasons["empty-old"]; len(reasons) != 1 || reasons[0] != ReasonEmpty {
+		t.Errorf("Expected empty-old to be deleted as empty, got %v", reasons)
+	}
+	if reasons := result.Reasons["full-old"]; len(reasons) != 1 || reasons[0] != ReasonDocs {
+		t.Errorf("Expected full-old to be deleted for docs, got %v", reasons)
+	}
+	if result.TotalDocs != 1200 || result.DeletedDocs != 600 {
+		t.Errorf("Expected 600 of 1200 docs deleted, got %d of %d", result.DeletedDocs, result.TotalDocs)
+	}
+}
+
+func TestEndpointLabel(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{"GET", "/", "GET /"},
+		{"GET", "/_cat/indices/logs-*?format=json&bytes=b", "GET /_cat/indices"},
+		{"GET", "/logs-2024.01.01/_settings", "GET /_settings"},
+		{"DELETE", "/logs-2024.01.01", "DELETE /{index}"},
+	}
+
+	for _, tt := range tests {
+		if got := endpointLabel(tt.method, tt.path); got != tt.expected {
+			t.Errorf("For %s %s, expected %q, got %q", tt.method, tt.path, tt.expected, got)
+		}
+	}
+}
+
+func TestGetIndexesFromFakeCluster(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	server, err := esfake.Start(esfake.Fixture{Indexes: []esfake.Index{
+		{Name: "logs-1", CreatedAt: created, Primaries: 2, Replicas: 1, PrimaryBytes: 500, Docs: 42},
+		{Name: "logs-2", Age: "1d", PrimaryBytes: 100, Settings: map[string]interface{}{"lifecycle.name": "logs-policy"}},
+		{Name: "other", PrimaryBytes: 100},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to start fake cluster: %v", err)
+	}
+	defer server.Close()
+
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(&config.Config{ESHost: server.URL}, log)
+
+	indexes, err := client.GetIndexes("logs-*")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(indexes) != 2 {
+		t.Fatalf("Expected 2 indexes, got %d", len(indexes))
+	}
+
+	first := indexes[0]
+	if first.SizeBytes != 1000 || first.PrimaryBytes != 500 || first.DocsCount != 42 {
+		t.Errorf("Unexpected sizes: %+v", first)
+	}
+	if first.ShardCopies() != 4 {
+		t.Errorf("Expected 4 shard copies, got %d", first.ShardCopies())
+	}
+	if !first.CreationDate.Equal(created) {
+		t.Errorf("Expected creation date %v, got %v", created, first.CreationDate)
+	}
+	if indexes[1].ManagedBy != ManagedByILM || indexes[1].PolicyName != "logs-policy" {
+		t.Errorf("Expected ILM policy to be detected, got %+v", indexes[1])
+	}
+}
+
+func TestAnalyzeIndexesSkipsDataStreamWriteIndex(t *testing.T) {
+	for _, tt := range []struct {
+		version string
+		skipped bool
+	}{
+		{"8.11.0", true},
+		{"7.8.0", false},
+	} {
+		server, err := esfake.Start(esfake.Fixture{
+			Version:     tt.version,
+			Indexes:     []esfake.Index{{Name: ".ds-logs-000001", Age: "30d"}, {Name: ".ds-logs-000002", Age: "30d"}},
+			DataStreams: []esfake.DataStream{{Name: "logs", Indexes: []string{".ds-logs-000001", ".ds-logs-000002"}}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to start fake cluster: %v", err)
+		}
+
+		log, _ := logger.New(logger.DefaultConfig())
+		client := NewClient(&config.Config{ESHost: server.URL, MaxAge: "7d"}, log)
+		if err := client.Config.Validate(); err != nil {
+			t.Fatalf("Unexpected validation error: %v", err)
+		}
+
+		indexes, err := client.GetIndexes(".ds-logs-*")
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.version, err)
+		}
+		toDelete, analysis := client.AnalyzeIndexes(indexes)
+
+		skipped := false
+		for _, s := range analysis.Skipped {
+			if s.Name == ".ds-logs-000002" {
+				skipped = true
+			}
+		}
+		if skipped != tt.skipped {
+			t.Errorf("%s: expected write index skipped=%v, got %v", tt.version, tt.skipped, skipped)
+		}
+		if tt.skipped && len(toDelete) != 1 {
+			t.Errorf("%s: expected 1 deletion, got %d", tt.version, len(toDelete))
+		}
+	}
+}
+
+func TestGetIndexesWithoutDataStreamAccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/":
+			w.Write([]byte(`{"cluster_name":"test","version":{"number":"8.11.0"}}`))
+		case r.URL.Path == "/_data_stream/*":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"type":"security_exception"}}`))
+		case strings.HasPrefix(r.URL.Path, "/_cat/indices/"):
+			w.Write([]byte(`[{"index":"logs-1","store.size":"100","pri.store.size":"50"}]`))
+		case strings.HasSuffix(r.URL.Path, "/_settings"):
+			w.Write([]byte(`{"logs-1":{"settings":{"index":{"creation_date":"1700000000000"}}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(&config.Config{ESHost: server.URL}, log)
+	indexes, err := client.GetIndexes("logs-*")
+	if err != nil {
+		t.Fatalf("Expected a failing data stream lookup not to fail the run, got %v", err)
+	}
+	if len(indexes) != 1 || indexes[0].WriteIndexOf != "" {
+		t.Errorf("Expected one unmarked index, got %+v", indexes)
+	}
+}
+
+func TestDeleteIndexRefusesDataStreamWriteIndex(t *testing.T) {
+	server, err := esfake.Start(esfake.Fixture{
+		Indexes:     []esfake.Index{{Name: ".ds-logs-000001"}, {Name: ".ds-logs-000002"}},
+		DataStreams: []esfake.DataStream{{Name: "logs", Indexes: []string{".ds-logs-000001", ".ds-logs-000002"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start fake cluster: %v", err)
+	}
+	defer server.Close()
+
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(&config.Config{ESHost: server.URL}, log)
+
+	if err := client.DeleteIndex(".ds-logs-000002"); err == nil {
+		t.Error("Expected deleting the write index to fail")
+	}
+	if err := client.DeleteIndex(".ds-logs-000001"); err != nil {
+		t.Errorf("Unexpected error deleting a backing index: %v", err)
+	}
+}
+
+func TestAnalyzeIndexesRecordsPolicyState(t *testing.T) {
+	registry := metrics.NewRegistry()
+	log, _ := logger.New(logger.DefaultConfig())
+	indexes := []IndexInfo{
+		{Name: "logs-1", SizeBytes: 100, ManagedBy: ManagedByILM, PolicyName: "logs"},
+		{Name: "logs-2", SizeBytes: 100},
+	}
+
+	for _, name := range []string{"hot", "cold"} {
+		client := NewClient(&config.Config{PolicyName: name, IndexPattern: "logs-*"}, log)
+		client.Metrics = registry
+		client.AnalyzeIndexes(append([]IndexInfo(nil), indexes...))
+	}
+
+	var b strings.Builder
+	registry.WriteTo(&b)
+	for _, expected := range []string{
+		`log_trimmer_indexes_matched{policy="hot"} 2`,
+		`log_trimmer_indexes_matched{policy="cold"} 2`,
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("Expected %s in:\n%s", expected, b.String())
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// dataStreamsResponse is the GET _data_stream response
+type dataStreamsResponse struct {
+	DataStreams []struct {
+		Name    string `json:"name"`
+		Indices []struct {
+			IndexName string `json:"index_name"`
+		} `json:"indices"`
+	} `json:"data_streams"`
+}
+
+// getWriteIndexes returns the data stream each write index belongs to,
+// keyed by index name. The write index is the last backing index.
+func (c *Client) getWriteIndexes() (map[string]string, error) {
+	if err := c.requireCapability(CapabilityDataStreams); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.makeRequest("GET", "/_data_stream/*")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get data streams with status %d", resp.StatusCode)
+	}
+
+	var streams dataStreamsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&streams); err != nil {
+		return nil, fmt.Errorf("failed to decode data streams: %w", err)
+	}
+
+	writes := make(map[string]string)
+	for _, stream := range streams.DataStreams {
+		if len(stream.Indices) > 0 {
+			writes[stream.Indices[len(stream.Indices)-1].IndexName] = stream.Name
+		}
+	}
+	return writes, nil
+}
+
+// markWriteIndexes records which indexes are the write index of a data
+// stream. Clusters without data streams are left alone.
+func (c *Client) markWriteIndexes(indexes []IndexInfo) error {
+	if !c.Supports(CapabilityDataStreams) {
+		return nil
+	}
+
+	writes, err := c.getWriteIndexes()
+	if err != nil {
+		return err
+	}
+	for i := range indexes {
+		indexes[i].WriteIndexOf = writes[indexes[i].Name]
+	}
+	return nil
+}
+
+// filterWriteIndexes removes data stream write indexes from the deletion
+// candidates, since the cluster refuses to delete them
+func (c *Client) filterWriteIndexes(indexes []IndexInfo) ([]IndexInfo, []SkippedIndex) {
+	var candidates []IndexInfo
+	var skipped []SkippedIndex
+	for _, index := range indexes {
+		if index.WriteIndexOf == "" {
+			candidates = append(candidates, index)
+			continue
+		}
+		c.Logger.Info("analysis", "write_index", "Skipping data stream write index", map[string]interface{}{
+			"index":       index.Name,
+			"data_stream": index.WriteIndexOf,
+		})
+		skipped = append(skipped, SkippedIndex{
+			Name:   index.Name,
+			Reason: fmt.Sprintf("write index of data stream %s", index.WriteIndexOf),
+		})
+	}
+	return candidates, skipped
+}
@@ -0,0 +1,337 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NodeAllocation represents a row of _cat/allocation
+type NodeAllocation struct {
+	Node        string `json:"node"`
+	Host        string `json:"host"`
+	Shards      string `json:"shards"`
+	DiskIndices string `json:"disk.indices"`
+	DiskUsed    string `json:"disk.used"`
+	DiskAvail   string `json:"disk.avail"`
+	DiskTotal   string `json:"disk.total"`
+	DiskPercent string `json:"disk.percent"`
+	UsedBytes   int64  // Calculated from DiskUsed
+	TotalBytes  int64  // Calculated from DiskTotal
+}
+
+// UsedPercent returns the disk usage of the node as a percentage
+func (n NodeAllocation) UsedPercent() float64 {
+	if n.TotalBytes == 0 {
+		return 0
+	}
+	return float64(n.UsedBytes) / float64(n.TotalBytes) * 100
+}
+
+// ShardInfo represents a row of _cat/shards
+type ShardInfo struct {
+	Index      string `json:"index"`
+	Shard      string `json:"shard"`
+	PriRep     string `json:"prirep"`
+	State      string `json:"state"`
+	Store      string `json:"store"`
+	Node       string `json:"node"`
+	StoreBytes int64  // Calculated from Store
+}
+
+// DiskWatermarks holds the cluster disk allocation watermarks as configured
+// (either percentages like "90%" or absolute free space like "50gb")
+type DiskWatermarks struct {
+	Low        string `json:"low"`
+	High       string `json:"high"`
+	FloodStage string `json:"flood_stage"`
+}
+
+// DiskTargetResult describes the outcome of the disk watermark filter
+type DiskTargetResult struct {
+	TargetPercent    float64 `json:"target_percent"`
+	HighWatermark    string  `json:"high_watermark"`
+	FullestNode      string  `json:"fullest_node"`
+	FullestPercent   float64 `json:"fullest_percent"`
+	BytesToFree      int64   `json:"bytes_to_free"`
+	ProjectedPercent float64 `json:"projected_percent"`
+	Satisfied        bool    `json:"satisfied"`
+}
+
+// GetAllocation retrieves per-node disk usage from _cat/allocation
+func (c *Client) GetAllocation() ([]NodeAllocation, error) {
+	resp, err := c.makeRequest("GET", "/_cat/allocation?format=json&bytes=b")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get allocation with status %d", resp.StatusCode)
+	}
+
+	var rows []NodeAllocation
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("failed to decode allocation response: %w", err)
+	}
+
+	// Unassigned shards show up as a pseudo node without disk figures
+	var nodes []NodeAllocation
+	for _, row := range rows {
+		if row.Node == "" || row.Node == "UNASSIGNED" || row.DiskTotal == "" {
+			continue
+		}
+		row.UsedBytes, _ = strconv.ParseInt(row.DiskUsed, 10, 64)
+		row.TotalBytes, _ = strconv.ParseInt(row.DiskTotal, 10, 64)
+		nodes = append(nodes, row)
+	}
+
+	return nodes, nil
+}
+
+// GetShards retrieves shard placement for indexes matching the pattern
+func (c *Client) GetShards(pattern string) ([]ShardInfo, error) {
+	path := fmt.Sprintf("/_cat/shards/%s?format=json&bytes=b", pattern)
+	resp, err := c.makeRequest("GET", path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get shards with status %d", resp.StatusCode)
+	}
+
+	var shards []ShardInfo
+	if err := json.NewDecoder(resp.Body).Decode(&shards); err != nil {
+		return nil, fmt.Errorf("failed to decode shards response: %w", err)
+	}
+
+	for i := range shards {
+		shards[i].StoreBytes, _ = strconv.ParseInt(shards[i].Store, 10, 64)
+	}
+
+	return shards, nil
+}
+
+// GetDiskWatermarks retrieves the effective disk watermark cluster settings
+func (c *Client) GetDiskWatermarks() (*DiskWatermarks, error) {
+	resp, err := c.makeRequest("GET", "/_cluster/settings?include_defaults=true&flat_settings=true")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get cluster settings with status %d", resp.StatusCode)
+	}
+
+	var settings map[string]map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
+		return nil, fmt.Errorf("failed to decode cluster settings response: %w", err)
+	}
+
+	// Transient settings override persistent ones, which override defaults
+	lookup := func(key string) string {
+		for _, scope := range []string{"transient", "persistent", "defaults"} {
+			if value, ok := settings[scope][key].(string); ok {
+				return value
+			}
+		}
+		return ""
+	}
+
+	return &DiskWatermarks{
+		Low:        lookup("cluster.routing.allocation.disk.watermark.low"),
+		High:       lookup("cluster.routing.allocation.disk.watermark.high"),
+		FloodStage: lookup("cluster.routing.allocation.disk.watermark.flood_stage"),
+	}, nil
+}
+
+// watermarkPercent converts a watermark into a used-disk percentage for a
+// node with the given capacity. Absolute watermarks describe free space; a
+// bare whole number above 1 is read as bytes.
+func watermarkPercent(watermark string, totalBytes int64) (float64, bool) {
+	watermark = strings.TrimSpace(watermark)
+	if watermark == "" {
+		return 0, false
+	}
+
+	if strings.HasSuffix(watermark, "%") {
+		value, err := strconv.ParseFloat(strings.TrimSuffix(watermark, "%"), 64)
+		if err != nil {
+			return 0, false
+		}
+		return value, true
+	}
+
+	// Ratios such as "0.9" are also accepted by Elasticsearch
+	if value, err := strconv.ParseFloat(watermark, 64); err == nil && value <= 1 {
+		return value * 100, true
+	}
+
+	freeBytes, err := strconv.ParseInt(watermark, 10, 64)
+	if err != nil {
+		freeBytes, err = parseESSize(watermark)
+	}
+	if err != nil || totalBytes == 0 {
+		return 0, false
+	}
+	return float64(totalBytes-freeBytes) / float64(totalBytes) * 100, true
+}
+
+// applyDiskTarget marks additional indexes for deletion until every data
+// node is projected to be below the configured disk usage target
+func (c *Client) applyDiskTarget(indexes, toDelete []IndexInfo) ([]IndexInfo, *DiskTargetResult, error) {
+	nodes, err := c.GetAllocation()
+	if err != nil {
+		return toDelete, nil, err
+	}
+	shards, err := c.GetShards(c.Config.IndexPattern)
+	if err != nil {
+		return toDelete, nil, err
+	}
+	watermarks, err := c.GetDiskWatermarks()
+	if err != nil {
+		return toDelete, nil, err
+	}
+
+	if watermarks.High != "" && len(nodes) > 0 {
+		if _, ok := watermarkPercent(watermarks.High, nodes[0].TotalBytes); !ok {
+			c.Logger.Warn("analysis", "disk_filter", "Could not parse the high disk watermark, the disk target is not capped", map[string]interface{}{
+				"high_watermark": watermarks.High,
+			})
+		}
+	}
+
+	selected, result := selectForDiskTarget(indexes, toDelete, nodes, shards, c.Config.TargetDiskPercent, watermarks.High)
+
+	c.Logger.Info("analysis", "disk_filter", "Applied disk watermark filter", map[string]interface{}{
+		"target_percent":    result.TargetPercent,
+		"high_watermark":    result.HighWatermark,
+		"fullest_node":      result.FullestNode,
+		"fullest_percent":   result.FullestPercent,
+		"bytes_to_free":     result.BytesToFree,
+		"projected_percent": result.ProjectedPercent,
+		"disk_deletes":      len(selected) - len(toDelete),
+	})
+	if !result.Satisfied {
+		c.Logger.Warn("analysis", "disk_filter", "Deleting all matching indexes is not enough to reach the disk target", map[string]interface{}{
+			"fullest_node":      result.FullestNode,
+			"projected_percent": result.ProjectedPercent,
+		})
+	}
+
+	return selected, result, nil
+}
+
+// selectForDiskTarget picks the oldest indexes with shards on over-target
+// nodes until the projected usage of each of those nodes is under target.
+// Indexes must already be sorted oldest first. Partially mounted searchable
+// snapshots only hold a shared cache on the nodes, so deleting them frees no
+// disk and they are never picked or counted as freeing space.
+func selectForDiskTarget(indexes, toDelete []IndexInfo, nodes []NodeAllocation, shards []ShardInfo, target float64, highWatermark string) ([]IndexInfo, *DiskTargetResult) {
+	result := &DiskTargetResult{
+		TargetPercent: target,
+		HighWatermark: highWatermark,
+		Satisfied:     true,
+	}
+
+	// Bytes held by each index on each node
+	placement := make(map[string]map[string]int64)
+	for _, shard := range shards {
+		if shard.Node == "" {
+			continue
+		}
+		if placement[shard.Index] == nil {
+			placement[shard.Index] = make(map[string]int64)
+		}
+		placement[shard.Index][shard.Node] += shard.StoreBytes
+	}
+
+	// Work out how much each node needs to free; the target can never be
+	// above the high watermark
+	used := make(map[string]int64)
+	need := make(map[string]int64)
+	totals := make(map[string]int64)
+	for _, node := range nodes {
+		nodeTarget := target
+		if high, ok := watermarkPercent(highWatermark, node.TotalBytes); ok && high < nodeTarget {
+			nodeTarget = high
+		}
+
+		used[node.Node] = node.UsedBytes
+		totals[node.Node] = node.TotalBytes
+		excess := node.UsedBytes - int64(nodeTarget/100*float64(node.TotalBytes))
+		if excess > 0 {
+			need[node.Node] = excess
+		}
+
+		if node.UsedPercent() > result.FullestPercent {
+			result.FullestPercent = node.UsedPercent()
+			result.FullestNode = node.Node
+			result.BytesToFree = excess
+			if excess < 0 {
+				result.BytesToFree = 0
+			}
+		}
+	}
+
+	free := func(index string) {
+		for node, bytes := range placement[index] {
+			used[node] -= bytes
+			if _, ok := need[node]; ok {
+				need[node] -= bytes
+				if need[node] <= 0 {
+					delete(need, node)
+				}
+			}
+		}
+	}
+
+	// Indexes already selected by other rules free space too
+	marked := make(map[string]bool)
+	for _, index := range toDelete {
+		marked[index.Name] = true
+		if !index.RemoteSnapshot {
+			free(index.Name)
+		}
+	}
+
+	for _, index := range indexes {
+		if len(need) == 0 {
+			break
+		}
+		if marked[index.Name] || index.RemoteSnapshot {
+			continue
+		}
+
+		helps := false
+		for node := range placement[index.Name] {
+			if _, ok := need[node]; ok {
+				helps = true
+				break
+			}
+		}
+		if !helps {
+			continue
+		}
+
+		toDelete = append(toDelete, index)
+		marked[index.Name] = true
+		free(index.Name)
+	}
+
+	result.Satisfied = len(need) == 0
+	for node, bytes := range used {
+		if totals[node] == 0 {
+			continue
+		}
+		if percent := float64(bytes) / float64(totals[node]) * 100; percent > result.ProjectedPercent {
+			result.ProjectedPercent = percent
+		}
+	}
+
+	return toDelete, result
+}
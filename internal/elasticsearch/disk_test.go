@@ -0,0 +1,126 @@
+package elasticsearch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+func TestWatermarkPercent(t *testing.T) {
+	tests := []struct {
+		input    string
+		total    int64
+		expected float64
+		ok       bool
+	}{
+		{"90%", 0, 90, true},
+		{"0.85", 0, 85, true},
+		{"100b", 1000, 90, true},
+		{"500", 1000, 50, true},
+		{"1", 0, 100, true},
+		{"1.5", 1000, 0, false},
+		{"", 1000, 0, false},
+		{"bogus", 1000, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := watermarkPercent(tt.input, tt.total)
+		if ok != tt.ok || got != tt.expected {
+			t.Errorf("For %q, expected %.1f/%v, got %.1f/%v", tt.input, tt.expected, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestSelectForDiskTarget(t *testing.T) {
+	now := time.Now()
+	indexes := []IndexInfo{
+		{Name: "logs-1", CreationDate: now.Add(-72 * time.Hour)},
+		{Name: "logs-2", CreationDate: now.Add(-48 * time.Hour)},
+		{Name: "logs-3", CreationDate: now.Add(-24 * time.Hour)},
+		{Name: "logs-4", CreationDate: now},
+	}
+	nodes := []NodeAllocation{
+		{Node: "node-a", UsedBytes: 900, TotalBytes: 1000},
+		{Node: "node-b", UsedBytes: 500, TotalBytes: 1000},
+	}
+	shards := []ShardInfo{
+		{Index: "logs-1", Node: "node-b", StoreBytes: 200},
+		{Index: "logs-2", Node: "node-a", StoreBytes: 50},
+		{Index: "logs-3", Node: "node-a", StoreBytes: 100},
+		{Index: "logs-4", Node: "node-a", StoreBytes: 100},
+	}
+
+	// node-a needs 100 bytes freed to reach 80%; logs-1 does not help
+	selected, result := selectForDiskTarget(indexes, nil, nodes, shards, 80, "90%")
+	if len(selected) != 2 || selected[0].Name != "logs-2" || selected[1].Name != "logs-3" {
+		t.Fatalf("Expected logs-2 and logs-3 to be selected, got %v", selected)
+	}
+	if result.FullestNode != "node-a" || result.BytesToFree != 100 {
+		t.Errorf("Expected node-a to need 100 bytes, got %s/%d", result.FullestNode, result.BytesToFree)
+	}
+	if !result.Satisfied || result.ProjectedPercent != 75 {
+		t.Errorf("Expected target to be satisfied at 75%%, got %v/%.1f", result.Satisfied, result.ProjectedPercent)
+	}
+
+	// A target above the high watermark is capped at the watermark
+	selected, _ = selectForDiskTarget(indexes, nil, nodes, shards, 95, "85%")
+	if len(selected) != 1 || selected[0].Name != "logs-2" {
+		t.Errorf("Expected target to be capped at the high watermark, got %v", selected)
+	}
+
+	// Partially mounted searchable snapshots free no node disk
+	remote := append([]IndexInfo(nil), indexes...)
+	remote[1].RemoteSnapshot = true
+	selected, _ = selectForDiskTarget(remote, nil, nodes, shards, 80, "90%")
+	if len(selected) != 1 || selected[0].Name != "logs-3" {
+		t.Errorf("Expected the searchable snapshot to be passed over, got %v", selected)
+	}
+
+	// Indexes already selected by other rules count towards the target
+	selected, _ = selectForDiskTarget(indexes, indexes[3:], nodes, shards, 80, "90%")
+	if len(selected) != 1 {
+		t.Errorf("Expected no extra selections, got %d", len(selected)-1)
+	}
+}
+
+func TestAnalyzeIndexesDiskTarget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/_cat/allocation":
+			w.Write([]byte(`[{"node":"node-a","disk.used":"900","disk.total":"1000"},{"node":"UNASSIGNED","shards":"2"}]`))
+		case "/_cat/shards/logs-*":
+			w.Write([]byte(`[{"index":"logs-1","node":"node-a","store":"150"},{"index":"logs-2","node":"node-a","store":"150"}]`))
+		case "/_cluster/settings":
+			w.Write([]byte(`{"persistent":{},"transient":{},"defaults":{"cluster.routing.allocation.disk.watermark.high":"90%"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ESHost: server.URL, IndexPattern: "logs-*", TargetDiskPercent: 80}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	now := time.Now()
+	indexes := []IndexInfo{
+		{Name: "logs-2", CreationDate: now, SizeBytes: 150},
+		{Name: "logs-1", CreationDate: now.Add(-time.Hour), SizeBytes: 150},
+	}
+
+	toDelete, result := client.AnalyzeIndexes(indexes)
+	if len(toDelete) != 1 || toDelete[0].Name != "logs-1" {
+		t.Fatalf("Expected oldest index logs-1 to be deleted, got %v", toDelete)
+	}
+	if result.DiskTarget == nil || result.DiskTarget.HighWatermark != "90%" {
+		t.Errorf("Expected disk target result with high watermark, got %+v", result.DiskTarget)
+	}
+	if result.DeletedSize != 150 {
+		t.Errorf("Expected deleted size 150, got %d", result.DeletedSize)
+	}
+}
@@ -0,0 +1,200 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Distribution identifies the search engine the client is talking to
+type Distribution string
+
+const (
+	DistributionElasticsearch Distribution = "elasticsearch"
+	DistributionOpenSearch    Distribution = "opensearch"
+)
+
+// Capability names a version-specific feature of the cluster
+type Capability string
+
+const (
+	CapabilityDataStreams         Capability = "data streams"
+	CapabilityILM                 Capability = "index lifecycle management (ILM)"
+	CapabilityISM                 Capability = "index state management (ISM)"
+	CapabilitySearchableSnapshots Capability = "searchable snapshots"
+	CapabilityClusterManager      Capability = "cluster_manager node role naming"
+)
+
+// ErrUnsupported is returned when an operation needs a capability the
+// connected cluster does not have
+var ErrUnsupported = errors.New("unsupported by connected cluster")
+
+// ServerInfo describes the cluster returned by GET /
+type ServerInfo struct {
+	ClusterName  string       `json:"cluster_name"`
+	Distribution Distribution `json:"distribution"`
+	Version      string       `json:"version"`
+	Major        int          `json:"-"`
+	Minor        int          `json:"-"`
+	Patch        int          `json:"-"`
+}
+
+// minVersion is the first release of a distribution offering a capability
+type minVersion struct {
+	major, minor int
+}
+
+// capabilityTable lists the minimum version per distribution for each
+// capability; a missing distribution means the capability is never available
+var capabilityTable = map[Capability]map[Distribution]minVersion{
+	CapabilityDataStreams: {
+		DistributionElasticsearch: {7, 9},
+		DistributionOpenSearch:    {1, 0},
+	},
+	CapabilityILM: {
+		DistributionElasticsearch: {6, 6},
+	},
+	CapabilityISM: {
+		DistributionOpenSearch: {1, 0},
+	},
+	CapabilitySearchableSnapshots: {
+		DistributionElasticsearch: {7, 10},
+		DistributionOpenSearch:    {2, 7},
+	},
+	CapabilityClusterManager: {
+		DistributionOpenSearch: {2, 0},
+	},
+}
+
+// rootResponse mirrors the parts of the GET / response we care about
+type rootResponse struct {
+	ClusterName string `json:"cluster_name"`
+	Version     struct {
+		Distribution string `json:"distribution"`
+		Number       string `json:"number"`
+	} `json:"version"`
+}
+
+// Connect queries the cluster root endpoint and records the distribution and
+// version so version-specific behaviour can be routed through Supports
+func (c *Client) Connect() (*ServerInfo, error) {
+	c.Logger.Info("elasticsearch", "connect", "Detecting cluster distribution and version")
+
+	resp, err := c.makeRequest("GET", "/")
+	if err != nil {
+		c.Logger.Error("elasticsearch", "connect", "Failed to query cluster root", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err := fmt.Errorf("cluster root request failed with status %d", resp.StatusCode)
+		c.Logger.Error("elasticsearch", "connect", "Cluster root request failed", err, map[string]interface{}{
+			"status_code": resp.StatusCode,
+		})
+		return nil, err
+	}
+
+	var root rootResponse
+	if err := json.NewDecoder(resp.Body).Decode(&root); err != nil {
+		c.Logger.Error("elasticsearch", "connect", "Failed to decode cluster root response", err)
+		return nil, fmt.Errorf("failed to decode cluster root response: %w", err)
+	}
+
+	info, err := parseServerInfo(root)
+	if err != nil {
+		c.Logger.Error("elasticsearch", "connect", "Unrecognised cluster version", err)
+		return nil, err
+	}
+	c.Server = info
+
+	c.Logger.Success("elasticsearch", "connect", "Connected to cluster", map[string]interface{}{
+		"cluster_name": info.ClusterName,
+		"distribution": info.Distribution,
+		"version":      info.Version,
+	})
+
+	return info, nil
+}
+
+// EnsureConnected detects the cluster distribution and version unless
+// Connect has already succeeded. Runs call it before their first request so
+// capability checks reflect the real cluster.
+func (c *Client) EnsureConnected() error {
+	if c.Server != nil {
+		return nil
+	}
+	_, err := c.Connect()
+	return err
+}
+
+// parseServerInfo converts the root response into a ServerInfo
+func parseServerInfo(root rootResponse) (*ServerInfo, error) {
+	info := &ServerInfo{
+		ClusterName:  root.ClusterName,
+		Distribution: DistributionElasticsearch,
+		Version:      root.Version.Number,
+	}
+	if strings.EqualFold(root.Version.Distribution, string(DistributionOpenSearch)) {
+		info.Distribution = DistributionOpenSearch
+	}
+
+	// Strip pre-release suffixes such as "8.0.0-SNAPSHOT"
+	number := root.Version.Number
+	if idx := strings.IndexAny(number, "-+"); idx != -1 {
+		number = number[:idx]
+	}
+
+	parts := strings.Split(number, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid cluster version '%s'", root.Version.Number)
+	}
+
+	var nums [3]int
+	for i := 0; i < len(parts) && i < 3; i++ {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster version '%s'", root.Version.Number)
+		}
+		nums[i] = n
+	}
+	info.Major, info.Minor, info.Patch = nums[0], nums[1], nums[2]
+
+	return info, nil
+}
+
+// Supports reports whether the connected cluster offers a capability. Before
+// Connect has been called every capability is assumed to be available.
+func (c *Client) Supports(capability Capability) bool {
+	if c.Server == nil {
+		return true
+	}
+
+	min, ok := capabilityTable[capability][c.Server.Distribution]
+	if !ok {
+		return false
+	}
+	if c.Server.Major != min.major {
+		return c.Server.Major > min.major
+	}
+	return c.Server.Minor >= min.minor
+}
+
+// requireCapability returns a descriptive ErrUnsupported error when the
+// connected cluster lacks a capability
+func (c *Client) requireCapability(capability Capability) error {
+	if c.Supports(capability) {
+		return nil
+	}
+	return fmt.Errorf("%s is not available on %s %s: %w", capability, c.Server.Distribution, c.Server.Version, ErrUnsupported)
+}
+
+// CatColumn maps a _cat column name to the one used by the connected cluster
+func (c *Client) CatColumn(name string) string {
+	if name == "master" && c.Server != nil && c.Supports(CapabilityClusterManager) {
+		return "cluster_manager"
+	}
+	return name
+}
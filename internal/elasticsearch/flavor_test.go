@@ -0,0 +1,165 @@
+package elasticsearch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/esfake"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+func TestConnectDetectsDistribution(t *testing.T) {
+	tests := []struct {
+		body         string
+		distribution Distribution
+		major        int
+		minor        int
+	}{
+		{`{"cluster_name":"es","version":{"number":"8.11.1","build_flavor":"default"}}`, DistributionElasticsearch, 8, 11},
+		{`{"cluster_name":"es7","version":{"number":"7.17.0-SNAPSHOT"}}`, DistributionElasticsearch, 7, 17},
+		{`{"cluster_name":"os","version":{"distribution":"opensearch","number":"2.11.0"}}`, DistributionOpenSearch, 2, 11},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(tt.body))
+		}))
+
+		cfg := &config.Config{ESHost: server.URL, SkipTLS: true}
+		log, _ := logger.New(logger.DefaultConfig())
+		client := NewClient(cfg, log)
+
+		info, err := client.Connect()
+		server.Close()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if info.Distribution != tt.distribution || info.Major != tt.major || info.Minor != tt.minor {
+			t.Errorf("Expected %s %d.%d, got %s %d.%d", tt.distribution, tt.major, tt.minor, info.Distribution, info.Major, info.Minor)
+		}
+		if client.Server != info {
+			t.Error("Expected server info to be recorded on the client")
+		}
+	}
+}
+
+func TestSupports(t *testing.T) {
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(&config.Config{}, log)
+
+	// Before connecting everything is assumed to be available
+	if !client.Supports(CapabilityISM) {
+		t.Error("Expected capabilities to be assumed before Connect")
+	}
+
+	tests := []struct {
+		server     ServerInfo
+		capability Capability
+		expected   bool
+	}{
+		{ServerInfo{Distribution: DistributionElasticsearch, Major: 7, Minor: 9}, CapabilityDataStreams, true},
+		{ServerInfo{Distribution: DistributionElasticsearch, Major: 7, Minor: 8}, CapabilityDataStreams, false},
+		{ServerInfo{Distribution: DistributionElasticsearch, Major: 8, Minor: 0}, CapabilityILM, true},
+		{ServerInfo{Distribution: DistributionElasticsearch, Major: 8, Minor: 0}, CapabilityISM, false},
+		{ServerInfo{Distribution: DistributionOpenSearch, Major: 2, Minor: 11}, CapabilityISM, true},
+		{ServerInfo{Distribution: DistributionOpenSearch, Major: 2, Minor: 11}, CapabilityILM, false},
+		{ServerInfo{Distribution: DistributionOpenSearch, Major: 1, Minor: 0}, CapabilityDataStreams, true},
+		{ServerInfo{Distribution: DistributionOpenSearch, Major: 2, Minor: 5}, CapabilitySearchableSnapshots, false},
+		{ServerInfo{Distribution: DistributionElasticsearch, Major: 7, Minor: 10}, CapabilitySearchableSnapshots, true},
+	}
+
+	for _, tt := range tests {
+		server := tt.server
+		client.Server = &server
+		if got := client.Supports(tt.capability); got != tt.expected {
+			t.Errorf("%s %d.%d %s: expected %v, got %v", server.Distribution, server.Major, server.Minor, tt.capability, tt.expected, got)
+		}
+	}
+}
+
+func TestRequireCapabilityError(t *testing.T) {
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(&config.Config{}, log)
+	client.Server = &ServerInfo{Distribution: DistributionElasticsearch, Version: "8.11.1", Major: 8, Minor: 11}
+
+	err := client.requireCapability(CapabilityISM)
+	if !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Expected ErrUnsupported, got %v", err)
+	}
+
+	if col := client.CatColumn("master"); col != "master" {
+		t.Errorf("Expected 'master' column on Elasticsearch, got %s", col)
+	}
+	client.Server = &ServerInfo{Distribution: DistributionOpenSearch, Version: "2.11.0", Major: 2, Minor: 11}
+	if col := client.CatColumn("master"); col != "cluster_manager" {
+		t.Errorf("Expected 'cluster_manager' column on OpenSearch 2.x, got %s", col)
+	}
+}
+
+func TestEnrichDetectsRemoteSnapshots(t *testing.T) {
+	settings := map[string]interface{}{"store.type": "snapshot", "store.snapshot.partial": "true"}
+	for _, tt := range []struct {
+		version string
+		remote  bool
+	}{
+		{"8.11.0", true},
+		{"7.9.0", false},
+	} {
+		server, err := esfake.Start(esfake.Fixture{
+			Version: tt.version,
+			Indexes: []esfake.Index{{Name: "partial-logs-1", Settings: settings}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to start fake cluster: %v", err)
+		}
+
+		log, _ := logger.New(logger.DefaultConfig())
+		client := NewClient(&config.Config{ESHost: server.URL}, log)
+		indexes, err := client.GetIndexes("partial-*")
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.version, err)
+		}
+		if len(indexes) != 1 || indexes[0].RemoteSnapshot != tt.remote {
+			t.Errorf("%s: expected remote snapshot %v, got %+v", tt.version, tt.remote, indexes)
+		}
+	}
+}
+
+func TestElectedMasterUsesClusterManagerColumn(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{"cluster_name":"test","version":{"distribution":"opensearch","number":"2.11.0"}}`))
+		case "/_cat/nodes":
+			if h := r.URL.Query().Get("h"); h != "name,cluster_manager" {
+				t.Errorf("Expected the cluster_manager column, got %s", h)
+			}
+			w.Write([]byte(`[{"name":"node-1","cluster_manager":"-"},{"name":"node-2","cluster_manager":"*"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(&config.Config{ESHost: server.URL}, log)
+	master, err := client.ElectedMaster()
+	if err != nil || master != "node-2" {
+		t.Errorf("Expected node-2 as cluster manager, got %q and %v", master, err)
+	}
+
+	fake, err := esfake.Start(esfake.Fixture{})
+	if err != nil {
+		t.Fatalf("Failed to start fake cluster: %v", err)
+	}
+	defer fake.Close()
+	client = NewClient(&config.Config{ESHost: fake.URL}, log)
+	if master, err := client.ElectedMaster(); err != nil || master != "esfake-0" {
+		t.Errorf("Expected esfake-0 as master on Elasticsearch, got %q and %v", master, err)
+	}
+}
@@ -0,0 +1,190 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/pkg/utils"
+)
+
+// forecastAlpha is the weight of the most recent day in the exponentially
+// weighted moving average of daily ingest
+const forecastAlpha = 0.3
+
+// Forecast projects the growth of the indexes matching a pattern
+type Forecast struct {
+	Policy         string     `json:"policy"`
+	Pattern        string     `json:"pattern"`
+	Indexes        int        `json:"indexes"`
+	SizeBasis      string     `json:"size_basis"`
+	TotalSize      int64      `json:"total_size"`
+	MaxSize        int64      `json:"max_size,omitempty"`
+	HistoryDays    int        `json:"history_days"`
+	DailyIngest    int64      `json:"daily_ingest"`             // Bytes per day
+	RetentionDays  float64    `json:"retention_days,omitempty"` // Days of data MaxSize holds at the current ingest rate
+	MaxAgeDays     float64    `json:"max_age_days,omitempty"`
+	Exceeded       bool       `json:"exceeded"`
+	DaysUntilLimit *float64   `json:"days_until_limit,omitempty"`
+	LimitReachedAt *time.Time `json:"limit_reached_at,omitempty"`
+}
+
+// forecastGrowth estimates daily ingest from the sizes and creation dates of
+// indexes and projects when the total will cross maxSize. Each index's size
+// is attributed to the UTC day it was created; today is left out because
+// its indexes are still being written.
+func forecastGrowth(indexes []IndexInfo, sizeOf func(IndexInfo) int64, maxSize int64, now time.Time) (Forecast, error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	forecast := Forecast{Indexes: len(indexes), MaxSize: maxSize}
+
+	daily := make(map[time.Time]int64)
+	var first time.Time
+	for _, index := range indexes {
+		size := sizeOf(index)
+		forecast.TotalSize += size
+		if index.CreationDate.IsZero() {
+			continue
+		}
+		day := index.CreationDate.UTC().Truncate(24 * time.Hour)
+		if !day.Before(today) {
+			continue
+		}
+		daily[day] += size
+		if first.IsZero() || day.Before(first) {
+			first = day
+		}
+	}
+
+	if first.IsZero() || today.Sub(first) < 2*24*time.Hour {
+		return forecast, fmt.Errorf("not enough history: need indexes created on at least 2 days before today")
+	}
+
+	// Days without new indexes count as zero ingest
+	ewma := float64(daily[first])
+	for day := first.Add(24 * time.Hour); day.Before(today); day = day.Add(24 * time.Hour) {
+		ewma = forecastAlpha*float64(daily[day]) + (1-forecastAlpha)*ewma
+	}
+	forecast.HistoryDays = int(today.Sub(first).Hours() / 24)
+	forecast.DailyIngest = int64(ewma)
+
+	if maxSize <= 0 || forecast.DailyIngest <= 0 {
+		return forecast, nil
+	}
+
+	forecast.RetentionDays = float64(maxSize) / float64(forecast.DailyIngest)
+	days := float64(maxSize-forecast.TotalSize) / float64(forecast.DailyIngest)
+	if days <= 0 {
+		days = 0
+		forecast.Exceeded = true
+	}
+	reachedAt := now.Add(time.Duration(days * 24 * float64(time.Hour)))
+	forecast.DaysUntilLimit = &days
+	forecast.LimitReachedAt = &reachedAt
+
+	return forecast, nil
+}
+
+// Forecast estimates daily ingest for the configured pattern and projects
+// when its total size will cross MaxSize
+func (c *Client) Forecast() (*Forecast, error) {
+	indexes, err := c.GetIndexes(c.Config.IndexPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	forecast, err := forecastGrowth(indexes, c.indexSize, c.Config.MaxSizeBytes, c.now())
+	if err != nil {
+		return nil, fmt.Errorf("cannot forecast %s: %w", c.Config.IndexPattern, err)
+	}
+
+	forecast.Policy = c.Config.PolicyName
+	if forecast.Policy == "" {
+		forecast.Policy = c.Config.IndexPattern
+	}
+	forecast.Pattern = c.Config.IndexPattern
+	forecast.SizeBasis = c.sizeBasis()
+	if c.Config.MaxAgeDuration > 0 {
+		forecast.MaxAgeDays = c.Config.MaxAgeDuration.Hours() / 24
+	}
+
+	c.Logger.Info("forecast", "forecast", "Forecast computed", map[string]interface{}{
+		"pattern":        forecast.Pattern,
+		"daily_ingest":   forecast.DailyIngest,
+		"retention_days": forecast.RetentionDays,
+	})
+	return &forecast, nil
+}
+
+// forecastColumns are the table columns printed by PrintForecasts
+var forecastColumns = []string{"POLICY", "INDEXES", "TOTAL SIZE", "MAX SIZE", "DAILY INGEST", "RETENTION", "LIMIT IN", "LIMIT DATE"}
+
+// forecastWidths are the column widths used by PrintForecasts
+var forecastWidths = []int{24, 8, 12, 12, 12, 10, 10, 12}
+
+// PrintForecasts prints forecasts as a table, JSON or CSV
+func PrintForecasts(forecasts []Forecast, output string) error {
+	switch output {
+	case config.OutputJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(forecasts)
+	case config.OutputCSV:
+		rows := [][]string{{"policy", "indexes", "total_size", "max_size", "daily_ingest", "retention_days", "days_until_limit", "limit_date"}}
+		for _, f := range forecasts {
+			daysUntil, limitDate := "", ""
+			if f.DaysUntilLimit != nil {
+				daysUntil = fmt.Sprintf("%.1f", *f.DaysUntilLimit)
+				limitDate = f.LimitReachedAt.Format("2006-01-02")
+			}
+			rows = append(rows, []string{
+				f.Policy,
+				fmt.Sprintf("%d", f.Indexes),
+				fmt.Sprintf("%d", f.TotalSize),
+				fmt.Sprintf("%d", f.MaxSize),
+				fmt.Sprintf("%d", f.DailyIngest),
+				fmt.Sprintf("%.1f", f.RetentionDays),
+				daysUntil,
+				limitDate,
+			})
+		}
+		return writeCSV(rows)
+	}
+
+	utils.PrintTableHeader(forecastColumns, forecastWidths)
+	for _, f := range forecasts {
+		maxSize, retention, limitIn, limitDate := "-", "-", "-", "-"
+		if f.MaxSize > 0 {
+			maxSize = utils.FormatBytes(f.MaxSize)
+		}
+		if f.RetentionDays > 0 {
+			retention = fmt.Sprintf("%.1fd", f.RetentionDays)
+		}
+		if f.DaysUntilLimit != nil {
+			limitIn = fmt.Sprintf("%.1fd", *f.DaysUntilLimit)
+			limitDate = f.LimitReachedAt.Format("2006-01-02")
+			if f.Exceeded {
+				limitIn = "exceeded"
+			}
+		}
+		utils.PrintTableRow([]string{
+			f.Policy,
+			fmt.Sprintf("%d", f.Indexes),
+			utils.FormatBytes(f.TotalSize),
+			maxSize,
+			utils.FormatBytes(f.DailyIngest) + "/d",
+			retention,
+			limitIn,
+			limitDate,
+		}, forecastWidths)
+	}
+	utils.PrintTableFooter(forecastWidths)
+
+	for _, f := range forecasts {
+		if f.MaxAgeDays > 0 && f.RetentionDays > 0 && f.RetentionDays < f.MaxAgeDays {
+			fmt.Printf("%s: max size holds %.1f days of data, less than the %.0f days max age keeps\n", f.Policy, f.RetentionDays, f.MaxAgeDays)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package elasticsearch
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestForecastGrowth(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	const gb = int64(1 << 30)
+
+	var indexes []IndexInfo
+	for day := 1; day <= 9; day++ {
+		indexes = append(indexes, IndexInfo{
+			Name:         "logs-" + time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC).Format("2006.01.02"),
+			SizeBytes:    10 * gb,
+			CreationDate: time.Date(2024, 3, day, 0, 5, 0, 0, time.UTC),
+		})
+	}
+	// Today's index is still being written and must not drag the rate down
+	indexes = append(indexes, IndexInfo{Name: "logs-today", SizeBytes: gb, CreationDate: now.Add(-time.Hour)})
+
+	size := func(index IndexInfo) int64 { return index.SizeBytes }
+	forecast, err := forecastGrowth(indexes, size, 200*gb, now)
+	if err != nil {
+		t.Fatalf("Expected forecast to succeed, got %v", err)
+	}
+
+	if forecast.DailyIngest != 10*gb {
+		t.Errorf("Expected 10 GB/day, got %d", forecast.DailyIngest)
+	}
+	if forecast.TotalSize != 91*gb || forecast.HistoryDays != 9 {
+		t.Errorf("Expected 91 GB over 9 days of history, got %d over %d", forecast.TotalSize, forecast.HistoryDays)
+	}
+	if forecast.RetentionDays != 20 {
+		t.Errorf("Expected 20 days of retention, got %.1f", forecast.RetentionDays)
+	}
+	if forecast.DaysUntilLimit == nil || math.Abs(*forecast.DaysUntilLimit-10.9) > 0.01 || forecast.Exceeded {
+		t.Errorf("Expected limit in 10.9 days, got %+v", forecast.DaysUntilLimit)
+	}
+
+	exceeded, _ := forecastGrowth(indexes, size, 50*gb, now)
+	if !exceeded.Exceeded || *exceeded.DaysUntilLimit != 0 {
+		t.Errorf("Expected the budget to be exceeded already, got %+v", exceeded)
+	}
+}
+
+func TestForecastGrowthNeedsHistory(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	indexes := []IndexInfo{{Name: "logs-1", SizeBytes: 100, CreationDate: now.Add(-20 * time.Hour)}}
+
+	if _, err := forecastGrowth(indexes, func(i IndexInfo) int64 { return i.SizeBytes }, 1000, now); err == nil {
+		t.Error("Expected error with less than 2 days of history")
+	}
+}
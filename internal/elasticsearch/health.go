@@ -0,0 +1,107 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+)
+
+// healthGatePollInterval is how often a paused run re-checks cluster health
+var healthGatePollInterval = 10 * time.Second
+
+// statusRank orders cluster health statuses from worst to best
+var statusRank = map[string]int{
+	"red":    0,
+	"yellow": 1,
+	"green":  2,
+}
+
+// HealthGateError reports which health preconditions blocked deletion
+type HealthGateError struct {
+	Conditions []string
+}
+
+func (e *HealthGateError) Error() string {
+	return fmt.Sprintf("cluster health gate failed: %s", strings.Join(e.Conditions, "; "))
+}
+
+// evaluateHealthGate returns the preconditions the cluster currently fails
+func evaluateHealthGate(gate config.HealthGateConfig, health *ClusterInfo) []string {
+	var failures []string
+
+	if gate.MinStatus != "" && statusRank[health.Status] < statusRank[gate.MinStatus] {
+		failures = append(failures, fmt.Sprintf("cluster status is %s, need at least %s", health.Status, gate.MinStatus))
+	}
+	if gate.BlockOnRelocating && health.RelocatingShards > 0 {
+		failures = append(failures, fmt.Sprintf("%d shards relocating", health.RelocatingShards))
+	}
+	if gate.BlockOnInitializing && health.InitializingShards > 0 {
+		failures = append(failures, fmt.Sprintf("%d shards initializing", health.InitializingShards))
+	}
+	if gate.MaxPendingTasks > 0 && health.PendingTasks >= gate.MaxPendingTasks {
+		failures = append(failures, fmt.Sprintf("%d pending tasks, need fewer than %d", health.PendingTasks, gate.MaxPendingTasks))
+	}
+
+	return failures
+}
+
+// CheckHealthGate checks the configured health preconditions once and
+// returns a HealthGateError describing any that fail
+func (c *Client) CheckHealthGate() error {
+	gate := c.Config.HealthGate
+	if !gate.Enabled() {
+		return nil
+	}
+
+	health, err := c.GetClusterHealth()
+	if err != nil {
+		return err
+	}
+
+	if failures := evaluateHealthGate(gate, health); len(failures) > 0 {
+		return &HealthGateError{Conditions: failures}
+	}
+	return nil
+}
+
+// WaitForHealthGate checks the health preconditions and, when the gate is
+// configured to pause, keeps re-checking until they pass or the timeout
+// expires. A failing gate is returned as a HealthGateError.
+func (c *Client) WaitForHealthGate(ctx context.Context) error {
+	err := c.CheckHealthGate()
+	if err == nil || c.Config.HealthGate.Action != config.HealthGatePause {
+		if err != nil {
+			c.Logger.Error("health", "gate", "Cluster health gate blocked deletion", err)
+		}
+		return err
+	}
+
+	deadline := time.Now().Add(c.Config.HealthGate.TimeoutDuration)
+	c.Logger.Warn("health", "gate", "Cluster health gate failed, pausing deletions", map[string]interface{}{
+		"error":   err.Error(),
+		"timeout": c.Config.HealthGate.Timeout,
+	})
+
+	ticker := time.NewTicker(healthGatePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if err = c.CheckHealthGate(); err == nil {
+			c.Logger.Info("health", "gate", "Cluster health gate passed, resuming deletions")
+			return nil
+		}
+		if time.Now().After(deadline) {
+			c.Logger.Error("health", "gate", "Cluster health gate still failing after timeout", err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package elasticsearch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+func TestEvaluateHealthGate(t *testing.T) {
+	gate := config.HealthGateConfig{
+		MinStatus:           "green",
+		BlockOnRelocating:   true,
+		BlockOnInitializing: true,
+		MaxPendingTasks:     5,
+	}
+
+	healthy := &ClusterInfo{Status: "green", PendingTasks: 4}
+	if failures := evaluateHealthGate(gate, healthy); len(failures) != 0 {
+		t.Errorf("Expected healthy cluster to pass, got %v", failures)
+	}
+
+	unhealthy := &ClusterInfo{Status: "yellow", RelocatingShards: 2, InitializingShards: 1, PendingTasks: 5}
+	if failures := evaluateHealthGate(gate, unhealthy); len(failures) != 4 {
+		t.Errorf("Expected 4 failed conditions, got %v", failures)
+	}
+
+	gate = config.HealthGateConfig{MinStatus: "yellow"}
+	if failures := evaluateHealthGate(gate, &ClusterInfo{Status: "yellow"}); len(failures) != 0 {
+		t.Errorf("Expected yellow cluster to pass a yellow gate, got %v", failures)
+	}
+}
+
+func TestDeleteIndexesStopsOnHealthGate(t *testing.T) {
+	var deletes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "DELETE" {
+			atomic.AddInt32(&deletes, 1)
+			w.Write([]byte(`{"acknowledged":true}`))
+			return
+		}
+		// The cluster starts relocating after the first deletion
+		if atomic.LoadInt32(&deletes) == 0 {
+			w.Write([]byte(`{"status":"green"}`))
+		} else {
+			w.Write([]byte(`{"status":"green","relocating_shards":3}`))
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		ESHost:     server.URL,
+		HealthGate: config.HealthGateConfig{BlockOnRelocating: true, Action: config.HealthGateAbort},
+	}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	report := client.DeleteIndexes(context.Background(), []IndexInfo{{Name: "logs-1"}, {Name: "logs-2"}, {Name: "logs-3"}})
+	if report.Deleted != 1 || !report.Aborted {
+		t.Fatalf("Expected run to abort after one deletion, got %+v", report)
+	}
+	if len(report.NotAttempted) != 2 || len(report.BlockedBy) != 1 {
+		t.Errorf("Expected 2 indexes left and one blocking condition, got %v / %v", report.NotAttempted, report.BlockedBy)
+	}
+}
+
+func TestWaitForHealthGatePauses(t *testing.T) {
+	var checks int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&checks, 1) < 3 {
+			w.Write([]byte(`{"status":"red"}`))
+		} else {
+			w.Write([]byte(`{"status":"green"}`))
+		}
+	}))
+	defer server.Close()
+
+	oldInterval := healthGatePollInterval
+	healthGatePollInterval = 10 * time.Millisecond
+	defer func() { healthGatePollInterval = oldInterval }()
+
+	cfg := &config.Config{
+		ESHost: server.URL,
+		HealthGate: config.HealthGateConfig{
+			MinStatus:       "yellow",
+			Action:          config.HealthGatePause,
+			TimeoutDuration: time.Second,
+		},
+	}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	if err := client.WaitForHealthGate(context.Background()); err != nil {
+		t.Fatalf("Expected paused gate to pass once the cluster recovers, got %v", err)
+	}
+	if atomic.LoadInt32(&checks) != 3 {
+		t.Errorf("Expected 3 health checks, got %d", checks)
+	}
+}
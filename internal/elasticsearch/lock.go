@@ -0,0 +1,356 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// ExitCodeLockHeld is the process exit code for runs that could not acquire
+// the run lock because another instance holds it
+const ExitCodeLockHeld = 3
+
+// LockDocument is the lease stored in the lock index
+type LockDocument struct {
+	Owner      string    `json:"owner"`
+	Host       string    `json:"host"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	RenewedAt  time.Time `json:"renewed_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// LockHeldError is returned when another owner holds an unexpired lease
+type LockHeldError struct {
+	Name   string
+	Holder LockDocument
+}
+
+func (e *LockHeldError) Error() string {
+	return fmt.Sprintf("run lock %s is held by %s on %s until %s", e.Name, e.Holder.Owner, e.Holder.Host, e.Holder.ExpiresAt.Format(time.RFC3339))
+}
+
+// ExitCode returns the process exit code for a run that found the lock held
+func (e *LockHeldError) ExitCode() int {
+	return ExitCodeLockHeld
+}
+
+// errLockConflict signals a failed compare-and-swap on the lock document
+var errLockConflict = errors.New("lock document changed concurrently")
+
+// Lock is a lease on a document in the lock index. The lease is renewed in
+// the background until Release is called; if a renewal finds that someone
+// else took over, Lost is closed.
+type Lock struct {
+	client      *Client
+	index       string
+	name        string
+	owner       string
+	ttl         time.Duration
+	doc         LockDocument
+	seqNo       int64
+	primaryTerm int64
+
+	mu          sync.Mutex
+	stop        chan struct{}
+	done        chan struct{}
+	lost        chan struct{}
+	lostOnce    sync.Once
+	releaseOnce sync.Once
+	releaseErr  error
+}
+
+// lockWriteResponse holds the concurrency control fields of a write
+type lockWriteResponse struct {
+	SeqNo       int64 `json:"_seq_no"`
+	PrimaryTerm int64 `json:"_primary_term"`
+}
+
+// lockGetResponse is the GET response for the lock document
+type lockGetResponse struct {
+	Found       bool         `json:"found"`
+	SeqNo       int64        `json:"_seq_no"`
+	PrimaryTerm int64        `json:"_primary_term"`
+	Source      LockDocument `json:"_source"`
+}
+
+// lockOwner identifies this process as host:pid
+func lockOwner() (string, string) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid()), host
+}
+
+// AcquireLock takes the named run lock stored in the configured lock index.
+// A new lease is created with op_type=create; an expired lease is taken
+// over with a seq_no/primary_term compare-and-swap. If an unexpired lease
+// is held elsewhere a LockHeldError is returned.
+func (c *Client) AcquireLock(name string) (*Lock, error) {
+	owner, host := lockOwner()
+	now := time.Now().UTC()
+
+	l := &Lock{
+		client: c,
+		index:  c.Config.LockIndex,
+		name:   name,
+		owner:  owner,
+		ttl:    c.Config.LockTTLDuration,
+		doc: LockDocument{
+			Owner:      owner,
+			Host:       host,
+			AcquiredAt: now,
+			RenewedAt:  now,
+			ExpiresAt:  now.Add(c.Config.LockTTLDuration),
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+		lost: make(chan struct{}),
+	}
+
+	path := fmt.Sprintf("/%s/_doc/%s?op_type=create&refresh=true", l.index, url.PathEscape(name))
+	resp, err := c.makeRequestWithBody("PUT", path, l.doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire run lock: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		if err := l.readVersion(resp); err != nil {
+			return nil, err
+		}
+	case http.StatusConflict:
+		if err := l.takeOverExpired(); err != nil {
+			return nil, err
+		}
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to acquire run lock with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	c.Logger.Success("lock", "acquire", "Acquired run lock", map[string]interface{}{
+		"lock":       name,
+		"owner":      owner,
+		"expires_at": l.doc.ExpiresAt,
+	})
+
+	go l.heartbeat()
+	return l, nil
+}
+
+// takeOverExpired replaces an existing lease if it has expired
+func (l *Lock) takeOverExpired() error {
+	path := fmt.Sprintf("/%s/_doc/%s", l.index, url.PathEscape(l.name))
+	resp, err := l.client.makeRequest("GET", path)
+	if err != nil {
+		return fmt.Errorf("failed to read run lock: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var current lockGetResponse
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(&current); err != nil {
+			return fmt.Errorf("failed to decode run lock: %w", err)
+		}
+	case http.StatusNotFound:
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to read run lock with status %d: %s", resp.StatusCode, string(body))
+	}
+	if !current.Found {
+		// Released between our create and read; let the caller retry
+		return &LockHeldError{Name: l.name, Holder: LockDocument{Owner: "unknown", Host: "unknown"}}
+	}
+
+	if time.Now().Before(current.Source.ExpiresAt) {
+		l.client.Logger.Warn("lock", "acquire", "Run lock is held by another instance", map[string]interface{}{
+			"lock":       l.name,
+			"holder":     current.Source.Owner,
+			"host":       current.Source.Host,
+			"expires_at": current.Source.ExpiresAt,
+		})
+		return &LockHeldError{Name: l.name, Holder: current.Source}
+	}
+
+	l.client.Logger.Warn("lock", "acquire", "Taking over expired run lock", map[string]interface{}{
+		"lock":       l.name,
+		"holder":     current.Source.Owner,
+		"expired_at": current.Source.ExpiresAt,
+	})
+
+	l.seqNo, l.primaryTerm = current.SeqNo, current.PrimaryTerm
+	if err := l.compareAndSwap(l.doc); err != nil {
+		if errors.Is(err, errLockConflict) {
+			return &LockHeldError{Name: l.name, Holder: current.Source}
+		}
+		return err
+	}
+	return nil
+}
+
+// compareAndSwap writes doc as the lease only if the document is unchanged
+// since we last saw it
+func (l *Lock) compareAndSwap(doc LockDocument) error {
+	path := fmt.Sprintf("/%s/_doc/%s?if_seq_no=%d&if_primary_term=%d&refresh=true", l.index, url.PathEscape(l.name), l.seqNo, l.primaryTerm)
+	resp, err := l.client.makeRequestWithBody("PUT", path, doc)
+	if err != nil {
+		return fmt.Errorf("failed to write run lock: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return l.readVersion(resp)
+	case http.StatusConflict:
+		return errLockConflict
+	default:
+		return fmt.Errorf("failed to write run lock with status %d", resp.StatusCode)
+	}
+}
+
+// readVersion records the seq_no and primary_term of a successful write
+func (l *Lock) readVersion(resp *http.Response) error {
+	var written lockWriteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&written); err != nil {
+		return fmt.Errorf("failed to decode run lock response: %w", err)
+	}
+	l.seqNo, l.primaryTerm = written.SeqNo, written.PrimaryTerm
+	return nil
+}
+
+// heartbeat renews the lease at a third of the TTL until stopped
+func (l *Lock) heartbeat() {
+	defer close(l.done)
+
+	interval := l.ttl / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := l.Renew(); err != nil {
+			l.client.Logger.Error("lock", "renew", "Failed to renew run lock", err, map[string]interface{}{
+				"lock": l.name,
+			})
+			if errors.Is(err, errLockConflict) {
+				l.lostOnce.Do(func() { close(l.lost) })
+				return
+			}
+		}
+	}
+}
+
+// Renew extends the lease by the TTL. The local copy of the lease only
+// changes once the write succeeded.
+func (l *Lock) Renew() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now().UTC()
+	doc := l.doc
+	doc.RenewedAt = now
+	doc.ExpiresAt = now.Add(l.ttl)
+	if err := l.compareAndSwap(doc); err != nil {
+		return err
+	}
+	l.doc = doc
+	return nil
+}
+
+// Lost is closed when the lease was taken over by another owner
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Owner returns the owner identifier written to the lease
+func (l *Lock) Owner() string {
+	return l.owner
+}
+
+// Release stops the heartbeat and deletes the lease if we still own it.
+// Later calls return the result of the first.
+func (l *Lock) Release() error {
+	l.releaseOnce.Do(func() { l.releaseErr = l.release() })
+	return l.releaseErr
+}
+
+// release does the work of Release
+func (l *Lock) release() error {
+	close(l.stop)
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	path := fmt.Sprintf("/%s/_doc/%s?if_seq_no=%d&if_primary_term=%d&refresh=true", l.index, url.PathEscape(l.name), l.seqNo, l.primaryTerm)
+	resp, err := l.client.makeRequest("DELETE", path)
+	if err != nil {
+		return fmt.Errorf("failed to release run lock: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNotFound:
+	case http.StatusConflict:
+		return fmt.Errorf("run lock %s was taken over before release", l.name)
+	default:
+		return fmt.Errorf("failed to release run lock with status %d", resp.StatusCode)
+	}
+
+	l.client.Logger.Info("lock", "release", "Released run lock", map[string]interface{}{
+		"lock": l.name,
+	})
+	return nil
+}
+
+// WithRunLock runs fn while holding the configured run lock. When no lock
+// index is configured fn runs unguarded. The context passed to fn is
+// cancelled if the lease is lost while fn is running.
+func (c *Client) WithRunLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	if c.Config.LockIndex == "" {
+		return fn(ctx)
+	}
+
+	lock, err := c.AcquireLock(c.Config.LockName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			c.Logger.Warn("lock", "release", "Failed to release run lock", map[string]interface{}{
+				"lock":  c.Config.LockName,
+				"error": err.Error(),
+			})
+		}
+	}()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-lock.Lost():
+			c.Logger.Error("lock", "lost", "Run lock was taken over, stopping run", nil)
+			cancel()
+		case <-runCtx.Done():
+		}
+	}()
+
+	return fn(runCtx)
+}
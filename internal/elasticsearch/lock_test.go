@@ -0,0 +1,215 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+// lockServer simulates a single lock document with create and
+// compare-and-swap semantics
+type lockServer struct {
+	mu    sync.Mutex
+	doc   *LockDocument
+	seqNo int64
+}
+
+func (s *lockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	if ifSeqNo := query.Get("if_seq_no"); ifSeqNo != "" {
+		seqNo, _ := strconv.ParseInt(ifSeqNo, 10, 64)
+		if s.doc == nil || seqNo != s.seqNo {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+	}
+
+	switch r.Method {
+	case "PUT":
+		if query.Get("op_type") == "create" && s.doc != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		var doc LockDocument
+		json.NewDecoder(r.Body).Decode(&doc)
+		s.doc = &doc
+		s.seqNo++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"_seq_no":%d,"_primary_term":1}`, s.seqNo)
+	case "GET":
+		if s.doc == nil {
+			w.Write([]byte(`{"found":false}`))
+			return
+		}
+		json.NewEncoder(w).Encode(lockGetResponse{Found: true, SeqNo: s.seqNo, PrimaryTerm: 1, Source: *s.doc})
+	case "DELETE":
+		s.doc = nil
+		w.Write([]byte(`{"result":"deleted"}`))
+	}
+}
+
+func newLockTestClient(t *testing.T, server *httptest.Server) *Client {
+	t.Helper()
+	cfg := &config.Config{
+		ESHost:          server.URL,
+		LockIndex:       "log-trimmer-locks",
+		LockName:        "log-trimmer",
+		LockTTLDuration: time.Minute,
+	}
+	log, _ := logger.New(logger.DefaultConfig())
+	return NewClient(cfg, log)
+}
+
+func TestAcquireAndReleaseLock(t *testing.T) {
+	ls := &lockServer{}
+	server := httptest.NewServer(ls)
+	defer server.Close()
+	client := newLockTestClient(t, server)
+
+	lock, err := client.AcquireLock("log-trimmer")
+	if err != nil {
+		t.Fatalf("Expected to acquire lock, got %v", err)
+	}
+
+	_, err = client.AcquireLock("log-trimmer")
+	var held *LockHeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("Expected LockHeldError for a held lock, got %v", err)
+	}
+	if held.Holder.Owner != lock.Owner() {
+		t.Errorf("Expected holder %s, got %s", lock.Owner(), held.Holder.Owner)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Expected release to succeed, got %v", err)
+	}
+	if ls.doc != nil {
+		t.Error("Expected lock document to be deleted on release")
+	}
+}
+
+func TestAcquireLockTakesOverExpiredLease(t *testing.T) {
+	ls := &lockServer{
+		doc:   &LockDocument{Owner: "other:1", Host: "other", ExpiresAt: time.Now().Add(-time.Minute)},
+		seqNo: 7,
+	}
+	server := httptest.NewServer(ls)
+	defer server.Close()
+	client := newLockTestClient(t, server)
+
+	lock, err := client.AcquireLock("log-trimmer")
+	if err != nil {
+		t.Fatalf("Expected to take over expired lease, got %v", err)
+	}
+	defer lock.Release()
+
+	if ls.doc.Owner != lock.Owner() {
+		t.Errorf("Expected lease to be owned by %s, got %s", lock.Owner(), ls.doc.Owner)
+	}
+}
+
+func TestRenewDetectsTakeover(t *testing.T) {
+	ls := &lockServer{}
+	server := httptest.NewServer(ls)
+	defer server.Close()
+	client := newLockTestClient(t, server)
+
+	lock, err := client.AcquireLock("log-trimmer")
+	if err != nil {
+		t.Fatalf("Expected to acquire lock, got %v", err)
+	}
+	defer lock.Release()
+
+	// Another instance overwrites the lease
+	ls.mu.Lock()
+	ls.seqNo++
+	ls.mu.Unlock()
+
+	if err := lock.Renew(); !errors.Is(err, errLockConflict) {
+		t.Errorf("Expected renew to report a conflict, got %v", err)
+	}
+}
+
+func TestAcquireLockReportsReadFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PUT":
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+	client := newLockTestClient(t, server)
+
+	_, err := client.AcquireLock("log-trimmer")
+	var held *LockHeldError
+	if err == nil || errors.As(err, &held) {
+		t.Errorf("Expected a read failure instead of a held lock, got %v", err)
+	}
+}
+
+func TestReleaseTwice(t *testing.T) {
+	ls := &lockServer{}
+	server := httptest.NewServer(ls)
+	defer server.Close()
+	client := newLockTestClient(t, server)
+
+	lock, err := client.AcquireLock("log-trimmer")
+	if err != nil {
+		t.Fatalf("Expected to acquire lock, got %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Expected release to succeed, got %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Errorf("Expected a second release to be a no-op, got %v", err)
+	}
+}
+
+func TestRenewKeepsLeaseOnConflict(t *testing.T) {
+	ls := &lockServer{}
+	server := httptest.NewServer(ls)
+	defer server.Close()
+	client := newLockTestClient(t, server)
+
+	lock, err := client.AcquireLock("log-trimmer")
+	if err != nil {
+		t.Fatalf("Expected to acquire lock, got %v", err)
+	}
+	defer lock.Release()
+	expires := lock.doc.ExpiresAt
+
+	ls.mu.Lock()
+	ls.seqNo++
+	ls.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+	if err := lock.Renew(); !errors.Is(err, errLockConflict) {
+		t.Fatalf("Expected renew to report a conflict, got %v", err)
+	}
+	if !lock.doc.ExpiresAt.Equal(expires) {
+		t.Errorf("Expected expiry to stay %v after a failed renew, got %v", expires, lock.doc.ExpiresAt)
+	}
+}
+
+func TestLockHeldErrorExitCode(t *testing.T) {
+	var err error = &LockHeldError{Name: "log-trimmer"}
+	var coded interface{ ExitCode() int }
+	if !errors.As(err, &coded) || coded.ExitCode() != ExitCodeLockHeld {
+		t.Errorf("Expected exit code %d", ExitCodeLockHeld)
+	}
+}
@@ -0,0 +1,148 @@
+package elasticsearch
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/company/log-trimmer/pkg/utils"
+)
+
+// Plan is the outcome of analysing one policy: the indexes that would be
+// deleted and the analysis summary
+type Plan struct {
+	ID        string         `json:"id"`
+	Policy    string         `json:"policy"`
+	Pattern   string         `json:"pattern"`
+	CreatedAt time.Time      `json:"created_at"`
+	ToDelete  []IndexInfo    `json:"to_delete"`
+	Result    AnalysisResult `json:"result"`
+}
+
+// BuildPlan retrieves the indexes matching the configured pattern and
+// analyses them for deletion
+func (c *Client) BuildPlan() (*Plan, error) {
+	indexes, err := c.GetIndexes(c.Config.IndexPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	toDelete, result := c.AnalyzeIndexes(indexes)
+	if c.Config.MaxShards > 0 {
+		c.projectClusterShards(&result)
+	}
+
+	return &Plan{
+		ID:        newPlanID(),
+		Policy:    c.policyLabel(),
+		Pattern:   c.Config.IndexPattern,
+		CreatedAt: time.Now(),
+		ToDelete:  toDelete,
+		Result:    result,
+	}, nil
+}
+
+// policyLabel names the configured policy, falling back to its index
+// pattern for single-policy configs
+func (c *Client) policyLabel() string {
+	if c.Config.PolicyName != "" {
+		return c.Config.PolicyName
+	}
+	return c.Config.IndexPattern
+}
+
+// projectClusterShards records the cluster-wide shard total and what it
+// drops to once the plan's deletions are applied. It is fetched here, once
+// per plan, so the analysis itself makes no requests.
+func (c *Client) projectClusterShards(result *AnalysisResult) {
+	health, err := c.GetClusterHealth()
+	if err != nil {
+		c.Logger.Warn("analysis", "shard_filter", "Could not get cluster shard total", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	result.ClusterShards = health.ActiveShards + health.UnassignedShards
+	result.ProjectedClusterShards = result.ClusterShards - result.DeletedShards
+
+	c.Logger.Info("analysis", "shard_filter", "Projected cluster shard total", map[string]interface{}{
+		"cluster_shards":           result.ClusterShards,
+		"projected_cluster_shards": result.ProjectedClusterShards,
+	})
+}
+
+// newPlanID returns a random identifier for a plan
+func newPlanID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// planColumns are the table columns printed for each index in a deletion plan
+var planColumns = []string{"INDEX", "CREATED", "TOTAL SIZE", "PRIMARY SIZE", "DOCS", "SHARDS", "REASON"}
+
+// planWidths are the column widths used by PrintPlan
+var planWidths = []int{40, 20, 12, 12, 10, 8, 16}
+
+// PlanRow returns the table cells describing an index in a deletion plan
+// along with the rules that selected it. Uncertain sizes are prefixed
+// with "~".
+func PlanRow(index IndexInfo, reasons []string) []string {
+	created := "unknown"
+	if !index.CreationDate.IsZero() {
+		created = index.CreationDate.Format("2006-01-02 15:04")
+	}
+
+	totalSize, primarySize := utils.FormatBytes(index.SizeBytes), utils.FormatBytes(index.PrimaryBytes)
+	if index.SizeUncertain {
+		totalSize, primarySize = "~"+totalSize, "~"+primarySize
+	}
+
+	return []string{
+		index.Name,
+		created,
+		totalSize,
+		primarySize,
+		utils.FormatNumber(index.DocsCount),
+		fmt.Sprintf("%d", index.ShardCopies()),
+		strings.Join(reasons, "+"),
+	}
+}
+
+// PrintPlan prints the indexes selected for deletion and any planned
+// replica reductions, followed by a summary of the analysis
+func PrintPlan(toDelete []IndexInfo, result AnalysisResult) {
+	utils.PrintTableHeader(planColumns, planWidths)
+	for _, index := range toDelete {
+		utils.PrintTableRow(PlanRow(index, result.Reasons[index.Name]), planWidths)
+	}
+	utils.PrintTableFooter(planWidths)
+
+	if len(result.Reductions) > 0 {
+		fmt.Println("Replicas to drop:")
+		printReductions(result.Reductions)
+	}
+
+	fmt.Printf("Indexes to delete: %d of %d\n", result.ToDelete, result.TotalIndexes)
+	if len(result.Reductions) > 0 {
+		fmt.Printf("Indexes to drop replicas: %d (%s)\n", len(result.Reductions), utils.FormatBytes(result.ReducedSize))
+	}
+	fmt.Printf("Size basis: %s (%s of %s)\n", result.SizeBasis, utils.FormatBytes(result.DeletedSize), utils.FormatBytes(result.TotalSize))
+	fmt.Printf("Projected size: %s\n", utils.FormatBytes(result.ProjectedSize))
+	fmt.Printf("Reclaimed space: %s total, %s primary\n", utils.FormatBytes(result.ReclaimedTotal), utils.FormatBytes(result.ReclaimedPrimary))
+	fmt.Printf("Shard copies: %d of %d\n", result.DeletedShards, result.TotalShards)
+	if result.ClusterShards > 0 {
+		fmt.Printf("Cluster shard total: %d -> %d\n", result.ClusterShards, result.ProjectedClusterShards)
+	}
+
+	for _, skipped := range result.Skipped {
+		fmt.Printf("Skipped %s: %s\n", skipped.Name, skipped.Reason)
+	}
+	if len(result.Uncertain) > 0 {
+		fmt.Printf("Uncertain sizes (closed or red indexes): %s\n", strings.Join(result.Uncertain, ", "))
+	}
+}
@@ -0,0 +1,198 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/company/log-trimmer/internal/ratelimit"
+	"github.com/company/log-trimmer/pkg/utils"
+)
+
+// ActionReduceReplicas marks outcomes that dropped an index's replicas
+// rather than deleting it
+const ActionReduceReplicas = "reduce_replicas"
+
+// ReplicaReduction is a planned drop of an index's replicas to zero
+type ReplicaReduction struct {
+	Index          string `json:"index"`
+	Replicas       int    `json:"replicas"` // Replicas before the reduction
+	SizeBytes      int64  `json:"size_bytes"`
+	PrimaryBytes   int64  `json:"primary_bytes"`
+	ReclaimedBytes int64  `json:"reclaimed_bytes"` // store.size minus pri.store.size
+}
+
+// planReplicaReductions plans dropping the replicas of the oldest indexes
+// not already selected for deletion until at least excess bytes are
+// reclaimed. Closed indexes and indexes with uncertain sizes are left out.
+func (c *Client) planReplicaReductions(indexes, toDelete []IndexInfo, excess int64, result *AnalysisResult) {
+	marked := make(map[string]bool)
+	for _, index := range toDelete {
+		marked[index.Name] = true
+	}
+
+	for _, index := range indexes {
+		if result.ReducedSize >= excess {
+			break
+		}
+		reclaimed := index.SizeBytes - index.PrimaryBytes
+		if marked[index.Name] || index.Replicas == 0 || reclaimed <= 0 || index.IsClosed() || index.SizeUncertain {
+			continue
+		}
+		result.Reductions = append(result.Reductions, ReplicaReduction{
+			Index:          index.Name,
+			Replicas:       index.Replicas,
+			SizeBytes:      index.SizeBytes,
+			PrimaryBytes:   index.PrimaryBytes,
+			ReclaimedBytes: reclaimed,
+		})
+		result.ReducedSize += reclaimed
+	}
+
+	c.Logger.Info("analysis", "replica_filter", "Planned replica reductions", map[string]interface{}{
+		"excess_size":  excess,
+		"reductions":   len(result.Reductions),
+		"reduced_size": result.ReducedSize,
+	})
+}
+
+// dropReduction removes the planned replica reduction of an index that is
+// now selected for deletion
+func (r *AnalysisResult) dropReduction(name string) {
+	for i, reduction := range r.Reductions {
+		if reduction.Index == name {
+			r.ReducedSize -= reduction.ReclaimedBytes
+			r.Reductions = append(r.Reductions[:i], r.Reductions[i+1:]...)
+			return
+		}
+	}
+}
+
+// ReduceReplicas sets number_of_replicas to zero on each planned index,
+// going through the same rate limits, maintenance window and cluster health
+// gate checks as DeleteIndexes before each change. It stops at the first blocked change and returns the outcomes so
+// far, the indexes not attempted and the blocking error.
+func (c *Client) ReduceReplicas(ctx context.Context, reductions []ReplicaReduction) ([]DeletionOutcome, []string, error) {
+	limiter := ratelimit.New(c.Config.MaxDeletesPerMinute, c.Config.MaxBytesPerMinuteBytes, c.Config.DeletePauseDuration)
+
+	var outcomes []DeletionOutcome
+	for i, reduction := range reductions {
+		var throttled time.Duration
+		err := ctx.Err()
+		if err == nil {
+			throttled, err = limiter.Wait(ctx, reduction.ReclaimedBytes)
+		}
+		if err == nil {
+			err = c.waitForWindow(ctx, i > 0)
+		}
+		if err == nil {
+			err = c.WaitForHealthGate(ctx)
+		}
+		if err != nil {
+			var remaining []string
+			for _, rest := range reductions[i:] {
+				remaining = append(remaining, rest.Index)
+			}
+			return outcomes, remaining, err
+		}
+
+		start := time.Now()
+		err = c.expectOK("PUT", fmt.Sprintf("/%s/_settings", reduction.Index), map[string]interface{}{"index.number_of_replicas": 0})
+		limiter.Done()
+		outcome := DeletionOutcome{
+			Index:     reduction.Index,
+			SizeBytes: reduction.ReclaimedBytes,
+			Duration:  time.Since(start),
+			Throttled: throttled,
+			Action:    ActionReduceReplicas,
+		}
+		if err != nil {
+			outcome.Error = err.Error()
+			c.Logger.Error("elasticsearch", "reduce_replicas", "Failed to drop replicas", err, map[string]interface{}{
+				"index": reduction.Index,
+			})
+		} else {
+			c.Logger.Success("elasticsearch", "reduce_replicas", "Dropped replicas", map[string]interface{}{
+				"index":     reduction.Index,
+				"replicas":  reduction.Replicas,
+				"reclaimed": reduction.ReclaimedBytes,
+			})
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes, nil, nil
+}
+
+// ApplyPlan carries out a plan: it drops the planned replicas first and
+// then deletes the selected indexes. If the replica reductions are blocked
+// no deletions are attempted.
+func (c *Client) ApplyPlan(ctx context.Context, plan *Plan) *DeletionReport {
+	if len(plan.Result.Reductions) == 0 {
+		return c.Apply(ctx, plan.ToDelete)
+	}
+
+	start := time.Now()
+	outcomes, remaining, err := c.ReduceReplicas(ctx, plan.Result.Reductions)
+
+	var report *DeletionReport
+	if err != nil {
+		report = &DeletionReport{NotAttempted: remaining}
+		report.block(err)
+		for _, index := range plan.ToDelete {
+			report.NotAttempted = append(report.NotAttempted, index.Name)
+		}
+	} else {
+		report = c.Apply(ctx, plan.ToDelete)
+	}
+	report.StartTime = start
+
+	for _, outcome := range outcomes {
+		report.Throttled += outcome.Throttled
+		if outcome.Error != "" {
+			report.Failed++
+			continue
+		}
+		report.Reduced++
+		report.ReducedBytes += outcome.SizeBytes
+	}
+	report.Outcomes = append(outcomes, report.Outcomes...)
+
+	if err != nil {
+		report.EndTime = time.Now()
+		c.Metrics.RecordRun(false, report.EndTime)
+	}
+	return report
+}
+
+// HasActions reports whether applying the plan would change anything
+func (p *Plan) HasActions() bool {
+	return len(p.ToDelete) > 0 || len(p.Result.Reductions) > 0
+}
+
+// reductionColumns are the table columns printed for planned replica
+// reductions
+var reductionColumns = []string{"INDEX", "REPLICAS", "TOTAL SIZE", "PRIMARY SIZE", "RECLAIMED"}
+
+// reductionWidths are the column widths used by printReductions
+var reductionWidths = []int{40, 10, 12, 12, 12}
+
+// ReductionRow returns the table cells describing a planned replica
+// reduction
+func ReductionRow(reduction ReplicaReduction) []string {
+	return []string{
+		reduction.Index,
+		fmt.Sprintf("%d -> 0", reduction.Replicas),
+		utils.FormatBytes(reduction.SizeBytes),
+		utils.FormatBytes(reduction.PrimaryBytes),
+		utils.FormatBytes(reduction.ReclaimedBytes),
+	}
+}
+
+// printReductions prints the planned replica reductions
+func printReductions(reductions []ReplicaReduction) {
+	utils.PrintTableHeader(reductionColumns, reductionWidths)
+	for _, reduction := range reductions {
+		utils.PrintTableRow(ReductionRow(reduction), reductionWidths)
+	}
+	utils.PrintTableFooter(reductionWidths)
+}
@@ -0,0 +1,135 @@
+package elasticsearch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+func TestAnalyzeIndexesReplicaReduction(t *testing.T) {
+	now := time.Now()
+	indexes := []IndexInfo{
+		{Name: "logs-1", CreationDate: now.Add(-3 * time.Hour), SizeBytes: 200, PrimaryBytes: 100, Replicas: 1},
+		{Name: "logs-2", CreationDate: now.Add(-2 * time.Hour), SizeBytes: 200, PrimaryBytes: 100, Replicas: 1},
+		{Name: "logs-3", CreationDate: now.Add(-time.Hour), SizeBytes: 200, PrimaryBytes: 100, Replicas: 1},
+	}
+
+	log, _ := logger.New(logger.DefaultConfig())
+
+	tests := []struct {
+		maxSize        int64
+		wantDelete     []string
+		wantReduce     []string
+		wantProjection int64
+	}{
+		// Dropping the replicas of the two oldest indexes is enough
+		{450, nil, []string{"logs-1", "logs-2"}, 400},
+		// Reductions alone cannot reclaim 350 bytes, so the oldest is deleted
+		{250, []string{"logs-1"}, []string{"logs-2", "logs-3"}, 200},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{MaxSizeBytes: tt.maxSize, SizeStrategy: config.SizeStrategyReduceReplicas}
+		client := NewClient(cfg, log)
+
+		toDelete, result := client.AnalyzeIndexes(append([]IndexInfo(nil), indexes...))
+
+		var deleted, reduced []string
+		for _, index := range toDelete {
+			deleted = append(deleted, index.Name)
+		}
+		for _, reduction := range result.Reductions {
+			reduced = append(reduced, reduction.Index)
+		}
+		if strings.Join(deleted, ",") != strings.Join(tt.wantDelete, ",") {
+			t.Errorf("Max size %d: expected deletions %v, got %v", tt.maxSize, tt.wantDelete, deleted)
+		}
+		if strings.Join(reduced, ",") != strings.Join(tt.wantReduce, ",") {
+			t.Errorf("Max size %d: expected reductions %v, got %v", tt.maxSize, tt.wantReduce, reduced)
+		}
+		if result.ProjectedSize != tt.wantProjection {
+			t.Errorf("Max size %d: expected projected size %d, got %d", tt.maxSize, tt.wantProjection, result.ProjectedSize)
+		}
+	}
+}
+
+func TestApplyPlanReducesBeforeDeleting(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		if r.Method == "PUT" && !strings.Contains(string(body), `"index.number_of_replicas":0`) {
+			t.Errorf("Expected replicas to be set to 0, got %s", body)
+		}
+		w.Write([]byte(`{"acknowledged": true}`))
+	}))
+	defer server.Close()
+
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(&config.Config{ESHost: server.URL}, log)
+
+	plan := &Plan{
+		ToDelete: []IndexInfo{{Name: "logs-1", SizeBytes: 200}},
+		Result: AnalysisResult{Reductions: []ReplicaReduction{
+			{Index: "logs-2", Replicas: 1, SizeBytes: 200, PrimaryBytes: 100, ReclaimedBytes: 100},
+		}},
+	}
+	if !plan.HasActions() {
+		t.Fatal("Expected plan with reductions to have actions")
+	}
+
+	report := client.ApplyPlan(context.Background(), plan)
+
+	want := "PUT /logs-2/_settings,DELETE /logs-1"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("Expected calls %s, got %s", want, got)
+	}
+	if report.Reduced != 1 || report.ReducedBytes != 100 || report.Deleted != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if len(report.Outcomes) != 2 || report.Outcomes[0].Action != ActionReduceReplicas {
+		t.Errorf("Expected the reduction outcome first, got %+v", report.Outcomes)
+	}
+}
+
+func TestReduceReplicasIsRateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"acknowledged": true}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ESHost: server.URL, DeletePauseDuration: 20 * time.Millisecond}
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(cfg, log)
+
+	outcomes, remaining, err := client.ReduceReplicas(context.Background(), []ReplicaReduction{
+		{Index: "logs-1", Replicas: 1, ReclaimedBytes: 100},
+		{Index: "logs-2", Replicas: 1, ReclaimedBytes: 100},
+	})
+	if err != nil || len(remaining) != 0 || len(outcomes) != 2 {
+		t.Fatalf("Expected both reductions to run, got %+v / %v / %v", outcomes, remaining, err)
+	}
+	if outcomes[0].Throttled != 0 || outcomes[1].Throttled == 0 {
+		t.Errorf("Expected only the second reduction to wait for the pause, got %+v", outcomes)
+	}
+
+	// A cancelled wait leaves the rest of the reductions untouched
+	cfg.DeletePauseDuration = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	outcomes, remaining, err = client.ReduceReplicas(ctx, []ReplicaReduction{{Index: "logs-1"}, {Index: "logs-2"}})
+	if err == nil || len(outcomes) != 1 || len(remaining) != 1 || remaining[0] != "logs-2" {
+		t.Errorf("Expected the pause to block the second reduction, got %+v / %v / %v", outcomes, remaining, err)
+	}
+}
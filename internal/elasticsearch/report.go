@@ -0,0 +1,220 @@
+package elasticsearch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/pkg/utils"
+)
+
+// familyDatePattern finds the date or rollover counter that ends an index
+// family prefix, as in logs-app-2024.03.01 or logs-app-000042
+var familyDatePattern = regexp.MustCompile(`[-_.]?(\d{4}[-_.]?\d{2}[-_.]?\d{2}|\d{6,})`)
+
+// Family summarises the indexes that share a family key
+type Family struct {
+	Name        string    `json:"name"`
+	Indexes     int       `json:"indexes"`
+	TotalSize   int64     `json:"total_size"`
+	PrimarySize int64     `json:"primary_size"`
+	Docs        int64     `json:"docs"`
+	Oldest      time.Time `json:"oldest"`
+	Newest      time.Time `json:"newest"`
+	DailySize   int64     `json:"daily_size"` // Average bytes per day between oldest and newest
+	Share       float64   `json:"share"`      // Percentage of the total size of all families
+}
+
+// familyKey returns the family an index belongs to: the first capture group
+// of pattern when set, otherwise the name up to the first date or counter
+func familyKey(name string, pattern *regexp.Regexp) string {
+	if pattern != nil {
+		if match := pattern.FindStringSubmatch(name); len(match) > 1 && match[1] != "" {
+			return match[1]
+		}
+		return name
+	}
+
+	if loc := familyDatePattern.FindStringIndex(name); loc != nil && loc[0] > 0 {
+		return name[:loc[0]]
+	}
+	return name
+}
+
+// GroupIndexes summarises indexes by family
+func GroupIndexes(indexes []IndexInfo, pattern *regexp.Regexp) []Family {
+	byName := make(map[string]*Family)
+	var order []string
+	var total int64
+
+	for _, index := range indexes {
+		key := familyKey(index.Name, pattern)
+		family, ok := byName[key]
+		if !ok {
+			family = &Family{Name: key}
+			byName[key] = family
+			order = append(order, key)
+		}
+
+		family.Indexes++
+		family.TotalSize += index.SizeBytes
+		family.PrimarySize += index.PrimaryBytes
+		family.Docs += index.DocsCount
+		total += index.SizeBytes
+		if created := index.CreationDate; !created.IsZero() {
+			if family.Oldest.IsZero() || created.Before(family.Oldest) {
+				family.Oldest = created
+			}
+			if created.After(family.Newest) {
+				family.Newest = created
+			}
+		}
+	}
+
+	families := make([]Family, 0, len(order))
+	for _, key := range order {
+		family := byName[key]
+		if !family.Oldest.IsZero() {
+			days := family.Newest.Sub(family.Oldest).Hours()/24 + 1
+			family.DailySize = int64(float64(family.TotalSize) / days)
+		}
+		if total > 0 {
+			family.Share = float64(family.TotalSize) / float64(total) * 100
+		}
+		families = append(families, *family)
+	}
+	return families
+}
+
+// familySortKeys compares two families by each sortable column
+var familySortKeys = map[string]func(a, b Family) bool{
+	"name":         func(a, b Family) bool { return a.Name < b.Name },
+	"indexes":      func(a, b Family) bool { return a.Indexes < b.Indexes },
+	"total_size":   func(a, b Family) bool { return a.TotalSize < b.TotalSize },
+	"primary_size": func(a, b Family) bool { return a.PrimarySize < b.PrimarySize },
+	"docs":         func(a, b Family) bool { return a.Docs < b.Docs },
+	"oldest":       func(a, b Family) bool { return a.Oldest.Before(b.Oldest) },
+	"newest":       func(a, b Family) bool { return a.Newest.Before(b.Newest) },
+	"daily_size":   func(a, b Family) bool { return a.DailySize < b.DailySize },
+	"share":        func(a, b Family) bool { return a.Share < b.Share },
+}
+
+// SortFamilies sorts families by a column name; a leading "-" sorts in
+// descending order. An empty key sorts by total size, largest first.
+func SortFamilies(families []Family, key string) error {
+	if key == "" {
+		key = "-total_size"
+	}
+	descending := strings.HasPrefix(key, "-")
+	less, ok := familySortKeys[strings.TrimPrefix(key, "-")]
+	if !ok {
+		var keys []string
+		for name := range familySortKeys {
+			keys = append(keys, name)
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("unknown sort column '%s': must be one of %s", key, strings.Join(keys, ", "))
+	}
+
+	sort.SliceStable(families, func(i, j int) bool {
+		if descending {
+			return less(families[j], families[i])
+		}
+		return less(families[i], families[j])
+	})
+	return nil
+}
+
+// CapacityReport groups the indexes matching the configured pattern by
+// family and sorts them by the configured column
+func (c *Client) CapacityReport() ([]Family, error) {
+	indexes, err := c.GetIndexes(c.Config.IndexPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	families := GroupIndexes(indexes, c.Config.ReportGroupPattern)
+	if err := SortFamilies(families, c.Config.ReportSort); err != nil {
+		return nil, err
+	}
+
+	c.Logger.Info("report", "capacity", "Capacity report computed", map[string]interface{}{
+		"pattern":  c.Config.IndexPattern,
+		"families": len(families),
+		"indexes":  len(indexes),
+	})
+	return families, nil
+}
+
+// familyColumns are the columns printed by PrintFamilies
+var familyColumns = []string{"FAMILY", "INDEXES", "TOTAL SIZE", "PRIMARY SIZE", "DOCS", "OLDEST", "NEWEST", "DAILY SIZE", "SHARE"}
+
+// familyWidths are the column widths used by PrintFamilies
+var familyWidths = []int{32, 8, 12, 12, 10, 12, 12, 12, 7}
+
+// formatDate formats a date for reports, showing "-" when unknown
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02")
+}
+
+// PrintFamilies prints a capacity report as a table, JSON or CSV. CSV
+// sizes are in bytes so the output can be loaded into a spreadsheet.
+func PrintFamilies(families []Family, output string) error {
+	switch output {
+	case config.OutputJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(families)
+	case config.OutputCSV:
+		rows := [][]string{{"family", "indexes", "total_size", "primary_size", "docs", "oldest", "newest", "daily_size", "share"}}
+		for _, f := range families {
+			rows = append(rows, []string{
+				f.Name,
+				fmt.Sprintf("%d", f.Indexes),
+				fmt.Sprintf("%d", f.TotalSize),
+				fmt.Sprintf("%d", f.PrimarySize),
+				fmt.Sprintf("%d", f.Docs),
+				formatDate(f.Oldest),
+				formatDate(f.Newest),
+				fmt.Sprintf("%d", f.DailySize),
+				fmt.Sprintf("%.2f", f.Share),
+			})
+		}
+		return writeCSV(rows)
+	}
+
+	utils.PrintTableHeader(familyColumns, familyWidths)
+	for _, f := range families {
+		utils.PrintTableRow([]string{
+			f.Name,
+			fmt.Sprintf("%d", f.Indexes),
+			utils.FormatBytes(f.TotalSize),
+			utils.FormatBytes(f.PrimarySize),
+			utils.FormatNumber(f.Docs),
+			formatDate(f.Oldest),
+			formatDate(f.Newest),
+			utils.FormatBytes(f.DailySize),
+			fmt.Sprintf("%.1f%%", f.Share),
+		}, familyWidths)
+	}
+	utils.PrintTableFooter(familyWidths)
+	return nil
+}
+
+// writeCSV writes rows to stdout as CSV
+func writeCSV(rows [][]string) error {
+	writer := csv.NewWriter(os.Stdout)
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package elasticsearch
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFamilyKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern *regexp.Regexp
+		want    string
+	}{
+		{"logs-app-2024.03.01", nil, "logs-app"},
+		{"logs-app-2024-03-01", nil, "logs-app"},
+		{"metrics_20240301", nil, "metrics"},
+		{"logs-app-000042", nil, "logs-app"},
+		{"kibana", nil, "kibana"},
+		{"logs-app-prod-2024.03.01", regexp.MustCompile(`^logs-(\w+)-`), "app"},
+		{"other-2024.03.01", regexp.MustCompile(`^logs-(\w+)-`), "other-2024.03.01"},
+	}
+
+	for _, tt := range tests {
+		if got := familyKey(tt.name, tt.pattern); got != tt.want {
+			t.Errorf("familyKey(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGroupAndSortIndexes(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	indexes := []IndexInfo{
+		{Name: "app-2024.03.01", SizeBytes: 100, PrimaryBytes: 50, DocsCount: 10, CreationDate: day(1)},
+		{Name: "app-2024.03.02", SizeBytes: 100, PrimaryBytes: 50, DocsCount: 10, CreationDate: day(2)},
+		{Name: "audit-2024.03.01", SizeBytes: 600, PrimaryBytes: 300, DocsCount: 5, CreationDate: day(1)},
+	}
+
+	families := GroupIndexes(indexes, nil)
+	if err := SortFamilies(families, ""); err != nil {
+		t.Fatalf("Expected default sort to succeed, got %v", err)
+	}
+	if len(families) != 2 || families[0].Name != "audit" {
+		t.Fatalf("Expected audit first by total size, got %+v", families)
+	}
+
+	app := families[1]
+	if app.Indexes != 2 || app.TotalSize != 200 || app.PrimarySize != 100 || app.Docs != 20 {
+		t.Errorf("Unexpected app totals: %+v", app)
+	}
+	if !app.Oldest.Equal(day(1)) || !app.Newest.Equal(day(2)) || app.DailySize != 100 {
+		t.Errorf("Expected 2 days at 100 bytes per day, got %+v", app)
+	}
+	if app.Share != 25 {
+		t.Errorf("Expected 25%% share, got %.1f", app.Share)
+	}
+
+	if err := SortFamilies(families, "docs"); err != nil || families[0].Name != "audit" {
+		t.Errorf("Expected audit first by ascending docs, got %+v / %v", families, err)
+	}
+	if err := SortFamilies(families, "-size"); err == nil {
+		t.Error("Expected error for unknown sort column")
+	}
+}
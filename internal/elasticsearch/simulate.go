@@ -0,0 +1,255 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/pkg/utils"
+)
+
+// SimulationStep is the outcome of applying a policy on one simulated day
+type SimulationStep struct {
+	Day        int       `json:"day"`
+	Date       time.Time `json:"date"`
+	Added      string    `json:"added,omitempty"` // Synthetic index created that day
+	Indexes    int       `json:"indexes"`         // Indexes before the deletions
+	SizeBefore int64     `json:"size_before"`
+	Deleted    []string  `json:"deleted,omitempty"`
+	Reduced    []string  `json:"reduced,omitempty"` // Indexes whose replicas were dropped
+	Reclaimed  int64     `json:"reclaimed"`
+	SizeAfter  int64     `json:"size_after"`
+}
+
+// Simulation is the day by day timeline of a policy applied to its current
+// indexes plus one synthetic index per day
+type Simulation struct {
+	Policy      string           `json:"policy"`
+	Pattern     string           `json:"pattern"`
+	SizeBasis   string           `json:"size_basis"`
+	Start       time.Time        `json:"start"`
+	Days        int              `json:"days"`
+	DailyGrowth int64            `json:"daily_growth"` // Bytes per day in the size basis
+	Steps       []SimulationStep `json:"steps"`
+	Deleted     int              `json:"deleted"`
+	Reclaimed   int64            `json:"reclaimed"`
+}
+
+// syntheticName names a simulated index after the pattern's prefix and the
+// day it is created, e.g. logs-2024.03.01 for logs-*
+func syntheticName(pattern string, day time.Time) string {
+	prefix := strings.SplitN(strings.Split(pattern, ",")[0], "*", 2)[0]
+	if prefix == "" {
+		prefix = "simulated"
+	}
+	if !strings.ContainsAny(prefix[len(prefix)-1:], "-_.") {
+		prefix += "-"
+	}
+	return prefix + day.Format("2006.01.02")
+}
+
+// syntheticIndex returns an index created at created holding growth bytes
+// in the size basis, shaped like template
+func (c *Client) syntheticIndex(template IndexInfo, created time.Time, growth int64) IndexInfo {
+	index := IndexInfo{
+		Name:         syntheticName(c.Config.IndexPattern, created),
+		Health:       "green",
+		Status:       "open",
+		Primaries:    template.Primaries,
+		Replicas:     template.Replicas,
+		CreationDate: created,
+		SizeSource:   SizeSourceEstimate,
+	}
+	if index.Primaries == 0 {
+		index.Primaries = 1
+	}
+
+	copies := int64(1 + index.Replicas)
+	if c.sizeBasis() == config.SizeBasisPrimary {
+		index.PrimaryBytes, index.SizeBytes = growth, growth*copies
+	} else {
+		index.SizeBytes, index.PrimaryBytes = growth, growth/copies
+	}
+	if template.DocsCount > 0 && template.PrimaryBytes > 0 {
+		index.DocsCount = int64(float64(index.PrimaryBytes) / float64(template.PrimaryBytes) * float64(template.DocsCount))
+	}
+	return index
+}
+
+// simulate steps indexes forward one day at a time from start. Day 0
+// applies the policy to the current indexes; every later day first adds a
+// synthetic index of growth bytes. Deleted indexes are removed and replica
+// reductions applied before the next day.
+func (c *Client) simulate(indexes []IndexInfo, start time.Time, days int, growth int64) []SimulationStep {
+	// The disk target depends on live node allocation, which a simulation
+	// cannot project, and metrics must not report simulated state
+	cfg := *c.Config
+	cfg.TargetDiskPercent = 0
+	sim := c.WithConfig(&cfg)
+	sim.Metrics = nil
+
+	current := append([]IndexInfo(nil), indexes...)
+	sort.Slice(current, func(i, j int) bool {
+		return current[i].CreationDate.Before(current[j].CreationDate)
+	})
+	var template IndexInfo
+	if len(current) > 0 {
+		template = current[len(current)-1]
+	}
+
+	var steps []SimulationStep
+	for day := 0; day <= days; day++ {
+		at := start.Add(time.Duration(day) * 24 * time.Hour)
+		step := SimulationStep{Day: day, Date: at}
+		if day > 0 && growth > 0 {
+			added := sim.syntheticIndex(template, at, growth)
+			current = append(current, added)
+			step.Added = added.Name
+		}
+
+		sim.Clock = func() time.Time { return at }
+		toDelete, result := sim.AnalyzeIndexes(append([]IndexInfo(nil), current...))
+
+		step.Indexes = len(current)
+		step.SizeBefore = result.TotalSize
+		step.SizeAfter = result.ProjectedSize
+		step.Reclaimed = result.TotalSize - result.ProjectedSize
+
+		deleted := make(map[string]bool)
+		for _, index := range toDelete {
+			deleted[index.Name] = true
+			step.Deleted = append(step.Deleted, index.Name)
+		}
+		reduced := make(map[string]bool)
+		for _, reduction := range result.Reductions {
+			reduced[reduction.Index] = true
+			step.Reduced = append(step.Reduced, reduction.Index)
+		}
+
+		remaining := current[:0]
+		for _, index := range current {
+			if deleted[index.Name] {
+				continue
+			}
+			if reduced[index.Name] {
+				index.Replicas = 0
+				index.SizeBytes = index.PrimaryBytes
+			}
+			remaining = append(remaining, index)
+		}
+		current = remaining
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// Simulate projects the configured policy SimulateDays days forward from
+// the as-of time, adding DailyGrowth bytes per day. Without a configured
+// daily growth the forecast ingest rate is used.
+func (c *Client) Simulate() (*Simulation, error) {
+	indexes, err := c.GetIndexes(c.Config.IndexPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	start := c.now()
+	growth := c.Config.DailyGrowthBytes
+	if growth == 0 {
+		forecast, err := forecastGrowth(indexes, c.indexSize, c.Config.MaxSizeBytes, start)
+		if err != nil {
+			return nil, fmt.Errorf("no daily growth configured and cannot forecast %s: %w", c.Config.IndexPattern, err)
+		}
+		growth = forecast.DailyIngest
+	}
+
+	simulation := &Simulation{
+		Policy:      c.Config.PolicyName,
+		Pattern:     c.Config.IndexPattern,
+		SizeBasis:   c.sizeBasis(),
+		Start:       start,
+		Days:        c.Config.SimulateDays,
+		DailyGrowth: growth,
+		Steps:       c.simulate(indexes, start, c.Config.SimulateDays, growth),
+	}
+	if simulation.Policy == "" {
+		simulation.Policy = c.Config.IndexPattern
+	}
+	for _, step := range simulation.Steps {
+		simulation.Deleted += len(step.Deleted)
+		simulation.Reclaimed += step.Reclaimed
+	}
+
+	c.Logger.Info("simulate", "simulate", "Simulation complete", map[string]interface{}{
+		"pattern":      simulation.Pattern,
+		"days":         simulation.Days,
+		"daily_growth": simulation.DailyGrowth,
+		"deleted":      simulation.Deleted,
+	})
+	return simulation, nil
+}
+
+// simulationColumns are the table columns printed by PrintSimulations
+var simulationColumns = []string{"DAY", "DATE", "INDEXES", "SIZE BEFORE", "DELETED", "REDUCED", "RECLAIMED", "SIZE AFTER"}
+
+// simulationWidths are the column widths used by PrintSimulations
+var simulationWidths = []int{5, 12, 8, 12, 8, 8, 12, 12}
+
+// PrintSimulations prints one timeline per policy as a table followed by
+// the indexes deleted each day, or as JSON or CSV
+func PrintSimulations(simulations []Simulation, output string) error {
+	switch output {
+	case config.OutputJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(simulations)
+	case config.OutputCSV:
+		rows := [][]string{{"policy", "day", "date", "added", "indexes", "size_before", "deleted", "reduced", "reclaimed", "size_after"}}
+		for _, s := range simulations {
+			for _, step := range s.Steps {
+				rows = append(rows, []string{
+					s.Policy,
+					fmt.Sprintf("%d", step.Day),
+					step.Date.Format("2006-01-02"),
+					step.Added,
+					fmt.Sprintf("%d", step.Indexes),
+					fmt.Sprintf("%d", step.SizeBefore),
+					strings.Join(step.Deleted, ";"),
+					strings.Join(step.Reduced, ";"),
+					fmt.Sprintf("%d", step.Reclaimed),
+					fmt.Sprintf("%d", step.SizeAfter),
+				})
+			}
+		}
+		return writeCSV(rows)
+	}
+
+	for _, s := range simulations {
+		fmt.Printf("Policy %s (%s): %s/d for %d days, size basis %s\n", s.Policy, s.Pattern, utils.FormatBytes(s.DailyGrowth), s.Days, s.SizeBasis)
+		utils.PrintTableHeader(simulationColumns, simulationWidths)
+		for _, step := range s.Steps {
+			utils.PrintTableRow([]string{
+				fmt.Sprintf("%d", step.Day),
+				step.Date.Format("2006-01-02"),
+				fmt.Sprintf("%d", step.Indexes),
+				utils.FormatBytes(step.SizeBefore),
+				fmt.Sprintf("%d", len(step.Deleted)),
+				fmt.Sprintf("%d", len(step.Reduced)),
+				utils.FormatBytes(step.Reclaimed),
+				utils.FormatBytes(step.SizeAfter),
+			}, simulationWidths)
+		}
+		utils.PrintTableFooter(simulationWidths)
+
+		for _, step := range s.Steps {
+			if len(step.Deleted) > 0 {
+				fmt.Printf("Day %d: delete %s\n", step.Day, strings.Join(step.Deleted, ", "))
+			}
+		}
+		fmt.Printf("Total: %d indexes deleted, %s reclaimed\n\n", s.Deleted, utils.FormatBytes(s.Reclaimed))
+	}
+	return nil
+}
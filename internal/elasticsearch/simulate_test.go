@@ -0,0 +1,76 @@
+package elasticsearch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+func TestSyntheticName(t *testing.T) {
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	tests := map[string]string{
+		"logs-*":        "logs-2024.03.05",
+		"logs*":         "logs-2024.03.05",
+		"app_*,other-*": "app_2024.03.05",
+		"*":             "simulated-2024.03.05",
+	}
+	for pattern, want := range tests {
+		if got := syntheticName(pattern, day); got != want {
+			t.Errorf("syntheticName(%s) = %s, want %s", pattern, got, want)
+		}
+	}
+}
+
+func TestSimulateSizeRule(t *testing.T) {
+	start := time.Date(2024, 3, 10, 6, 0, 0, 0, time.UTC)
+	indexes := []IndexInfo{
+		{Name: "logs-2024.03.08", CreationDate: start.Add(-48 * time.Hour), SizeBytes: 100, PrimaryBytes: 100, Primaries: 1},
+		{Name: "logs-2024.03.09", CreationDate: start.Add(-24 * time.Hour), SizeBytes: 100, PrimaryBytes: 100, Primaries: 1},
+		{Name: "logs-2024.03.10", CreationDate: start, SizeBytes: 100, PrimaryBytes: 100, Primaries: 1},
+	}
+
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(&config.Config{IndexPattern: "logs-*", MaxSizeBytes: 300}, log)
+
+	steps := client.simulate(indexes, start, 3, 100)
+	if len(steps) != 4 {
+		t.Fatalf("Expected 4 steps, got %d", len(steps))
+	}
+	if len(steps[0].Deleted) != 0 || steps[0].Added != "" {
+		t.Errorf("Expected day 0 to leave the current indexes alone, got %+v", steps[0])
+	}
+
+	wantDeleted := []string{"", "logs-2024.03.08", "logs-2024.03.09", "logs-2024.03.10"}
+	for day := 1; day <= 3; day++ {
+		step := steps[day]
+		if len(step.Deleted) != 1 || step.Deleted[0] != wantDeleted[day] {
+			t.Errorf("Day %d: expected %s deleted, got %v", day, wantDeleted[day], step.Deleted)
+		}
+		if step.SizeBefore != 400 || step.SizeAfter != 300 {
+			t.Errorf("Day %d: expected 400 -> 300 bytes, got %d -> %d", day, step.SizeBefore, step.SizeAfter)
+		}
+	}
+	if steps[1].Added != "logs-2024.03.11" {
+		t.Errorf("Expected synthetic index for day 1, got %s", steps[1].Added)
+	}
+}
+
+func TestAnalyzeIndexesUsesClock(t *testing.T) {
+	created := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	indexes := []IndexInfo{{Name: "logs-1", CreationDate: created}}
+
+	log, _ := logger.New(logger.DefaultConfig())
+	cfg := &config.Config{MaxAgeDuration: 7 * 24 * time.Hour, AsOfTime: created.Add(6 * 24 * time.Hour)}
+	client := NewClient(cfg, log)
+
+	if toDelete, _ := client.AnalyzeIndexes(indexes); len(toDelete) != 0 {
+		t.Error("Expected index younger than max age at the as-of time to be kept")
+	}
+
+	client.Clock = func() time.Time { return created.Add(8 * 24 * time.Hour) }
+	if toDelete, _ := client.AnalyzeIndexes(indexes); len(toDelete) != 1 {
+		t.Error("Expected clock to take precedence over the as-of time")
+	}
+}
@@ -0,0 +1,281 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/ratelimit"
+)
+
+// deletionMarkKey is the key in the index mapping's _meta that holds the
+// soft delete mark
+const deletionMarkKey = "log_trimmer"
+
+// DeletionMark records that an index is pending deletion
+type DeletionMark struct {
+	MarkedAt    time.Time `json:"marked_at"`
+	DeleteAfter time.Time `json:"delete_after"`
+	Action      string    `json:"action"`
+	Policy      string    `json:"policy,omitempty"`
+}
+
+// mappingResponse is the GET _mapping response, keyed by index name
+type mappingResponse map[string]struct {
+	Mappings struct {
+		Meta map[string]json.RawMessage `json:"_meta"`
+	} `json:"mappings"`
+}
+
+// getMeta returns the _meta object of an index mapping
+func (c *Client) getMeta(index string) (map[string]json.RawMessage, error) {
+	resp, err := c.makeRequest("GET", fmt.Sprintf("/%s/_mapping", index))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping of %s: %w", index, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read mapping of %s with status %d", index, resp.StatusCode)
+	}
+
+	var mappings mappingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&mappings); err != nil {
+		return nil, fmt.Errorf("failed to decode mapping of %s: %w", index, err)
+	}
+
+	meta := mappings[index].Mappings.Meta
+	if meta == nil {
+		meta = make(map[string]json.RawMessage)
+	}
+	return meta, nil
+}
+
+// putMeta replaces the _meta object of an index mapping. Elasticsearch
+// replaces _meta as a whole, so callers pass the merged object.
+func (c *Client) putMeta(index string, meta map[string]json.RawMessage) error {
+	return c.expectOK("PUT", fmt.Sprintf("/%s/_mapping", index), map[string]interface{}{"_meta": meta})
+}
+
+// expectOK sends a request and turns a non-200 response into an error
+func (c *Client) expectOK(method, path string, body interface{}) error {
+	resp, err := c.makeRequestWithBody(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s failed with status %d: %s", method, path, resp.StatusCode, string(data))
+	}
+	return nil
+}
+
+// GetDeletionMark returns the soft delete mark of an index, or nil when the
+// index is not marked
+func (c *Client) GetDeletionMark(index string) (*DeletionMark, error) {
+	meta, err := c.getMeta(index)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, ok := meta[deletionMarkKey]
+	if !ok || string(raw) == "null" {
+		return nil, nil
+	}
+	var mark DeletionMark
+	if err := json.Unmarshal(raw, &mark); err != nil {
+		return nil, fmt.Errorf("failed to decode deletion mark of %s: %w", index, err)
+	}
+	return &mark, nil
+}
+
+// MarkIndex records a deletion mark in the index mapping's _meta and then
+// blocks writes to the index or closes it, depending on the configured
+// soft delete action
+func (c *Client) MarkIndex(index string) (*DeletionMark, error) {
+	now := time.Now().UTC()
+	mark := &DeletionMark{
+		MarkedAt:    now,
+		DeleteAfter: now.Add(c.Config.GracePeriodDuration),
+		Action:      c.Config.SoftDeleteAction,
+		Policy:      c.Config.PolicyName,
+	}
+
+	meta, err := c.getMeta(index)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(mark)
+	if err != nil {
+		return nil, err
+	}
+	meta[deletionMarkKey] = encoded
+	if err := c.putMeta(index, meta); err != nil {
+		return nil, fmt.Errorf("failed to mark %s: %w", index, err)
+	}
+
+	// The mark must be written before closing, as a closed index's mapping
+	// cannot be updated
+	if mark.Action == config.SoftDeleteClose {
+		err = c.expectOK("POST", fmt.Sprintf("/%s/_close", index), nil)
+	} else {
+		err = c.expectOK("PUT", fmt.Sprintf("/%s/_settings", index), map[string]interface{}{"index.blocks.write": true})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s %s: %w", mark.Action, index, err)
+	}
+
+	c.Logger.Warn("elasticsearch", "mark_index", "Marked index for deletion", map[string]interface{}{
+		"index":        index,
+		"action":       mark.Action,
+		"delete_after": mark.DeleteAfter,
+	})
+	return mark, nil
+}
+
+// UnmarkIndex rescues an index during its grace period: it reopens the
+// index, lifts the write block and removes the deletion mark. Indexes
+// without a mark are left untouched, so a block or close this tool did not
+// make is never undone.
+func (c *Client) UnmarkIndex(index string) error {
+	mark, err := c.GetDeletionMark(index)
+	if err != nil {
+		return err
+	}
+	if mark == nil {
+		return fmt.Errorf("%s is not marked for deletion, leaving it unchanged", index)
+	}
+
+	if err := c.expectOK("POST", fmt.Sprintf("/%s/_open", index), nil); err != nil {
+		return fmt.Errorf("failed to open %s: %w", index, err)
+	}
+	if err := c.expectOK("PUT", fmt.Sprintf("/%s/_settings", index), map[string]interface{}{"index.blocks.write": nil}); err != nil {
+		return fmt.Errorf("failed to remove write block from %s: %w", index, err)
+	}
+
+	// Re-read _meta now the index is open, as putMeta replaces it whole
+	meta, err := c.getMeta(index)
+	if err != nil {
+		return err
+	}
+	delete(meta, deletionMarkKey)
+	if err := c.putMeta(index, meta); err != nil {
+		return fmt.Errorf("failed to remove deletion mark from %s: %w", index, err)
+	}
+
+	c.Logger.Success("elasticsearch", "unmark_index", "Removed deletion mark", map[string]interface{}{
+		"index": index,
+	})
+	return nil
+}
+
+// markIndexes marks each index, waiting for the rate limits and checking
+// the maintenance windows and health gate before each one like
+// DeleteIndexes. When a check fails it returns the indexes not yet marked
+// along with the error.
+func (c *Client) markIndexes(ctx context.Context, indexes []IndexInfo) ([]IndexInfo, []DeletionOutcome, []string, error) {
+	limiter := ratelimit.New(c.Config.MaxDeletesPerMinute, c.Config.MaxBytesPerMinuteBytes, c.Config.DeletePauseDuration)
+
+	var marked []IndexInfo
+	var failures []DeletionOutcome
+	for i, index := range indexes {
+		err := ctx.Err()
+		if err == nil {
+			_, err = limiter.Wait(ctx, index.SizeBytes)
+		}
+		if err == nil {
+			err = c.waitForWindow(ctx, i > 0)
+		}
+		if err == nil {
+			err = c.WaitForHealthGate(ctx)
+		}
+		if err != nil {
+			var remaining []string
+			for _, rest := range indexes[i:] {
+				remaining = append(remaining, rest.Name)
+			}
+			return marked, failures, remaining, err
+		}
+
+		_, err = c.MarkIndex(index.Name)
+		limiter.Done()
+		if err != nil {
+			failures = append(failures, DeletionOutcome{Index: index.Name, SizeBytes: index.SizeBytes, Error: err.Error()})
+			continue
+		}
+		marked = append(marked, index)
+	}
+	return marked, failures, nil, nil
+}
+
+// Apply carries out a plan: with soft delete enabled it marks new
+// candidates and only deletes those whose grace period has passed,
+// otherwise it deletes every index straight away. Marking goes through the
+// same rate limits, windows and health gate as deleting; if it is blocked
+// no deletions are attempted. An index that drops out of later plans keeps
+// its mark and stays blocked or closed until it is unmarked.
+func (c *Client) Apply(ctx context.Context, indexes []IndexInfo) *DeletionReport {
+	if !c.Config.SoftDelete {
+		return c.DeleteIndexes(ctx, indexes)
+	}
+
+	var due, unmarked, pending []IndexInfo
+	var failures []DeletionOutcome
+	for _, index := range indexes {
+		if ctx.Err() != nil {
+			break
+		}
+
+		mark, err := c.GetDeletionMark(index.Name)
+		switch {
+		case err != nil:
+			failures = append(failures, DeletionOutcome{Index: index.Name, SizeBytes: index.SizeBytes, Error: err.Error()})
+		case mark == nil:
+			unmarked = append(unmarked, index)
+		case time.Now().Before(mark.DeleteAfter):
+			pending = append(pending, index)
+		default:
+			due = append(due, index)
+		}
+	}
+
+	start := time.Now()
+	marked, markFailures, remaining, err := c.markIndexes(ctx, unmarked)
+	failures = append(failures, markFailures...)
+
+	var report *DeletionReport
+	if err != nil {
+		report = &DeletionReport{StartTime: start, NotAttempted: remaining}
+		report.block(err)
+		for _, index := range due {
+			report.NotAttempted = append(report.NotAttempted, index.Name)
+		}
+		report.EndTime = time.Now()
+		c.Metrics.RecordRun(false, report.EndTime)
+	} else {
+		report = c.DeleteIndexes(ctx, due)
+		report.StartTime = start
+	}
+	for _, index := range marked {
+		report.Marked = append(report.Marked, index.Name)
+	}
+	for _, index := range pending {
+		report.Pending = append(report.Pending, index.Name)
+	}
+	report.Outcomes = append(report.Outcomes, failures...)
+	report.Failed += len(failures)
+
+	c.Logger.Info("elasticsearch", "soft_delete", "Soft delete run finished", map[string]interface{}{
+		"marked":  len(report.Marked),
+		"pending": len(report.Pending),
+		"deleted": report.Deleted,
+		"failed":  report.Failed,
+	})
+	return report
+}
@@ -0,0 +1,184 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/logger"
+	"github.com/company/log-trimmer/internal/scheduler"
+)
+
+// softDeleteServer keeps the _meta, write block and open state of indexes
+type softDeleteServer struct {
+	mu      sync.Mutex
+	meta    map[string]map[string]json.RawMessage
+	blocked map[string]bool
+	closed  map[string]bool
+	deleted map[string]bool
+}
+
+func newSoftDeleteServer(indexes ...string) *softDeleteServer {
+	s := &softDeleteServer{
+		meta:    make(map[string]map[string]json.RawMessage),
+		blocked: make(map[string]bool),
+		closed:  make(map[string]bool),
+		deleted: make(map[string]bool),
+	}
+	for _, index := range indexes {
+		s.meta[index] = map[string]json.RawMessage{"owner": json.RawMessage(`"team-a"`)}
+	}
+	return s
+}
+
+func (s *softDeleteServer) mark(index string, deleteAfter time.Time) {
+	data, _ := json.Marshal(DeletionMark{MarkedAt: deleteAfter.Add(-time.Hour), DeleteAfter: deleteAfter})
+	s.meta[index][deletionMarkKey] = data
+}
+
+func (s *softDeleteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	index := parts[0]
+	op := ""
+	if len(parts) > 1 {
+		op = parts[1]
+	}
+
+	switch {
+	case r.Method == "GET" && op == "_mapping":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			index: map[string]interface{}{"mappings": map[string]interface{}{"_meta": s.meta[index]}},
+		})
+		return
+	case r.Method == "PUT" && op == "_mapping":
+		var body struct {
+			Meta map[string]json.RawMessage `json:"_meta"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.meta[index] = body.Meta
+	case r.Method == "PUT" && op == "_settings":
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.blocked[index] = body["index.blocks.write"] == true
+	case op == "_close":
+		s.closed[index] = true
+	case op == "_open":
+		s.closed[index] = false
+	case r.Method == "DELETE":
+		s.deleted[index] = true
+	}
+	w.Write([]byte(`{"acknowledged":true}`))
+}
+
+func newSoftDeleteClient(t *testing.T, server *httptest.Server, action string) *Client {
+	t.Helper()
+	cfg := &config.Config{
+		ESHost:              server.URL,
+		SoftDelete:          true,
+		SoftDeleteAction:    action,
+		GracePeriodDuration: 24 * time.Hour,
+	}
+	log, _ := logger.New(logger.DefaultConfig())
+	return NewClient(cfg, log)
+}
+
+func TestApplySoftDelete(t *testing.T) {
+	fake := newSoftDeleteServer("logs-1", "logs-2", "logs-3")
+	fake.mark("logs-2", time.Now().Add(-time.Minute))
+	fake.mark("logs-3", time.Now().Add(time.Hour))
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := newSoftDeleteClient(t, server, config.SoftDeleteBlock)
+
+	report := client.Apply(context.Background(), []IndexInfo{{Name: "logs-1"}, {Name: "logs-2"}, {Name: "logs-3"}})
+
+	if len(report.Marked) != 1 || report.Marked[0] != "logs-1" || !fake.blocked["logs-1"] {
+		t.Errorf("Expected logs-1 to be marked and write blocked, got %+v", report)
+	}
+	if report.Deleted != 1 || !fake.deleted["logs-2"] {
+		t.Errorf("Expected only logs-2 to be deleted, got %+v", report)
+	}
+	if len(report.Pending) != 1 || report.Pending[0] != "logs-3" || fake.deleted["logs-3"] {
+		t.Errorf("Expected logs-3 to stay pending, got %+v", report)
+	}
+	if _, ok := fake.meta["logs-1"]["owner"]; !ok {
+		t.Error("Expected existing _meta keys to be preserved when marking")
+	}
+}
+
+func TestMarkIndexCloseAndUnmark(t *testing.T) {
+	fake := newSoftDeleteServer("logs-1")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := newSoftDeleteClient(t, server, config.SoftDeleteClose)
+
+	mark, err := client.MarkIndex("logs-1")
+	if err != nil {
+		t.Fatalf("Expected mark to succeed, got %v", err)
+	}
+	if !fake.closed["logs-1"] || mark.DeleteAfter.Sub(mark.MarkedAt) != 24*time.Hour {
+		t.Errorf("Expected index to be closed with a 24h grace period, got %+v", mark)
+	}
+
+	if err := client.UnmarkIndex("logs-1"); err != nil {
+		t.Fatalf("Expected unmark to succeed, got %v", err)
+	}
+	if fake.closed["logs-1"] || fake.blocked["logs-1"] {
+		t.Error("Expected index to be reopened and writable")
+	}
+	if mark, _ := client.GetDeletionMark("logs-1"); mark != nil {
+		t.Errorf("Expected deletion mark to be removed, got %+v", mark)
+	}
+	if _, ok := fake.meta["logs-1"]["owner"]; !ok {
+		t.Error("Expected existing _meta keys to survive unmark")
+	}
+}
+
+func TestUnmarkIndexLeavesUnmarkedIndexAlone(t *testing.T) {
+	fake := newSoftDeleteServer("logs-1")
+	fake.closed["logs-1"] = true
+	fake.blocked["logs-1"] = true
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := newSoftDeleteClient(t, server, config.SoftDeleteClose)
+
+	if err := client.UnmarkIndex("logs-1"); err == nil {
+		t.Error("Expected unmarking an unmarked index to fail")
+	}
+	if !fake.closed["logs-1"] || !fake.blocked["logs-1"] {
+		t.Error("Expected an unmarked index to stay closed and write blocked")
+	}
+}
+
+func TestApplySoftDeleteOutsideWindow(t *testing.T) {
+	fake := newSoftDeleteServer("logs-1", "logs-2")
+	fake.mark("logs-2", time.Now().Add(-time.Minute))
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := newSoftDeleteClient(t, server, config.SoftDeleteClose)
+	// A one-minute window on 29 February is as good as closed
+	windows, err := scheduler.ParseWindows([]string{"0 0 29 2 * 1m"}, "UTC")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client.Config.Windows = windows
+
+	report := client.Apply(context.Background(), []IndexInfo{{Name: "logs-1"}, {Name: "logs-2"}})
+
+	if !report.Deferred || len(report.Marked) != 0 || fake.closed["logs-1"] {
+		t.Errorf("Expected marking to be deferred outside the window, got %+v", report)
+	}
+	if fake.deleted["logs-2"] || len(report.NotAttempted) != 2 {
+		t.Errorf("Expected no deletions once marking was deferred, got %+v", report)
+	}
+}
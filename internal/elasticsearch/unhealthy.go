@@ -0,0 +1,187 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/company/log-trimmer/internal/config"
+)
+
+// Sources of index sizes
+const (
+	SizeSourceCat      = "cat"      // _cat/indices
+	SizeSourceStats    = "stats"    // _stats, which also covers closed indexes
+	SizeSourceEstimate = "estimate" // Average of healthy indexes in the same family
+)
+
+// storeStats is the part of an _stats section used to size an index
+type storeStats struct {
+	Store struct {
+		SizeInBytes int64 `json:"size_in_bytes"`
+	} `json:"store"`
+	Docs struct {
+		Count int64 `json:"count"`
+	} `json:"docs"`
+}
+
+// statsResponse is the GET /{index}/_stats response
+type statsResponse struct {
+	Indices map[string]struct {
+		Primaries storeStats `json:"primaries"`
+		Total     storeStats `json:"total"`
+	} `json:"indices"`
+}
+
+// getIndexStats reads the store size and document count of an index from
+// _stats, which also reports closed indexes when asked to
+func (c *Client) getIndexStats(index string) (primaries, total storeStats, err error) {
+	path := fmt.Sprintf("/%s/_stats/store,docs?expand_wildcards=all&forbid_closed_indices=false", index)
+	resp, err := c.makeRequest("GET", path)
+	if err != nil {
+		return primaries, total, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return primaries, total, fmt.Errorf("failed to get stats of %s with status %d", index, resp.StatusCode)
+	}
+
+	var stats statsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return primaries, total, fmt.Errorf("failed to decode stats of %s: %w", index, err)
+	}
+	entry, ok := stats.Indices[index]
+	if !ok {
+		return primaries, total, fmt.Errorf("no stats reported for %s", index)
+	}
+	return entry.Primaries, entry.Total, nil
+}
+
+// familyAverage is the average size of the healthy indexes in a family
+type familyAverage struct {
+	total, primary int64
+}
+
+// familyAverages returns the average sizes of the open, non-red indexes in
+// each family, used to estimate indexes whose size cannot be read
+func (c *Client) familyAverages(indexes []IndexInfo) map[string]familyAverage {
+	sums := make(map[string]familyAverage)
+	counts := make(map[string]int64)
+	for _, index := range indexes {
+		if index.IsClosed() || index.IsRed() {
+			continue
+		}
+		key := familyKey(index.Name, c.Config.ReportGroupPattern)
+		sum := sums[key]
+		sum.total += index.SizeBytes
+		sum.primary += index.PrimaryBytes
+		sums[key] = sum
+		counts[key]++
+	}
+
+	averages := make(map[string]familyAverage, len(sums))
+	for key, sum := range sums {
+		averages[key] = familyAverage{total: sum.total / counts[key], primary: sum.primary / counts[key]}
+	}
+	return averages
+}
+
+// resolveUncertainSizes fills in the sizes _cat/indices leaves empty or
+// partial. Closed indexes are sized from _stats when the cluster reports
+// them and estimated from their family otherwise; red indexes keep the
+// larger of their partial size and the family estimate. Either way the
+// size is flagged as uncertain unless it was read from _stats.
+func (c *Client) resolveUncertainSizes(indexes []IndexInfo) {
+	var averages map[string]familyAverage
+	for i := range indexes {
+		index := &indexes[i]
+		if !index.IsClosed() && !index.IsRed() {
+			continue
+		}
+
+		if index.IsClosed() {
+			primaries, total, err := c.getIndexStats(index.Name)
+			if err == nil && total.Store.SizeInBytes > 0 {
+				index.SizeBytes = total.Store.SizeInBytes
+				index.PrimaryBytes = primaries.Store.SizeInBytes
+				index.DocsCount = primaries.Docs.Count
+				index.SizeSource = SizeSourceStats
+				continue
+			}
+			if err != nil {
+				c.Logger.Debug("elasticsearch", "index_stats", "Could not read closed index stats", map[string]interface{}{
+					"index": index.Name,
+					"error": err.Error(),
+				})
+			}
+		}
+
+		if averages == nil {
+			averages = c.familyAverages(indexes)
+		}
+		index.SizeUncertain = true
+		if estimate, ok := averages[familyKey(index.Name, c.Config.ReportGroupPattern)]; ok && estimate.total > index.SizeBytes {
+			index.SizeBytes = estimate.total
+			index.PrimaryBytes = estimate.primary
+			index.SizeSource = SizeSourceEstimate
+		}
+
+		c.Logger.Warn("elasticsearch", "index_size", "Index size is uncertain", map[string]interface{}{
+			"index":  index.Name,
+			"status": index.Status,
+			"health": index.Health,
+			"size":   index.SizeBytes,
+			"source": index.SizeSource,
+		})
+	}
+}
+
+// unhealthyMode returns the handling mode and state of a closed or red
+// index, or an empty mode for open indexes that are not red
+func (c *Client) unhealthyMode(index IndexInfo) (string, string) {
+	switch {
+	case index.IsClosed():
+		if c.Config.ClosedIndexes == "" {
+			return config.UnhealthyDelete, "closed"
+		}
+		return c.Config.ClosedIndexes, "closed"
+	case index.IsRed():
+		if c.Config.RedIndexes == "" {
+			return config.UnhealthyReport, "red"
+		}
+		return c.Config.RedIndexes, "red"
+	default:
+		return "", ""
+	}
+}
+
+// filterUnhealthyIndexes applies the closed and red index handling modes.
+// It returns the indexes that count towards the totals, the subset that may
+// be deleted, and the indexes held back.
+func (c *Client) filterUnhealthyIndexes(indexes []IndexInfo) ([]IndexInfo, []IndexInfo, []SkippedIndex) {
+	var counted, candidates []IndexInfo
+	var skipped []SkippedIndex
+	for _, index := range indexes {
+		mode, state := c.unhealthyMode(index)
+		switch mode {
+		case config.UnhealthySkip:
+			c.Logger.Info("analysis", "unhealthy_index", "Skipping index", map[string]interface{}{
+				"index": index.Name,
+				"state": state,
+			})
+			skipped = append(skipped, SkippedIndex{Name: index.Name, Reason: fmt.Sprintf("%s index, skipped", state)})
+		case config.UnhealthyReport:
+			c.Logger.Warn("analysis", "unhealthy_index", "Index counts towards totals but will not be deleted", map[string]interface{}{
+				"index": index.Name,
+				"state": state,
+			})
+			counted = append(counted, index)
+			skipped = append(skipped, SkippedIndex{Name: index.Name, Reason: fmt.Sprintf("%s index, report only", state)})
+		default:
+			counted = append(counted, index)
+			candidates = append(candidates, index)
+		}
+	}
+	return counted, candidates, skipped
+}
@@ -0,0 +1,143 @@
+package elasticsearch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+func TestGetIndexesResolvesUncertainSizes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/_cat/indices"):
+			w.Write([]byte(`[
+				{"index": "app-2024.03.01", "health": "green", "status": "open", "store.size": "1000", "pri.store.size": "500", "docs.count": "10"},
+				{"index": "app-2024.03.02", "health": "", "status": "close", "store.size": "", "pri.store.size": ""},
+				{"index": "app-2024.03.03", "health": "red", "status": "open", "store.size": "100", "pri.store.size": "100", "docs.count": "1"},
+				{"index": "db-2024.03.01", "health": "green", "status": "close", "store.size": "", "pri.store.size": ""}
+			]`))
+		case r.URL.Path == "/db-2024.03.01/_stats/store,docs":
+			if r.URL.Query().Get("forbid_closed_indices") != "false" {
+				t.Errorf("Expected closed indices to be allowed in stats request")
+			}
+			w.Write([]byte(`{"indices": {"db-2024.03.01": {
+				"primaries": {"store": {"size_in_bytes": 400}, "docs": {"count": 7}},
+				"total": {"store": {"size_in_bytes": 800}, "docs": {"count": 14}}
+			}}}`))
+		case strings.HasSuffix(r.URL.Path, "/_stats/store,docs"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "index_closed_exception"}`))
+		case r.URL.Path == "/":
+			w.Write([]byte(`{"cluster_name":"test","version":{"number":"8.11.0"}}`))
+		case r.URL.Path == "/_data_stream/*":
+			w.Write([]byte(`{"data_streams":[]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(&config.Config{ESHost: server.URL}, log)
+
+	indexes, err := client.GetIndexes("*")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	byName := make(map[string]IndexInfo)
+	for _, index := range indexes {
+		byName[index.Name] = index
+	}
+
+	if open := byName["app-2024.03.01"]; open.SizeUncertain || open.SizeSource != SizeSourceCat {
+		t.Errorf("Expected open index size from _cat, got %+v", open)
+	}
+	if stats := byName["db-2024.03.01"]; stats.SizeBytes != 800 || stats.PrimaryBytes != 400 || stats.DocsCount != 7 || stats.SizeUncertain {
+		t.Errorf("Expected closed index sized from _stats, got %+v", stats)
+	}
+	if estimated := byName["app-2024.03.02"]; estimated.SizeBytes != 1000 || estimated.SizeSource != SizeSourceEstimate || !estimated.SizeUncertain {
+		t.Errorf("Expected closed index estimated from its family, got %+v", estimated)
+	}
+	if red := byName["app-2024.03.03"]; red.SizeBytes != 1000 || !red.SizeUncertain {
+		t.Errorf("Expected red index to take the larger family estimate, got %+v", red)
+	}
+}
+
+func TestAnalyzeIndexesUnhealthyModes(t *testing.T) {
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	indexes := []IndexInfo{
+		{Name: "closed", Status: StatusClose, CreationDate: old, SizeBytes: 100, SizeUncertain: true},
+		{Name: "open", Status: "open", Health: "green", CreationDate: old, SizeBytes: 100},
+	}
+
+	log, _ := logger.New(logger.DefaultConfig())
+
+	tests := []struct {
+		mode          string
+		wantDelete    int
+		wantTotal     int64
+		wantSkipped   int
+		wantUncertain int
+	}{
+		{config.UnhealthyDelete, 2, 200, 0, 1},
+		{config.UnhealthyReport, 1, 200, 1, 1},
+		{config.UnhealthySkip, 1, 100, 1, 0},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{MaxAgeDuration: 7 * 24 * time.Hour, ClosedIndexes: tt.mode}
+		client := NewClient(cfg, log)
+
+		toDelete, result := client.AnalyzeIndexes(append([]IndexInfo(nil), indexes...))
+		if len(toDelete) != tt.wantDelete {
+			t.Errorf("Mode %s: expected %d deletions, got %d", tt.mode, tt.wantDelete, len(toDelete))
+		}
+		if result.TotalSize != tt.wantTotal {
+			t.Errorf("Mode %s: expected total size %d, got %d", tt.mode, tt.wantTotal, result.TotalSize)
+		}
+		if len(result.Skipped) != tt.wantSkipped {
+			t.Errorf("Mode %s: expected %d skipped, got %d", tt.mode, tt.wantSkipped, len(result.Skipped))
+		}
+		if len(result.Uncertain) != tt.wantUncertain {
+			t.Errorf("Mode %s: expected %d uncertain, got %v", tt.mode, tt.wantUncertain, result.Uncertain)
+		}
+	}
+}
+
+func TestDocumentRulesSkipClosedIndexWithoutStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/_cat/indices"):
+			w.Write([]byte(`[
+				{"index": "app-2024.03.01", "health": "", "status": "close", "store.size": "", "pri.store.size": ""},
+				{"index": "app-2024.03.02", "health": "green", "status": "open", "store.size": "100", "pri.store.size": "100", "docs.count": "0"}
+			]`))
+		case strings.HasSuffix(r.URL.Path, "/_stats/store,docs"):
+			w.WriteHeader(http.StatusForbidden)
+		case r.URL.Path == "/":
+			w.Write([]byte(`{"cluster_name":"test","version":{"number":"8.11.0"}}`))
+		case r.URL.Path == "/_data_stream/*":
+			w.Write([]byte(`{"data_streams":[]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	log, _ := logger.New(logger.DefaultConfig())
+	client := NewClient(&config.Config{ESHost: server.URL, EmptyIndexAgeDuration: time.Hour, MaxDocs: 1}, log)
+
+	indexes, err := client.GetIndexes("app-*")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	toDelete, _ := client.AnalyzeIndexes(indexes)
+	if len(toDelete) != 1 || toDelete[0].Name != "app-2024.03.02" {
+		t.Errorf("Expected only the open empty index to be deleted, got %v", toDelete)
+	}
+}
@@ -0,0 +1,60 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WindowClosedError reports that deletions were deferred because no
+// maintenance window is open
+type WindowClosedError struct {
+	Windows  string
+	NextOpen time.Time
+}
+
+func (e *WindowClosedError) Error() string {
+	if e.NextOpen.IsZero() {
+		return fmt.Sprintf("outside maintenance window %s, which does not open again", e.Windows)
+	}
+	return fmt.Sprintf("outside maintenance window %s, next opens at %s", e.Windows, e.NextOpen.Format(time.RFC3339))
+}
+
+// waitForWindow checks that a maintenance window is open. Before the first
+// deletion, or when PauseOutsideWindow is off, a closed window returns a
+// WindowClosedError; once deletions have started and PauseOutsideWindow is
+// on, it waits for the next window instead.
+func (c *Client) waitForWindow(ctx context.Context, started bool) error {
+	windows := c.Config.Windows
+	now := time.Now()
+	if windows.IsOpen(now) {
+		return nil
+	}
+
+	closed := &WindowClosedError{Windows: windows.String(), NextOpen: windows.NextOpen(now)}
+	// Without a next opening there is nothing to wait for; never treat it
+	// as open
+	if !started || !c.PauseOutsideWindow || closed.NextOpen.IsZero() {
+		c.Logger.Warn("window", "check", "Outside maintenance window, deferring deletions", map[string]interface{}{
+			"windows":   closed.Windows,
+			"next_open": closed.NextOpen,
+		})
+		return closed
+	}
+
+	c.Logger.Warn("window", "wait", "Maintenance window closed, pausing deletions until the next window", map[string]interface{}{
+		"windows":   closed.Windows,
+		"next_open": closed.NextOpen,
+	})
+
+	timer := time.NewTimer(time.Until(closed.NextOpen))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	c.Logger.Info("window", "wait", "Maintenance window opened, resuming deletions")
+	return nil
+}
@@ -0,0 +1,740 @@
+// Package esfake is an in-memory Elasticsearch cluster for tests and dry
+// runs. It serves the subset of the REST API log-trimmer uses from seeded
+// fixtures, keeps state across requests so deletions and setting changes
+// are visible to later calls, and can inject latency, error responses and
+// dropped connections.
+package esfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Index is a seeded index. Unset fields get defaults: green health, open
+// status, one primary shard, a total size of PrimaryBytes times the shard
+// copies, and a creation date of Age before the fixture was loaded.
+type Index struct {
+	Name         string                 `json:"name" yaml:"name"`
+	UUID         string                 `json:"uuid" yaml:"uuid"`
+	Health       string                 `json:"health" yaml:"health"` // "green", "yellow" or "red"
+	Status       string                 `json:"status" yaml:"status"` // "open" or "close"
+	Primaries    int                    `json:"primaries" yaml:"primaries"`
+	Replicas     int                    `json:"replicas" yaml:"replicas"`
+	Docs         int64                  `json:"docs" yaml:"docs"`
+	DocsDeleted  int64                  `json:"docs_deleted" yaml:"docs_deleted"`
+	SizeBytes    int64                  `json:"size_bytes" yaml:"size_bytes"`
+	PrimaryBytes int64                  `json:"primary_bytes" yaml:"primary_bytes"`
+	CreatedAt    time.Time              `json:"created_at" yaml:"created_at"`
+	Age          string                 `json:"age" yaml:"age"`           // e.g. "36h" or "7d", used when CreatedAt is unset
+	Settings     map[string]interface{} `json:"settings" yaml:"settings"` // Extra index settings with dotted keys, e.g. "lifecycle.name"
+	Aliases      []string               `json:"aliases" yaml:"aliases"`
+}
+
+// DataStream is a seeded data stream. The last backing index is the write
+// index, which cannot be deleted.
+type DataStream struct {
+	Name    string   `json:"name" yaml:"name"`
+	Indexes []string `json:"indexes" yaml:"indexes"`
+}
+
+// Health overrides parts of the _cluster/health response. An empty Status
+// is derived from the worst health of the open indexes.
+type Health struct {
+	Status             string `json:"status" yaml:"status"`
+	Nodes              int    `json:"nodes" yaml:"nodes"`
+	RelocatingShards   int    `json:"relocating_shards" yaml:"relocating_shards"`
+	InitializingShards int    `json:"initializing_shards" yaml:"initializing_shards"`
+	UnassignedShards   int    `json:"unassigned_shards" yaml:"unassigned_shards"`
+	PendingTasks       int    `json:"pending_tasks" yaml:"pending_tasks"`
+}
+
+// Fixture is the seeded state of a fake cluster
+type Fixture struct {
+	ClusterName  string       `json:"cluster_name" yaml:"cluster_name"`
+	Version      string       `json:"version" yaml:"version"`
+	Distribution string       `json:"distribution" yaml:"distribution"` // Empty for Elasticsearch, or "opensearch"
+	Health       Health       `json:"health" yaml:"health"`
+	Indexes      []Index      `json:"indexes" yaml:"indexes"`
+	DataStreams  []DataStream `json:"data_streams" yaml:"data_streams"`
+}
+
+// LoadFixture reads a fixture from a YAML or JSON file
+func LoadFixture(file string) (Fixture, error) {
+	var fixture Fixture
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fixture, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		err = json.Unmarshal(data, &fixture)
+	} else {
+		err = yaml.Unmarshal(data, &fixture)
+	}
+	if err != nil {
+		return fixture, fmt.Errorf("failed to parse fixture %s: %w", file, err)
+	}
+	return fixture, nil
+}
+
+// Cluster is the in-memory cluster state. It implements http.Handler, so it
+// can be served with http.ListenAndServe as well as through Start.
+type Cluster struct {
+	mu           sync.Mutex
+	name         string
+	version      string
+	distribution string
+	health       Health
+	indexes      map[string]*Index
+	dataStreams  map[string]*DataStream
+	faults       []*Fault
+	requests     []string
+}
+
+// NewCluster returns a cluster seeded from fixture
+func NewCluster(fixture Fixture) (*Cluster, error) {
+	c := &Cluster{
+		name:         fixture.ClusterName,
+		version:      fixture.Version,
+		distribution: fixture.Distribution,
+		health:       fixture.Health,
+		indexes:      make(map[string]*Index),
+		dataStreams:  make(map[string]*DataStream),
+	}
+	if c.name == "" {
+		c.name = "esfake"
+	}
+	if c.version == "" {
+		c.version = "8.11.0"
+	}
+	if c.health.Nodes == 0 {
+		c.health.Nodes = 1
+	}
+
+	for _, index := range fixture.Indexes {
+		if err := c.AddIndex(index); err != nil {
+			return nil, err
+		}
+	}
+	for _, stream := range fixture.DataStreams {
+		for _, name := range stream.Indexes {
+			if _, ok := c.indexes[name]; !ok {
+				return nil, fmt.Errorf("data stream %s: unknown backing index %s", stream.Name, name)
+			}
+		}
+		stream := stream
+		c.dataStreams[stream.Name] = &stream
+	}
+	return c, nil
+}
+
+// AddIndex adds or replaces an index, filling in defaults
+func (c *Cluster) AddIndex(index Index) error {
+	if index.Name == "" {
+		return fmt.Errorf("index name is required")
+	}
+	if index.UUID == "" {
+		index.UUID = fmt.Sprintf("uuid-%s", index.Name)
+	}
+	if index.Health == "" {
+		index.Health = "green"
+	}
+	if index.Status == "" {
+		index.Status = "open"
+	}
+	if index.Primaries == 0 {
+		index.Primaries = 1
+	}
+	if index.SizeBytes == 0 {
+		index.SizeBytes = index.PrimaryBytes * int64(1+index.Replicas)
+	}
+	if index.CreatedAt.IsZero() {
+		age, err := parseAge(index.Age)
+		if err != nil {
+			return fmt.Errorf("index %s: %w", index.Name, err)
+		}
+		index.CreatedAt = time.Now().Add(-age)
+	}
+	if index.Settings == nil {
+		index.Settings = make(map[string]interface{})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indexes[index.Name] = &index
+	return nil
+}
+
+// parseAge parses a Go duration or a whole number of days such as "7d"
+func parseAge(age string) (time.Duration, error) {
+	if age == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(age, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age '%s'", age)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	duration, err := time.ParseDuration(age)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age '%s'", age)
+	}
+	return duration, nil
+}
+
+// Index returns a copy of the named index
+func (c *Cluster) Index(name string) (Index, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index, ok := c.indexes[name]
+	if !ok {
+		return Index{}, false
+	}
+	return *index, true
+}
+
+// IndexNames returns the names of all indexes, sorted
+func (c *Cluster) IndexNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sortedNames()
+}
+
+// Requests returns the requests served so far as "METHOD /path"
+func (c *Cluster) Requests() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.requests...)
+}
+
+// Server is a Cluster served over HTTP on a local port
+type Server struct {
+	*Cluster
+	URL string
+
+	server *httptest.Server
+}
+
+// Start serves a cluster seeded from fixture until Close is called
+func Start(fixture Fixture) (*Server, error) {
+	cluster, err := NewCluster(fixture)
+	if err != nil {
+		return nil, err
+	}
+	server := httptest.NewServer(cluster)
+	return &Server{Cluster: cluster, URL: server.URL, server: server}, nil
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// ServeHTTP routes a request to the matching API handler after applying
+// any injected fault
+func (c *Cluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	c.requests = append(c.requests, r.Method+" "+r.URL.Path)
+	fault := c.matchFault(r)
+	c.mu.Unlock()
+
+	if fault != nil && fault.apply(w) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/" && r.Method == http.MethodGet:
+		c.serveRoot(w)
+	case parts[0] == "_cluster" && len(parts) == 2 && parts[1] == "health":
+		c.serveHealth(w)
+	case parts[0] == "_cat" && len(parts) >= 2 && parts[1] == "indices":
+		c.serveCatIndices(w, catTarget(parts))
+	case parts[0] == "_cat" && len(parts) == 2 && parts[1] == "nodes":
+		c.serveCatNodes(w, r)
+	case parts[0] == "_cat" && len(parts) >= 2 && parts[1] == "aliases":
+		c.serveCatAliases(w, catTarget(parts))
+	case parts[0] == "_alias" || parts[0] == "_aliases":
+		c.serveAliases(w, "*", catTarget(append([]string{""}, parts...)))
+	case parts[0] == "_data_stream":
+		c.serveDataStreams(w, catTarget(append([]string{""}, parts...)))
+	case strings.HasPrefix(parts[0], "_"):
+		writeError(w, http.StatusBadRequest, "illegal_argument_exception", fmt.Sprintf("unsupported endpoint %s %s", r.Method, r.URL.Path))
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		c.deleteIndexes(w, parts[0])
+	case len(parts) >= 2 && parts[1] == "_settings" && r.Method == http.MethodGet:
+		c.serveSettings(w, parts[0])
+	case len(parts) >= 2 && parts[1] == "_settings" && r.Method == http.MethodPut:
+		c.updateSettings(w, r, parts[0])
+	case len(parts) == 2 && (parts[1] == "_close" || parts[1] == "_open") && r.Method == http.MethodPost:
+		c.setStatus(w, parts[0], parts[1] == "_close")
+	case len(parts) >= 2 && parts[1] == "_stats":
+		c.serveStats(w, r, parts[0])
+	case len(parts) >= 2 && (parts[1] == "_alias" || parts[1] == "_aliases"):
+		c.serveAliases(w, parts[0], catTarget(parts))
+	default:
+		writeError(w, http.StatusBadRequest, "illegal_argument_exception", fmt.Sprintf("unsupported endpoint %s %s", r.Method, r.URL.Path))
+	}
+}
+
+// catTarget returns the target following a two-segment endpoint such as
+// _cat/indices, or "*" when there is none
+func catTarget(parts []string) string {
+	if len(parts) >= 3 && parts[2] != "" {
+		return parts[2]
+	}
+	return "*"
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes an Elasticsearch style error response
+func writeError(w http.ResponseWriter, status int, errorType, reason string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error":  map[string]interface{}{"type": errorType, "reason": reason},
+		"status": status,
+	})
+}
+
+// resolve expands a comma-separated list of index names, aliases, data
+// streams and wildcards into sorted index names. Unless wildcards match
+// nothing, a missing name is reported as an error.
+func (c *Cluster) resolve(target string) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, expr := range strings.Split(target, ",") {
+		if expr == "_all" {
+			expr = "*"
+		}
+		matched := false
+		for name, index := range c.indexes {
+			if ok, _ := path.Match(expr, name); ok {
+				seen[name], matched = true, true
+				continue
+			}
+			for _, alias := range index.Aliases {
+				if ok, _ := path.Match(expr, alias); ok {
+					seen[name], matched = true, true
+				}
+			}
+		}
+		for name, stream := range c.dataStreams {
+			if ok, _ := path.Match(expr, name); ok {
+				for _, backing := range stream.Indexes {
+					seen[backing], matched = true, true
+				}
+			}
+		}
+		if !matched && !strings.Contains(expr, "*") {
+			return nil, fmt.Errorf("no such index [%s]", expr)
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// serveRoot serves GET /
+func (c *Cluster) serveRoot(w http.ResponseWriter) {
+	version := map[string]interface{}{"number": c.version}
+	if c.distribution != "" {
+		version["distribution"] = c.distribution
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":         "esfake-0",
+		"cluster_name": c.name,
+		"version":      version,
+	})
+}
+
+// serveCatNodes serves GET _cat/nodes for the single fake node. The elected
+// master column is "cluster_manager" on OpenSearch 2 and later, where
+// "master" is kept as an alias, and "master" everywhere else.
+func (c *Cluster) serveCatNodes(w http.ResponseWriter, r *http.Request) {
+	clusterManager := c.distribution == "opensearch" && !strings.HasPrefix(c.version, "1.")
+	row := map[string]interface{}{}
+	for _, column := range strings.Split(r.URL.Query().Get("h"), ",") {
+		switch {
+		case column == "name":
+			row[column] = "esfake-0"
+		case column == "master" || (column == "cluster_manager" && clusterManager):
+			row[column] = "*"
+		case column == "":
+		default:
+			writeError(w, http.StatusBadRequest, "illegal_argument_exception", fmt.Sprintf("unknown header [%s]", column))
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, []map[string]interface{}{row})
+}
+
+// healthRank orders index health from best to worst
+var healthRank = map[string]int{"green": 0, "yellow": 1, "red": 2}
+
+// serveHealth serves GET /_cluster/health
+func (c *Cluster) serveHealth(w http.ResponseWriter) {
+	status, shards := "green", 0
+	for _, index := range c.indexes {
+		if index.Status != "open" {
+			continue
+		}
+		shards += index.Primaries * (1 + index.Replicas)
+		if healthRank[index.Health] > healthRank[status] {
+			status = index.Health
+		}
+	}
+	if c.health.Status != "" {
+		status = c.health.Status
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"cluster_name":            c.name,
+		"status":                  status,
+		"number_of_nodes":         c.health.Nodes,
+		"active_shards":           shards - c.health.UnassignedShards,
+		"relocating_shards":       c.health.RelocatingShards,
+		"initializing_shards":     c.health.InitializingShards,
+		"unassigned_shards":       c.health.UnassignedShards,
+		"number_of_pending_tasks": c.health.PendingTasks,
+	})
+}
+
+// serveCatIndices serves GET /_cat/indices/{target}?format=json&bytes=b.
+// Like Elasticsearch, closed indexes report no sizes or document counts.
+func (c *Cluster) serveCatIndices(w http.ResponseWriter, target string) {
+	names, err := c.resolve(target)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "index_not_found_exception", err.Error())
+		return
+	}
+
+	rows := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		index := c.indexes[name]
+		row := map[string]string{
+			"index":  index.Name,
+			"uuid":   index.UUID,
+			"health": index.Health,
+			"status": index.Status,
+			"pri":    strconv.Itoa(index.Primaries),
+			"rep":    strconv.Itoa(index.Replicas),
+		}
+		if index.Status == "open" {
+			row["docs.count"] = strconv.FormatInt(index.Docs, 10)
+			row["docs.deleted"] = strconv.FormatInt(index.DocsDeleted, 10)
+			row["store.size"] = strconv.FormatInt(index.SizeBytes, 10)
+			row["pri.store.size"] = strconv.FormatInt(index.PrimaryBytes, 10)
+		}
+		rows = append(rows, row)
+	}
+	writeJSON(w, http.StatusOK, rows)
+}
+
+// serveCatAliases serves GET /_cat/aliases/{alias}?format=json
+func (c *Cluster) serveCatAliases(w http.ResponseWriter, target string) {
+	rows := []map[string]string{}
+	for _, name := range c.sortedNames() {
+		for _, alias := range c.indexes[name].Aliases {
+			if ok, _ := path.Match(target, alias); ok {
+				rows = append(rows, map[string]string{"alias": alias, "index": name})
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, rows)
+}
+
+// serveAliases serves GET /{target}/_alias/{alias} and GET /_alias/{alias}
+func (c *Cluster) serveAliases(w http.ResponseWriter, target, alias string) {
+	names, err := c.resolve(target)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "index_not_found_exception", err.Error())
+		return
+	}
+
+	body := make(map[string]interface{})
+	for _, name := range names {
+		aliases := make(map[string]interface{})
+		for _, a := range c.indexes[name].Aliases {
+			if ok, _ := path.Match(alias, a); ok {
+				aliases[a] = map[string]interface{}{}
+			}
+		}
+		if len(aliases) > 0 || alias == "*" {
+			body[name] = map[string]interface{}{"aliases": aliases}
+		}
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// serveDataStreams serves GET /_data_stream/{name}
+func (c *Cluster) serveDataStreams(w http.ResponseWriter, target string) {
+	streams := []map[string]interface{}{}
+	var names []string
+	for name := range c.dataStreams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if ok, _ := path.Match(target, name); !ok {
+			continue
+		}
+		stream := c.dataStreams[name]
+		var backing []map[string]string
+		for _, index := range stream.Indexes {
+			backing = append(backing, map[string]string{"index_name": index, "index_uuid": c.indexes[index].UUID})
+		}
+		streams = append(streams, map[string]interface{}{
+			"name":            stream.Name,
+			"timestamp_field": map[string]string{"name": "@timestamp"},
+			"indices":         backing,
+			"generation":      len(stream.Indexes),
+			"status":          "GREEN",
+		})
+	}
+	if len(streams) == 0 && !strings.Contains(target, "*") {
+		writeError(w, http.StatusNotFound, "index_not_found_exception", fmt.Sprintf("no such index [%s]", target))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data_streams": streams})
+}
+
+// deleteIndexes serves DELETE /{target}. As in Elasticsearch, wildcards,
+// aliases and the write index of a data stream are refused.
+func (c *Cluster) deleteIndexes(w http.ResponseWriter, target string) {
+	var names []string
+	for _, name := range strings.Split(target, ",") {
+		if strings.Contains(name, "*") || name == "_all" {
+			writeError(w, http.StatusBadRequest, "illegal_argument_exception", "Wildcard expressions or all indices are not allowed")
+			return
+		}
+		if _, ok := c.indexes[name]; !ok {
+			if c.isAlias(name) {
+				writeError(w, http.StatusBadRequest, "illegal_argument_exception", fmt.Sprintf("The provided expression [%s] matches an alias, specify the corresponding concrete indices instead.", name))
+				return
+			}
+			writeError(w, http.StatusNotFound, "index_not_found_exception", fmt.Sprintf("no such index [%s]", name))
+			return
+		}
+		for _, stream := range c.dataStreams {
+			if last := len(stream.Indexes) - 1; last >= 0 && stream.Indexes[last] == name {
+				writeError(w, http.StatusBadRequest, "illegal_argument_exception", fmt.Sprintf("index [%s] is the write index for data stream [%s] and cannot be deleted", name, stream.Name))
+				return
+			}
+		}
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		delete(c.indexes, name)
+		for _, stream := range c.dataStreams {
+			for i, backing := range stream.Indexes {
+				if backing == name {
+					stream.Indexes = append(stream.Indexes[:i], stream.Indexes[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"acknowledged": true})
+}
+
+// isAlias reports whether name is an alias of any index
+func (c *Cluster) isAlias(name string) bool {
+	for _, index := range c.indexes {
+		for _, alias := range index.Aliases {
+			if alias == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sortedNames returns the index names in order
+func (c *Cluster) sortedNames() []string {
+	names := make([]string, 0, len(c.indexes))
+	for name := range c.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// indexSettings returns the settings of an index as Elasticsearch nests
+// them under "index"
+func indexSettings(index *Index) map[string]interface{} {
+	flat := map[string]interface{}{
+		"creation_date":      strconv.FormatInt(index.CreatedAt.UnixMilli(), 10),
+		"number_of_shards":   strconv.Itoa(index.Primaries),
+		"number_of_replicas": strconv.Itoa(index.Replicas),
+		"uuid":               index.UUID,
+		"provided_name":      index.Name,
+	}
+	if index.Status == "close" {
+		flat["verified_before_close"] = "true"
+	}
+	for key, value := range index.Settings {
+		flat[key] = value
+	}
+
+	nested := make(map[string]interface{})
+	for key, value := range flat {
+		current := nested
+		keys := strings.Split(key, ".")
+		for _, k := range keys[:len(keys)-1] {
+			next, ok := current[k].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				current[k] = next
+			}
+			current = next
+		}
+		current[keys[len(keys)-1]] = value
+	}
+	return map[string]interface{}{"index": nested}
+}
+
+// serveSettings serves GET /{target}/_settings
+func (c *Cluster) serveSettings(w http.ResponseWriter, target string) {
+	names, err := c.resolve(target)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "index_not_found_exception", err.Error())
+		return
+	}
+
+	body := make(map[string]interface{})
+	for _, name := range names {
+		body[name] = map[string]interface{}{"settings": indexSettings(c.indexes[name])}
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// flatten converts nested settings into dotted keys without the leading
+// "index." prefix
+func flatten(prefix string, value interface{}, out map[string]interface{}) {
+	if nested, ok := value.(map[string]interface{}); ok {
+		for key, v := range nested {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flatten(key, v, out)
+		}
+		return
+	}
+	out[strings.TrimPrefix(prefix, "index.")] = value
+}
+
+// updateSettings serves PUT /{target}/_settings. number_of_replicas also
+// resizes the index; a null value removes a setting.
+func (c *Cluster) updateSettings(w http.ResponseWriter, r *http.Request, target string) {
+	names, err := c.resolve(target)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "index_not_found_exception", err.Error())
+		return
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "parse_exception", err.Error())
+		return
+	}
+	settings := make(map[string]interface{})
+	flatten("", body, settings)
+
+	for _, name := range names {
+		index := c.indexes[name]
+		for key, value := range settings {
+			if key == "number_of_replicas" {
+				replicas, err := strconv.Atoi(fmt.Sprint(value))
+				if err != nil || replicas < 0 {
+					writeError(w, http.StatusBadRequest, "illegal_argument_exception", fmt.Sprintf("invalid number_of_replicas [%v]", value))
+					return
+				}
+				index.Replicas = replicas
+				index.SizeBytes = index.PrimaryBytes * int64(1+replicas)
+				continue
+			}
+			if value == nil {
+				delete(index.Settings, key)
+			} else {
+				index.Settings[key] = value
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"acknowledged": true})
+}
+
+// setStatus serves POST /{target}/_close and /_open
+func (c *Cluster) setStatus(w http.ResponseWriter, target string, closed bool) {
+	names, err := c.resolve(target)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "index_not_found_exception", err.Error())
+		return
+	}
+
+	for _, name := range names {
+		if closed {
+			c.indexes[name].Status = "close"
+		} else {
+			c.indexes[name].Status = "open"
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"acknowledged": true})
+}
+
+// serveStats serves GET /{target}/_stats. Closed indexes are only included
+// with forbid_closed_indices=false.
+func (c *Cluster) serveStats(w http.ResponseWriter, r *http.Request, target string) {
+	names, err := c.resolve(target)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "index_not_found_exception", err.Error())
+		return
+	}
+	includeClosed := r.URL.Query().Get("forbid_closed_indices") == "false"
+
+	indices := make(map[string]interface{})
+	for _, name := range names {
+		index := c.indexes[name]
+		if index.Status == "close" && !includeClosed {
+			continue
+		}
+		indices[name] = map[string]interface{}{
+			"uuid": index.UUID,
+			"primaries": map[string]interface{}{
+				"docs":  map[string]int64{"count": index.Docs, "deleted": index.DocsDeleted},
+				"store": map[string]int64{"size_in_bytes": index.PrimaryBytes},
+			},
+			"total": map[string]interface{}{
+				"docs":  map[string]int64{"count": index.Docs * int64(1+index.Replicas), "deleted": index.DocsDeleted * int64(1+index.Replicas)},
+				"store": map[string]int64{"size_in_bytes": index.SizeBytes},
+			},
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"indices": indices})
+}
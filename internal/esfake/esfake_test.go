@@ -0,0 +1,141 @@
+package esfake
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startFixture(t *testing.T) *Server {
+	t.Helper()
+	fixture, err := LoadFixture("testdata/cluster.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	server, err := Start(fixture)
+	if err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func do(t *testing.T, server *Server, method, path string) (*http.Response, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(method, server.URL+path, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	var body json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp, body
+}
+
+func TestCatIndicesResolvesAliasesAndDataStreams(t *testing.T) {
+	server := startFixture(t)
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"logs-*", 2},
+		{"logs-app", 2},
+		{"metrics", 2},
+		{"*", 4},
+		{"missing-*", 0},
+	}
+
+	for _, tt := range tests {
+		resp, body := do(t, server, "GET", "/_cat/indices/"+tt.target+"?format=json&bytes=b")
+		var rows []map[string]string
+		if err := json.Unmarshal(body, &rows); err != nil || resp.StatusCode != 200 {
+			t.Fatalf("Target %s: unexpected response %d: %s", tt.target, resp.StatusCode, body)
+		}
+		if len(rows) != tt.want {
+			t.Errorf("Target %s: expected %d indexes, got %d", tt.target, tt.want, len(rows))
+		}
+	}
+
+	_, body := do(t, server, "GET", "/_cat/indices/logs-app-2024.03.01?format=json&bytes=b")
+	if !strings.Contains(string(body), `"store.size":"2000"`) {
+		t.Errorf("Expected total size to include the replica, got %s", body)
+	}
+}
+
+func TestDeleteFollowsElasticsearchRules(t *testing.T) {
+	server := startFixture(t)
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"logs-*", http.StatusBadRequest},
+		{"logs-app", http.StatusBadRequest},
+		{".ds-metrics-000002", http.StatusBadRequest},
+		{"missing", http.StatusNotFound},
+		{".ds-metrics-000001", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if resp, body := do(t, server, "DELETE", "/"+tt.target); resp.StatusCode != tt.status {
+			t.Errorf("DELETE %s: expected %d, got %d: %s", tt.target, tt.status, resp.StatusCode, body)
+		}
+	}
+
+	if _, ok := server.Index(".ds-metrics-000001"); ok {
+		t.Error("Expected deleted index to be gone")
+	}
+	_, body := do(t, server, "GET", "/_data_stream/metrics")
+	if strings.Contains(string(body), ".ds-metrics-000001") {
+		t.Errorf("Expected deleted index to leave the data stream, got %s", body)
+	}
+}
+
+func TestSettingsRoundTrip(t *testing.T) {
+	server := startFixture(t)
+
+	_, body := do(t, server, "GET", "/logs-app-2024.03.02/_settings")
+	if !strings.Contains(string(body), `"lifecycle":{"name":"logs-policy"}`) {
+		t.Errorf("Expected nested lifecycle setting, got %s", body)
+	}
+
+	req, _ := http.NewRequest("PUT", server.URL+"/logs-app-2024.03.02/_settings", strings.NewReader(`{"index.number_of_replicas": 0}`))
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Failed to update settings: %v", err)
+	}
+	if index, _ := server.Index("logs-app-2024.03.02"); index.Replicas != 0 || index.SizeBytes != 1000 {
+		t.Errorf("Expected replicas dropped and size halved, got %+v", index)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	server := startFixture(t)
+
+	server.Inject(Fault{Path: "/_cluster/health", Status: http.StatusTooManyRequests, Times: 1})
+	if resp, _ := do(t, server, "GET", "/_cluster/health"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected injected 429, got %d", resp.StatusCode)
+	}
+	if resp, _ := do(t, server, "GET", "/_cluster/health"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected fault to expire after one request, got %d", resp.StatusCode)
+	}
+
+	server.Inject(Fault{Method: "DELETE", Drop: true})
+	req, _ := http.NewRequest("DELETE", server.URL+"/.ds-metrics-000001", nil)
+	if _, err := http.DefaultClient.Do(req); err == nil {
+		t.Error("Expected dropped connection to fail the request")
+	}
+	server.ClearFaults()
+
+	server.Inject(Fault{Latency: 50 * time.Millisecond})
+	start := time.Now()
+	if resp, _ := do(t, server, "GET", "/"); resp.StatusCode != http.StatusOK || time.Since(start) < 50*time.Millisecond {
+		t.Errorf("Expected delayed success, got %d after %v", resp.StatusCode, time.Since(start))
+	}
+
+	if requests := server.Requests(); len(requests) != 4 || requests[0] != "GET /_cluster/health" {
+		t.Errorf("Unexpected request log: %v", requests)
+	}
+}
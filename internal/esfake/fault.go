@@ -0,0 +1,86 @@
+package esfake
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Fault describes a failure injected into matching requests. Latency is
+// added before the fault takes effect; Drop closes the connection without
+// a response, otherwise a non-zero Status is returned as an error response.
+// A fault with only Latency delays the request and then serves it normally.
+type Fault struct {
+	Method  string        // Matches any method when empty
+	Path    string        // Matches paths with this prefix; any path when empty
+	Latency time.Duration // Delay before responding
+	Status  int           // e.g. 429 or 503
+	Drop    bool          // Close the connection without responding
+	Times   int           // Number of requests to affect; 0 affects every request
+}
+
+// Inject adds a fault. Faults are matched in the order they were injected.
+func (c *Cluster) Inject(fault Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, &fault)
+}
+
+// ClearFaults removes all injected faults
+func (c *Cluster) ClearFaults() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = nil
+}
+
+// matchFault returns the first fault matching r and uses up one of its
+// remaining requests. The caller holds c.mu.
+func (c *Cluster) matchFault(r *http.Request) *Fault {
+	for i, fault := range c.faults {
+		if fault.Method != "" && !strings.EqualFold(fault.Method, r.Method) {
+			continue
+		}
+		if !strings.HasPrefix(r.URL.Path, fault.Path) {
+			continue
+		}
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				c.faults = append(c.faults[:i], c.faults[i+1:]...)
+			}
+		}
+		return fault
+	}
+	return nil
+}
+
+// apply carries out the fault and reports whether it produced the response
+func (f *Fault) apply(w http.ResponseWriter) bool {
+	if f.Latency > 0 {
+		time.Sleep(f.Latency)
+	}
+
+	if f.Drop {
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				conn.Close()
+				return true
+			}
+		}
+		// Without hijacking support, fail the request as abruptly as possible
+		panic(http.ErrAbortHandler)
+	}
+
+	if f.Status != 0 {
+		errorType := "internal_server_error"
+		switch f.Status {
+		case http.StatusTooManyRequests:
+			errorType = "es_rejected_execution_exception"
+		case http.StatusServiceUnavailable:
+			errorType = "cluster_block_exception"
+		}
+		writeError(w, f.Status, errorType, "injected fault")
+		return true
+	}
+	return false
+}
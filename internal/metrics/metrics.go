@@ -0,0 +1,274 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestBuckets are the histogram buckets for request latency, in seconds
+var requestBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// histogram is a cumulative latency histogram
+type histogram struct {
+	counts []uint64 // One per bucket, non-cumulative
+	sum    float64
+	count  uint64
+}
+
+// Registry collects the trimmer metrics and renders them in the Prometheus
+// text exposition format. All methods are safe to call on a nil Registry,
+// which discards everything.
+type Registry struct {
+	mu sync.Mutex
+
+	indexesMatched   map[string]int64
+	policySize       map[string]int64
+	indexesDeleted   uint64
+	bytesReclaimed   uint64
+	deletionFailures uint64
+	runs             map[string]uint64
+	lastSuccess      time.Time
+	requests         map[string]*histogram
+}
+
+// NewRegistry creates an empty metrics registry
+func NewRegistry() *Registry {
+	return &Registry{
+		indexesMatched: make(map[string]int64),
+		policySize:     make(map[string]int64),
+		runs:           make(map[string]uint64),
+		requests:       make(map[string]*histogram),
+	}
+}
+
+// SetPolicyState records how many indexes a policy matched and their size
+func (r *Registry) SetPolicyState(policy string, matched int, sizeBytes int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.indexesMatched[policy] = int64(matched)
+	r.policySize[policy] = sizeBytes
+}
+
+// AddDeleted records a successfully deleted index
+func (r *Registry) AddDeleted(sizeBytes int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.indexesDeleted++
+	r.bytesReclaimed += uint64(sizeBytes)
+}
+
+// AddDeletionFailure records a failed index deletion
+func (r *Registry) AddDeletionFailure() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deletionFailures++
+}
+
+// RecordRun records the outcome of a run; successful runs update the last
+// success timestamp
+func (r *Registry) RecordRun(success bool, at time.Time) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if success {
+		r.runs["success"]++
+		r.lastSuccess = at
+	} else {
+		r.runs["failure"]++
+	}
+}
+
+// ObserveRequest records the latency of a request to an Elasticsearch endpoint
+func (r *Registry) ObserveRequest(endpoint string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.requests[endpoint]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(requestBuckets))}
+		r.requests[endpoint] = h
+	}
+
+	seconds := d.Seconds()
+	for i, bound := range requestBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// WriteTo renders all metrics in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	if r != nil {
+		r.mu.Lock()
+		r.render(&b)
+		r.mu.Unlock()
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// render writes the exposition text; the caller must hold the lock
+func (r *Registry) render(b *strings.Builder) {
+	writeHeader(b, "log_trimmer_indexes_matched", "gauge", "Number of indexes matched by each policy in the last analysis.")
+	for _, policy := range sortedKeys(r.indexesMatched) {
+		fmt.Fprintf(b, "log_trimmer_indexes_matched{policy=\"%s\"} %d\n", escapeLabel(policy), r.indexesMatched[policy])
+	}
+
+	writeHeader(b, "log_trimmer_policy_size_bytes", "gauge", "Current total size of the indexes matched by each policy.")
+	for _, policy := range sortedKeys(r.policySize) {
+		fmt.Fprintf(b, "log_trimmer_policy_size_bytes{policy=\"%s\"} %d\n", escapeLabel(policy), r.policySize[policy])
+	}
+
+	writeHeader(b, "log_trimmer_indexes_deleted_total", "counter", "Total number of indexes deleted.")
+	fmt.Fprintf(b, "log_trimmer_indexes_deleted_total %d\n", r.indexesDeleted)
+
+	writeHeader(b, "log_trimmer_bytes_reclaimed_total", "counter", "Total store bytes reclaimed by deleting indexes.")
+	fmt.Fprintf(b, "log_trimmer_bytes_reclaimed_total %d\n", r.bytesReclaimed)
+
+	writeHeader(b, "log_trimmer_deletion_failures_total", "counter", "Total number of failed index deletions.")
+	fmt.Fprintf(b, "log_trimmer_deletion_failures_total %d\n", r.deletionFailures)
+
+	writeHeader(b, "log_trimmer_runs_total", "counter", "Total number of runs by result.")
+	for _, result := range []string{"success", "failure"} {
+		fmt.Fprintf(b, "log_trimmer_runs_total{result=\"%s\"} %d\n", escapeLabel(result), r.runs[result])
+	}
+
+	writeHeader(b, "log_trimmer_last_success_timestamp_seconds", "gauge", "Unix time of the last successful run.")
+	lastSuccess := int64(0)
+	if !r.lastSuccess.IsZero() {
+		lastSuccess = r.lastSuccess.Unix()
+	}
+	fmt.Fprintf(b, "log_trimmer_last_success_timestamp_seconds %d\n", lastSuccess)
+
+	writeHeader(b, "log_trimmer_request_duration_seconds", "histogram", "Latency of Elasticsearch requests by endpoint.")
+	for _, endpoint := range sortedKeys(r.requests) {
+		h := r.requests[endpoint]
+		var cumulative uint64
+		for i, bound := range requestBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(b, "log_trimmer_request_duration_seconds_bucket{endpoint=\"%s\",le=\"%s\"} %d\n", escapeLabel(endpoint), formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(b, "log_trimmer_request_duration_seconds_bucket{endpoint=\"%s\",le=\"+Inf\"} %d\n", escapeLabel(endpoint), h.count)
+		fmt.Fprintf(b, "log_trimmer_request_duration_seconds_sum{endpoint=\"%s\"} %s\n", escapeLabel(endpoint), formatFloat(h.sum))
+		fmt.Fprintf(b, "log_trimmer_request_duration_seconds_count{endpoint=\"%s\"} %d\n", escapeLabel(endpoint), h.count)
+	}
+}
+
+// Handler returns an HTTP handler serving the metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+// Serve starts an HTTP listener exposing the metrics on /metrics. It
+// returns the server so the caller can shut it down.
+func (r *Registry) Serve(addr string) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r.Handler())
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+
+	// Surface immediate bind failures to the caller
+	select {
+	case err := <-errCh:
+		return nil, fmt.Errorf("failed to start metrics listener: %w", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	return server, nil
+}
+
+// WriteTextfile atomically writes the metrics to path for the node_exporter
+// textfile collector, using a temporary file in the same directory
+func (r *Registry) WriteTextfile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create metrics textfile: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := r.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace metrics textfile: %w", err)
+	}
+	return nil
+}
+
+// writeHeader writes the HELP and TYPE lines for a metric family
+func writeHeader(b *strings.Builder, name, metricType, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, metricType)
+}
+
+// labelEscaper escapes a label value as the exposition format requires.
+// Go's %q escaping differs for non-ASCII and control characters.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes backslashes, double quotes and newlines in a label
+// value
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// formatFloat formats a float without trailing zeros
+func formatFloat(f float64) string {
+	return fmt.Sprintf("%g", f)
+}
+
+// sortedKeys returns the keys of a map in sorted order for stable output
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	r := NewRegistry()
+	r.SetPolicyState("logs-*", 12, 4096)
+	r.AddDeleted(1024)
+	r.AddDeleted(2048)
+	r.AddDeletionFailure()
+	r.RecordRun(true, time.Unix(1700000000, 0))
+	r.ObserveRequest("GET /_cat/indices", 30*time.Millisecond)
+	r.ObserveRequest("GET /_cat/indices", 2*time.Second)
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := b.String()
+
+	expected := []string{
+		`log_trimmer_indexes_matched{policy="logs-*"} 12`,
+		`log_trimmer_policy_size_bytes{policy="logs-*"} 4096`,
+		`log_trimmer_indexes_deleted_total 2`,
+		`log_trimmer_bytes_reclaimed_total 3072`,
+		`log_trimmer_deletion_failures_total 1`,
+		`log_trimmer_runs_total{result="success"} 1`,
+		`log_trimmer_last_success_timestamp_seconds 1700000000`,
+		`log_trimmer_request_duration_seconds_bucket{endpoint="GET /_cat/indices",le="0.05"} 1`,
+		`log_trimmer_request_duration_seconds_bucket{endpoint="GET /_cat/indices",le="2.5"} 2`,
+		`log_trimmer_request_duration_seconds_count{endpoint="GET /_cat/indices"} 2`,
+		`# TYPE log_trimmer_request_duration_seconds histogram`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected output to contain %q", line)
+		}
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	var r *Registry
+	r.AddDeleted(1)
+	r.ObserveRequest("GET /", time.Second)
+	r.RecordRun(true, time.Now())
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.AddDeleted(10)
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Expected text/plain content type, got %s", rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "log_trimmer_indexes_deleted_total 1\n") {
+		t.Error("Expected deleted counter in response body")
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	r := NewRegistry()
+	r.AddDeletionFailure()
+
+	path := filepath.Join(t.TempDir(), "log_trimmer.prom")
+	if err := r.WriteTextfile(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read textfile: %v", err)
+	}
+	if !strings.Contains(string(data), "log_trimmer_deletion_failures_total 1\n") {
+		t.Error("Expected failure counter in textfile")
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected temporary file to be cleaned up, found %d entries", len(entries))
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"logs-*", "logs-*"},
+		{"café-logs", "café-logs"},
+		{`a\b`, `a\\b`},
+		{`say "hi"`, `say \"hi\"`},
+		{"two\nlines", `two\nlines`},
+		{"tab\there", "tab\there"},
+	}
+	for _, tt := range tests {
+		if got := escapeLabel(tt.input); got != tt.expected {
+			t.Errorf("escapeLabel(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+
+	r := NewRegistry()
+	r.SetPolicyState("café", 1, 2)
+	var b strings.Builder
+	r.WriteTo(&b)
+	if !strings.Contains(b.String(), `log_trimmer_indexes_matched{policy="café"} 1`) {
+		t.Errorf("Expected non-ASCII policy names to be written as is, got:\n%s", b.String())
+	}
+}
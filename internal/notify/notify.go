@@ -0,0 +1,308 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/elasticsearch"
+	"github.com/company/log-trimmer/internal/logger"
+	"github.com/company/log-trimmer/pkg/utils"
+)
+
+// retryDelay is the wait before the first retry; it doubles on each attempt
+var retryDelay = time.Second
+
+// defaultText is the message used by the Slack and Teams presets when no
+// template is configured
+const defaultText = `log-trimmer {{.Policy}}: {{.Summary}}{{range .Failures}}
+- {{.Index}}: {{.Error}}{{end}}`
+
+// Failure describes an index that could not be deleted
+type Failure struct {
+	Index string `json:"index"`
+	Error string `json:"error"`
+}
+
+// Event is a notification about a run. It is the data passed to templates.
+type Event struct {
+	Type         string    `json:"type"`
+	Policy       string    `json:"policy"`
+	Pattern      string    `json:"pattern"`
+	PlanID       string    `json:"plan_id,omitempty"`
+	DryRun       bool      `json:"dry_run"`
+	Planned      int       `json:"planned"`
+	Deleted      int       `json:"deleted"`
+	Failed       int       `json:"failed"`
+	DeletedBytes int64     `json:"deleted_bytes"`
+	Indexes      []string  `json:"indexes,omitempty"`
+	Failures     []Failure `json:"failures,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Time         time.Time `json:"time"`
+}
+
+// Summary returns a one-line description of the event
+func (e Event) Summary() string {
+	switch e.Type {
+	case config.NotifyOnFailure:
+		if e.Error != "" {
+			return "run failed: " + e.Error
+		}
+		return fmt.Sprintf("%d of %d deletions failed", e.Failed, e.Planned)
+	case config.NotifyOnDelete:
+		return fmt.Sprintf("deleted %d indexes (%s)", e.Deleted, utils.FormatBytes(e.DeletedBytes))
+	case config.NotifyOnPlan:
+		if e.DryRun {
+			return fmt.Sprintf("plan selects %d indexes (dry run)", e.Planned)
+		}
+		return fmt.Sprintf("plan selects %d indexes", e.Planned)
+	default:
+		return "nothing to delete"
+	}
+}
+
+// Events classifies the outcome of a run into the events it raises. report
+// is nil for dry runs and for runs that failed before deleting; err is set
+// when the run failed.
+func Events(cfg *config.Config, plan *elasticsearch.Plan, report *elasticsearch.DeletionReport, err error) []Event {
+	base := Event{
+		Policy:  cfg.PolicyName,
+		Pattern: cfg.IndexPattern,
+		DryRun:  !cfg.DeleteIndexes,
+		Time:    time.Now(),
+	}
+	if base.Policy == "" {
+		base.Policy = cfg.IndexPattern
+	}
+	if plan != nil {
+		base.PlanID = plan.ID
+		base.Planned = len(plan.ToDelete)
+		for _, index := range plan.ToDelete {
+			base.Indexes = append(base.Indexes, index.Name)
+		}
+	}
+	if report != nil {
+		base.Deleted = report.Deleted
+		base.Failed = report.Failed
+		base.DeletedBytes = report.DeletedBytes
+		for _, outcome := range report.Outcomes {
+			if outcome.Error != "" {
+				base.Failures = append(base.Failures, Failure{Index: outcome.Index, Error: outcome.Error})
+			}
+		}
+		if report.Aborted && len(report.BlockedBy) > 0 {
+			base.Error = "aborted: " + strings.Join(report.BlockedBy, "; ")
+		}
+	}
+	if err != nil {
+		base.Error = err.Error()
+	}
+
+	var events []Event
+	add := func(eventType string) {
+		event := base
+		event.Type = eventType
+		events = append(events, event)
+	}
+
+	if base.Planned > 0 {
+		add(config.NotifyOnPlan)
+	} else if err == nil {
+		add(config.NotifyOnNoop)
+	}
+	if base.Deleted > 0 {
+		add(config.NotifyOnDelete)
+	}
+	if base.Failed > 0 || base.Error != "" {
+		add(config.NotifyOnFailure)
+	}
+
+	return events
+}
+
+// Notifier sends events to one webhook
+type Notifier struct {
+	cfg    config.NotifierConfig
+	tmpl   *template.Template
+	client *http.Client
+	events map[string]bool
+}
+
+// New creates a notifier from validated settings
+func New(cfg config.NotifierConfig) (*Notifier, error) {
+	n := &Notifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.TimeoutDuration},
+		events: make(map[string]bool),
+	}
+	for _, event := range cfg.Events {
+		n.events[event] = true
+	}
+
+	text := cfg.Template
+	if text == "" && cfg.Preset != config.NotifyPresetGeneric {
+		text = defaultText
+	}
+	if text != "" {
+		tmpl, err := template.New(cfg.Name).Funcs(config.NotifyTemplateFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notifier template: %w", err)
+		}
+		n.tmpl = tmpl
+	}
+
+	return n, nil
+}
+
+// Wants reports whether the notifier subscribes to the event
+func (n *Notifier) Wants(event Event) bool {
+	if !n.events[event.Type] {
+		return false
+	}
+	if event.Type == config.NotifyOnDelete && event.Deleted <= n.cfg.DeleteThreshold {
+		return false
+	}
+	return true
+}
+
+// render builds the request body for the configured preset
+func (n *Notifier) render(event Event) ([]byte, error) {
+	var text string
+	if n.tmpl != nil {
+		var buf bytes.Buffer
+		if err := n.tmpl.Execute(&buf, event); err != nil {
+			return nil, fmt.Errorf("failed to render notification: %w", err)
+		}
+		text = buf.String()
+	}
+
+	switch n.cfg.Preset {
+	case config.NotifyPresetSlack:
+		return json.Marshal(map[string]string{"text": text})
+	case config.NotifyPresetTeams:
+		color := "2EB886"
+		if event.Type == config.NotifyOnFailure {
+			color = "D13438"
+		}
+		return json.Marshal(map[string]string{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    event.Summary(),
+			"themeColor": color,
+			"title":      "log-trimmer: " + event.Policy,
+			"text":       text,
+		})
+	default:
+		if n.tmpl == nil {
+			return json.Marshal(event)
+		}
+		return []byte(text), nil
+	}
+}
+
+// Send renders the event and posts it, retrying on network errors and
+// server-side failures
+func (n *Notifier) Send(ctx context.Context, event Event) error {
+	body, err := n.render(event)
+	if err != nil {
+		return err
+	}
+
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.cfg.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// post sends one request and reports whether a failure is worth retrying
+func (n *Notifier) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", n.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range n.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+}
+
+// Set is the group of notifiers configured for a policy. All methods are
+// safe to call on a nil Set, which sends nothing.
+type Set struct {
+	notifiers []*Notifier
+	logger    *logger.Logger
+}
+
+// NewSet creates the notifiers configured in cfg
+func NewSet(cfg *config.Config, log *logger.Logger) (*Set, error) {
+	s := &Set{logger: log}
+	for _, notifierCfg := range cfg.Notifiers {
+		n, err := New(notifierCfg)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: %w", notifierCfg.Name, err)
+		}
+		s.notifiers = append(s.notifiers, n)
+	}
+	return s, nil
+}
+
+// Notify sends the events raised by a run to every notifier subscribed to
+// them. Delivery failures are logged and never fail the run.
+func (s *Set) Notify(ctx context.Context, cfg *config.Config, plan *elasticsearch.Plan, report *elasticsearch.DeletionReport, runErr error) {
+	if s == nil || len(s.notifiers) == 0 {
+		return
+	}
+
+	for _, event := range Events(cfg, plan, report, runErr) {
+		for _, n := range s.notifiers {
+			if !n.Wants(event) {
+				continue
+			}
+			if err := n.Send(ctx, event); err != nil {
+				s.logger.Warn("notify", "send", "Failed to deliver notification", map[string]interface{}{
+					"notifier": n.cfg.Name,
+					"event":    event.Type,
+					"error":    err.Error(),
+				})
+				continue
+			}
+			s.logger.Debug("notify", "send", "Notification delivered", map[string]interface{}{
+				"notifier": n.cfg.Name,
+				"event":    event.Type,
+			})
+		}
+	}
+}
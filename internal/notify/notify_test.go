@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/elasticsearch"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+func validNotifier(t *testing.T, n config.NotifierConfig) config.NotifierConfig {
+	t.Helper()
+	cfg := &config.Config{ESHost: "http://localhost:9200", MaxAge: "7d", Notifiers: []config.NotifierConfig{n}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected notifier config to be valid, got %v", err)
+	}
+	return cfg.Notifiers[0]
+}
+
+func TestEvents(t *testing.T) {
+	cfg := &config.Config{IndexPattern: "logs-*", PolicyName: "app"}
+	plan := &elasticsearch.Plan{ID: "plan-1", ToDelete: []elasticsearch.IndexInfo{{Name: "logs-1"}, {Name: "logs-2"}}}
+	report := &elasticsearch.DeletionReport{
+		Deleted:  1,
+		Failed:   1,
+		Outcomes: []elasticsearch.DeletionOutcome{{Index: "logs-1"}, {Index: "logs-2", Error: "boom"}},
+	}
+
+	types := func(events []Event) []string {
+		var out []string
+		for _, event := range events {
+			out = append(out, event.Type)
+		}
+		return out
+	}
+
+	got := types(Events(cfg, plan, report, nil))
+	want := []string{config.NotifyOnPlan, config.NotifyOnDelete, config.NotifyOnFailure}
+	if len(got) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected events %v, got %v", want, got)
+		}
+	}
+
+	noop := Events(cfg, &elasticsearch.Plan{}, nil, nil)
+	if len(noop) != 1 || noop[0].Type != config.NotifyOnNoop {
+		t.Errorf("Expected a single noop event, got %v", types(noop))
+	}
+
+	failed := Events(cfg, nil, nil, errors.New("cluster unreachable"))
+	if len(failed) != 1 || failed[0].Type != config.NotifyOnFailure || failed[0].Error == "" {
+		t.Errorf("Expected a failure event for a failed run, got %+v", failed)
+	}
+	if !failed[0].DryRun {
+		t.Error("Expected a failed dry run to be reported as a dry run")
+	}
+
+	// A real run that fails before deleting has no report but is no dry run
+	live := *cfg
+	live.DeleteIndexes = true
+	if failed := Events(&live, plan, nil, errors.New("health gate")); len(failed) == 0 || failed[0].DryRun {
+		t.Errorf("Expected a failed real run not to be reported as a dry run, got %+v", failed)
+	}
+}
+
+func TestSlackPresetAndThreshold(t *testing.T) {
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer server.Close()
+
+	n, err := New(validNotifier(t, config.NotifierConfig{
+		URL:             server.URL,
+		Preset:          config.NotifyPresetSlack,
+		DeleteThreshold: 5,
+	}))
+	if err != nil {
+		t.Fatalf("Expected notifier to be created, got %v", err)
+	}
+
+	if n.Wants(Event{Type: config.NotifyOnDelete, Deleted: 5}) {
+		t.Error("Expected on_delete at the threshold to be filtered out")
+	}
+	if n.Wants(Event{Type: config.NotifyOnNoop}) {
+		t.Error("Expected on_noop to be filtered out by default")
+	}
+
+	event := Event{Type: config.NotifyOnDelete, Policy: "app", Deleted: 6, DeletedBytes: 1024}
+	if !n.Wants(event) {
+		t.Fatal("Expected on_delete above the threshold to be sent")
+	}
+	if err := n.Send(context.Background(), event); err != nil {
+		t.Fatalf("Expected send to succeed, got %v", err)
+	}
+	if payload["text"] != "log-trimmer app: deleted 6 indexes (1.0 KB)" {
+		t.Errorf("Unexpected Slack text: %q", payload["text"])
+	}
+}
+
+func TestGenericTemplateAndRetries(t *testing.T) {
+	delay := retryDelay
+	retryDelay = time.Millisecond
+	t.Cleanup(func() { retryDelay = delay })
+	var attempts int32
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	n, err := New(validNotifier(t, config.NotifierConfig{
+		URL:      server.URL,
+		Template: `{"policy":{{json .Policy}},"failed":{{.Failed}}}`,
+		Events:   []string{config.NotifyOnFailure},
+		Retries:  2,
+	}))
+	if err != nil {
+		t.Fatalf("Expected notifier to be created, got %v", err)
+	}
+
+	if err := n.Send(context.Background(), Event{Type: config.NotifyOnFailure, Policy: "app", Failed: 2}); err != nil {
+		t.Fatalf("Expected send to succeed after retries, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+	if body != `{"policy":"app","failed":2}` {
+		t.Errorf("Unexpected rendered body: %s", body)
+	}
+}
+
+func TestSetNotifyIgnoresDeliveryFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ESHost: "http://localhost:9200", MaxAge: "7d", Notifiers: []config.NotifierConfig{{URL: server.URL, Events: []string{config.NotifyOnNoop}}}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected config to be valid, got %v", err)
+	}
+	log, _ := logger.New(logger.DefaultConfig())
+	set, err := NewSet(cfg, log)
+	if err != nil {
+		t.Fatalf("Expected notifiers to be created, got %v", err)
+	}
+
+	set.Notify(context.Background(), cfg, &elasticsearch.Plan{}, nil, nil)
+
+	var nilSet *Set
+	nilSet.Notify(context.Background(), cfg, nil, nil, nil)
+}
@@ -0,0 +1,301 @@
+// Package policytest checks what a retention policy selects against index
+// fixtures. Each test case seeds an in-memory cluster, runs the same index
+// retrieval and analysis as a real run, and compares the deletions with the
+// expected keep and delete lists.
+package policytest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/elasticsearch"
+	"github.com/company/log-trimmer/internal/esfake"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+// ExitCodeFailed is the process exit code when any test case does not
+// match its expectations
+const ExitCodeFailed = 4
+
+// IndexFixture describes an index in a test case. Sizes accept the same
+// format as max_size; Created takes a date or RFC 3339 timestamp, and Age
+// places the creation date relative to the case's as-of time instead.
+type IndexFixture struct {
+	Name        string                 `yaml:"name"`
+	Size        string                 `yaml:"size"`         // Total store size, defaults to the primary size times the shard copies
+	PrimarySize string                 `yaml:"primary_size"` // Defaults to the total size divided by the shard copies
+	Created     string                 `yaml:"created"`
+	Age         string                 `yaml:"age"`
+	Health      string                 `yaml:"health"`
+	Status      string                 `yaml:"status"`
+	Docs        int64                  `yaml:"docs"`
+	Primaries   int                    `yaml:"primaries"`
+	Replicas    int                    `yaml:"replicas"`
+	Aliases     []string               `yaml:"aliases"`
+	Settings    map[string]interface{} `yaml:"settings"` // e.g. lifecycle.name
+}
+
+// Expectation lists the indexes a policy must delete and must keep.
+// Indexes in neither list are not checked.
+type Expectation struct {
+	Delete []string `yaml:"delete"`
+	Keep   []string `yaml:"keep"`
+}
+
+// Case is one policy test loaded from a YAML file
+type Case struct {
+	Name    string         `yaml:"name"`   // Defaults to the file name
+	Policy  string         `yaml:"policy"` // Policy to test; may be omitted when only one is configured
+	AsOf    string         `yaml:"as_of"`  // Time the policy is evaluated at, defaults to now
+	Indexes []IndexFixture `yaml:"indexes"`
+	Expect  Expectation    `yaml:"expect"`
+}
+
+// Load reads a test case from a YAML file
+func Load(file string) (*Case, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy test: %w", err)
+	}
+
+	var c Case
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse policy test %s: %w", file, err)
+	}
+	if c.Name == "" {
+		c.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if len(c.Indexes) == 0 {
+		return nil, fmt.Errorf("policy test %s: no indexes", file)
+	}
+	return &c, nil
+}
+
+// Mismatch is an index whose decision differs from the expectation
+type Mismatch struct {
+	Index    string `json:"index"`
+	Expected string `json:"expected"` // "delete" or "keep"
+	Actual   string `json:"actual"`
+	Reason   string `json:"reason"` // Rules that selected the index, or why it was kept
+}
+
+// Result is the outcome of one test case
+type Result struct {
+	Case       string     `json:"case"`
+	Policy     string     `json:"policy"`
+	Deleted    []string   `json:"deleted"`
+	Mismatches []Mismatch `json:"mismatches,omitempty"`
+}
+
+// Passed reports whether every expectation was met
+func (r Result) Passed() bool {
+	return len(r.Mismatches) == 0
+}
+
+// fixture converts the case's indexes into a fake cluster fixture
+func (c *Case) fixture(asOf time.Time) (esfake.Fixture, error) {
+	var fixture esfake.Fixture
+	for _, f := range c.Indexes {
+		index := esfake.Index{
+			Name:      f.Name,
+			Health:    f.Health,
+			Status:    f.Status,
+			Docs:      f.Docs,
+			Primaries: f.Primaries,
+			Replicas:  f.Replicas,
+			Aliases:   f.Aliases,
+			Settings:  f.Settings,
+			CreatedAt: asOf,
+		}
+
+		var err error
+		if f.Size != "" {
+			if index.SizeBytes, err = config.ParseSize(f.Size); err != nil {
+				return fixture, fmt.Errorf("index %s: invalid size '%s': %v", f.Name, f.Size, err)
+			}
+		}
+		if f.PrimarySize != "" {
+			if index.PrimaryBytes, err = config.ParseSize(f.PrimarySize); err != nil {
+				return fixture, fmt.Errorf("index %s: invalid primary size '%s': %v", f.Name, f.PrimarySize, err)
+			}
+		} else {
+			index.PrimaryBytes = index.SizeBytes / int64(1+f.Replicas)
+		}
+
+		switch {
+		case f.Created != "":
+			if index.CreatedAt, err = config.ParseTime(f.Created); err != nil {
+				return fixture, fmt.Errorf("index %s: %v", f.Name, err)
+			}
+		case f.Age != "":
+			age, err := config.ParseAge(f.Age)
+			if err != nil {
+				return fixture, fmt.Errorf("index %s: invalid age '%s': %v", f.Name, f.Age, err)
+			}
+			index.CreatedAt = asOf.Add(-age)
+		}
+		fixture.Indexes = append(fixture.Indexes, index)
+	}
+	return fixture, nil
+}
+
+// selectPolicy returns the resolved policy a case tests
+func selectPolicy(cfg *config.Config, name string) (*config.Config, error) {
+	policies, err := cfg.ResolvePolicies()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		if len(policies) != 1 {
+			return nil, fmt.Errorf("%d policies are configured, name the one to test", len(policies))
+		}
+		return policies[0], nil
+	}
+	for _, policy := range policies {
+		if policy.PolicyName == name {
+			return policy, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown policy '%s'", name)
+}
+
+// Run evaluates a test case against a policy from cfg. Dry run settings
+// apply: nothing is deleted, and the disk usage target, which needs live
+// node allocation, is ignored.
+func Run(cfg *config.Config, c *Case, log *logger.Logger) (*Result, error) {
+	policy, err := selectPolicy(cfg, c.Policy)
+	if err != nil {
+		return nil, fmt.Errorf("policy test %s: %w", c.Name, err)
+	}
+
+	asOf := time.Now()
+	if c.AsOf != "" {
+		if asOf, err = config.ParseTime(c.AsOf); err != nil {
+			return nil, fmt.Errorf("policy test %s: invalid as_of: %v", c.Name, err)
+		}
+	}
+
+	fixture, err := c.fixture(asOf)
+	if err != nil {
+		return nil, fmt.Errorf("policy test %s: %w", c.Name, err)
+	}
+	server, err := esfake.Start(fixture)
+	if err != nil {
+		return nil, fmt.Errorf("policy test %s: %w", c.Name, err)
+	}
+	defer server.Close()
+
+	run := *policy
+	run.ESHost = server.URL
+	run.Username, run.Password = "", ""
+	run.DeleteIndexes = false
+	run.TargetDiskPercent = 0
+	client := elasticsearch.NewClient(&run, log)
+	client.Clock = func() time.Time { return asOf }
+
+	indexes, err := client.GetIndexes(run.IndexPattern)
+	if err != nil {
+		return nil, fmt.Errorf("policy test %s: %w", c.Name, err)
+	}
+	toDelete, analysis := client.AnalyzeIndexes(indexes)
+
+	matched := make(map[string]bool)
+	for _, index := range indexes {
+		matched[index.Name] = true
+	}
+	deleted := make(map[string]bool)
+	result := &Result{Case: c.Name, Policy: run.PolicyName}
+	for _, index := range toDelete {
+		deleted[index.Name] = true
+		result.Deleted = append(result.Deleted, index.Name)
+	}
+	sort.Strings(result.Deleted)
+
+	check := func(name, expected string) {
+		actual := "keep"
+		if deleted[name] {
+			actual = "delete"
+		}
+		if actual == expected {
+			return
+		}
+		reason := decisionReason(name, analysis, deleted[name])
+		if !matched[name] {
+			reason = fmt.Sprintf("not matched by index pattern %s", run.IndexPattern)
+		}
+		result.Mismatches = append(result.Mismatches, Mismatch{
+			Index:    name,
+			Expected: expected,
+			Actual:   actual,
+			Reason:   reason,
+		})
+	}
+	for _, name := range c.Expect.Delete {
+		check(name, "delete")
+	}
+	for _, name := range c.Expect.Keep {
+		check(name, "keep")
+	}
+	return result, nil
+}
+
+// decisionReason explains why the analysis deleted or kept an index
+func decisionReason(name string, analysis elasticsearch.AnalysisResult, deleted bool) string {
+	if deleted {
+		return "selected by " + strings.Join(analysis.Reasons[name], "+")
+	}
+	for _, skipped := range analysis.Skipped {
+		if skipped.Name == name {
+			return "skipped: " + skipped.Reason
+		}
+	}
+	for _, reduction := range analysis.Reductions {
+		if reduction.Index == name {
+			return "replicas dropped instead"
+		}
+	}
+	return "no rule selected it"
+}
+
+// RunFiles loads and runs each test case file in order
+func RunFiles(cfg *config.Config, files []string, log *logger.Logger) ([]*Result, error) {
+	var results []*Result
+	for _, file := range files {
+		c, err := Load(file)
+		if err != nil {
+			return results, err
+		}
+		result, err := Run(cfg, c, log)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Print writes a line per case and the details of each mismatch, and
+// returns the number of failed cases
+func Print(results []*Result) int {
+	failed := 0
+	for _, r := range results {
+		if r.Passed() {
+			fmt.Printf("PASS %s (policy %s): %d deleted\n", r.Case, r.Policy, len(r.Deleted))
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL %s (policy %s)\n", r.Case, r.Policy)
+		for _, m := range r.Mismatches {
+			fmt.Printf("  %s: expected %s, got %s (%s)\n", m.Index, m.Expected, m.Actual, m.Reason)
+		}
+	}
+	fmt.Printf("%d passed, %d failed\n", len(results)-failed, failed)
+	return failed
+}
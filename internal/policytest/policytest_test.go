@@ -0,0 +1,125 @@
+package policytest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/company/log-trimmer/internal/config"
+	"github.com/company/log-trimmer/internal/logger"
+)
+
+func testConfig() *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.ESHost = "http://localhost:9200"
+	cfg.Policies = []config.PolicyConfig{
+		{Name: "app", IndexPattern: "logs-app-*", MaxAge: "7d"},
+		{Name: "audit", IndexPattern: "logs-audit-*", MaxAge: "365d"},
+	}
+	return cfg
+}
+
+func TestRunPasses(t *testing.T) {
+	log, _ := logger.New(logger.DefaultConfig())
+	results, err := RunFiles(testConfig(), []string{"testdata/app.yaml"}, log)
+	if err != nil {
+		t.Fatalf("RunFiles failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+
+	result := results[0]
+	if !result.Passed() {
+		t.Errorf("Expected the case to pass, got mismatches %+v", result.Mismatches)
+	}
+	if result.Case != "app retention" || result.Policy != "app" {
+		t.Errorf("Unexpected case or policy: %s, %s", result.Case, result.Policy)
+	}
+	if strings.Join(result.Deleted, ",") != "logs-app-2024.02.01,logs-app-2024.03.01" {
+		t.Errorf("Unexpected deletions: %v", result.Deleted)
+	}
+}
+
+func TestRunReportsMismatches(t *testing.T) {
+	c, err := Load("testdata/app.yaml")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	c.Expect = Expectation{
+		Delete: []string{"logs-app-2024.03.10", "logs-audit-2024.01.01", "logs-app-2024.02.01"},
+		Keep:   []string{"logs-app-2024.03.01"},
+	}
+
+	cfg := testConfig()
+	cfg.ClosedIndexes = config.UnhealthySkip
+
+	log, _ := logger.New(logger.DefaultConfig())
+	result, err := Run(cfg, c, log)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Passed() {
+		t.Fatal("Expected mismatches")
+	}
+
+	reasons := make(map[string]string)
+	for _, m := range result.Mismatches {
+		reasons[m.Index] = m.Reason
+	}
+	want := map[string]string{
+		"logs-app-2024.03.10":   "no rule selected it",
+		"logs-audit-2024.01.01": "not matched by index pattern logs-app-*",
+		"logs-app-2024.03.01":   "selected by age",
+		"logs-app-2024.02.01":   "skipped: closed index, skipped",
+	}
+	for index, reason := range want {
+		if reasons[index] != reason {
+			t.Errorf("%s: expected reason %q, got %q", index, reason, reasons[index])
+		}
+	}
+	if len(result.Mismatches) != len(want) {
+		t.Errorf("Expected %d mismatches, got %+v", len(want), result.Mismatches)
+	}
+}
+
+func TestRunUnknownPolicy(t *testing.T) {
+	c := &Case{Name: "unknown", Policy: "missing", Indexes: []IndexFixture{{Name: "logs-app-1"}}}
+	log, _ := logger.New(logger.DefaultConfig())
+	if _, err := Run(testConfig(), c, log); err == nil || !strings.Contains(err.Error(), "unknown policy 'missing'") {
+		t.Errorf("Expected unknown policy error, got %v", err)
+	}
+
+	c.Policy = ""
+	if _, err := Run(testConfig(), c, log); err == nil || !strings.Contains(err.Error(), "name the one to test") {
+		t.Errorf("Expected ambiguous policy error, got %v", err)
+	}
+}
+
+func TestFixtureSizesAndAges(t *testing.T) {
+	c := &Case{Indexes: []IndexFixture{
+		{Name: "a", Size: "2GB", Replicas: 1, Age: "2d"},
+		{Name: "b", Size: "10GB", PrimarySize: "4GB", Created: "2024-03-01"},
+		{Name: "c", Size: "lots"},
+	}}
+	asOf, _ := config.ParseTime("2024-03-11")
+
+	if _, err := c.fixture(asOf); err == nil || !strings.Contains(err.Error(), "index c: invalid size") {
+		t.Fatalf("Expected invalid size error, got %v", err)
+	}
+
+	c.Indexes = c.Indexes[:2]
+	fixture, err := c.fixture(asOf)
+	if err != nil {
+		t.Fatalf("fixture failed: %v", err)
+	}
+	a, b := fixture.Indexes[0], fixture.Indexes[1]
+	if a.PrimaryBytes != a.SizeBytes/2 {
+		t.Errorf("Expected primary size to default to half of %d, got %d", a.SizeBytes, a.PrimaryBytes)
+	}
+	if got := asOf.Sub(a.CreatedAt).Hours(); got != 48 {
+		t.Errorf("Expected index a to be 48h old, got %vh", got)
+	}
+	if b.PrimaryBytes >= b.SizeBytes || b.CreatedAt.Day() != 1 {
+		t.Errorf("Unexpected index b: %+v", b)
+	}
+}
@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket refilled continuously at a fixed rate. Taking
+// more tokens than are available puts the bucket into debt, and the caller
+// waits until the debt is repaid, so a single large request is throttled in
+// proportion to its size instead of being refused.
+type Bucket struct {
+	capacity float64
+	rate     float64 // Tokens per second
+	tokens   float64
+	updated  time.Time
+}
+
+// NewBucket returns a full bucket holding perMinute tokens, refilled at
+// perMinute tokens per minute
+func NewBucket(perMinute float64, now time.Time) *Bucket {
+	return &Bucket{
+		capacity: perMinute,
+		rate:     perMinute / 60,
+		tokens:   perMinute,
+		updated:  now,
+	}
+}
+
+// Reserve takes n tokens and returns how long the caller must wait before
+// using them
+func (b *Bucket) Reserve(n float64, now time.Time) time.Duration {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+	}
+	b.updated = now
+
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Limiter throttles deletions by index count, bytes and a minimum pause
+// between consecutive deletions. All methods are safe to call on a nil
+// Limiter, which never waits.
+type Limiter struct {
+	mu      sync.Mutex
+	indexes *Bucket
+	bytes   *Bucket
+	pause   time.Duration
+	last    time.Time // When the previous deletion finished
+
+	now func() time.Time
+}
+
+// New returns a limiter for the given limits, or nil when none are set
+func New(indexesPerMinute int, bytesPerMinute int64, pause time.Duration) *Limiter {
+	if indexesPerMinute <= 0 && bytesPerMinute <= 0 && pause <= 0 {
+		return nil
+	}
+
+	l := &Limiter{pause: pause, now: time.Now}
+	now := l.now()
+	if indexesPerMinute > 0 {
+		l.indexes = NewBucket(float64(indexesPerMinute), now)
+	}
+	if bytesPerMinute > 0 {
+		l.bytes = NewBucket(float64(bytesPerMinute), now)
+	}
+	return l
+}
+
+// Wait blocks until a deletion of the given size is allowed and returns the
+// delay that was applied. It returns early with the context's error if ctx
+// is cancelled.
+func (l *Limiter) Wait(ctx context.Context, bytes int64) (time.Duration, error) {
+	if l == nil {
+		return 0, ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	var delay time.Duration
+	if l.indexes != nil {
+		delay = maxDuration(delay, l.indexes.Reserve(1, now))
+	}
+	if l.bytes != nil {
+		delay = maxDuration(delay, l.bytes.Reserve(float64(bytes), now))
+	}
+	if l.pause > 0 && !l.last.IsZero() {
+		delay = maxDuration(delay, l.last.Add(l.pause).Sub(now))
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return 0, ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-timer.C:
+		return delay, nil
+	}
+}
+
+// Done records that a deletion finished, starting the minimum pause
+func (l *Limiter) Done() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.last = l.now()
+	l.mu.Unlock()
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBucketReserve(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bucket := NewBucket(60, start) // One token per second
+
+	if wait := bucket.Reserve(60, start); wait != 0 {
+		t.Errorf("Expected a full bucket to allow a burst, got wait %s", wait)
+	}
+	if wait := bucket.Reserve(1, start); wait != time.Second {
+		t.Errorf("Expected to wait 1s for the next token, got %s", wait)
+	}
+
+	// Oversized requests go into debt and wait in proportion to their size
+	later := start.Add(time.Minute)
+	if wait := bucket.Reserve(120, later); wait != 61*time.Second {
+		t.Errorf("Expected to wait 61s, got %s", wait)
+	}
+}
+
+func TestLimiterPauseBetweenDeletions(t *testing.T) {
+	limiter := New(0, 0, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	if delay, err := limiter.Wait(context.Background(), 0); delay != 0 || err != nil {
+		t.Fatalf("Expected the first deletion to run immediately, got %s / %v", delay, err)
+	}
+	limiter.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.Wait(ctx, 0); err != context.Canceled {
+		t.Errorf("Expected cancelled wait to return context.Canceled, got %v", err)
+	}
+}
+
+func TestLimiterWaitsForBytes(t *testing.T) {
+	limiter := New(0, 6000, 0) // 100 bytes per second
+
+	if delay, _ := limiter.Wait(context.Background(), 6000); delay != 0 {
+		t.Fatalf("Expected the first minute's budget to be available, got %s", delay)
+	}
+	delay, err := limiter.Wait(context.Background(), 5)
+	if err != nil || delay < 40*time.Millisecond || delay > 60*time.Millisecond {
+		t.Errorf("Expected about 50ms delay, got %s / %v", delay, err)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	limiter := New(0, 0, 0)
+	if limiter != nil {
+		t.Fatal("Expected no limiter without limits")
+	}
+	if delay, err := limiter.Wait(context.Background(), 1<<40); delay != 0 || err != nil {
+		t.Errorf("Expected nil limiter to never wait, got %s / %v", delay, err)
+	}
+	limiter.Done()
+}
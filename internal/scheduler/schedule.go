@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the next activation time after a given time
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// Parse parses a schedule expression. Supported forms are standard five
+// field cron expressions ("0 3 * * *"), "@every <duration>" and the
+// shorthands @hourly, @daily, @weekly and @monthly.
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("empty schedule")
+	}
+
+	switch expr {
+	case "@hourly":
+		expr = "0 * * * *"
+	case "@daily", "@midnight":
+		expr = "0 0 * * *"
+	case "@weekly":
+		expr = "0 0 * * 0"
+	case "@monthly":
+		expr = "0 0 1 * *"
+	}
+
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval in schedule '%s': %v", expr, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("interval in schedule '%s' must be at least 1s", expr)
+		}
+		return intervalSchedule(d), nil
+	}
+
+	return parseCron(expr)
+}
+
+// intervalSchedule activates at a fixed interval
+type intervalSchedule time.Duration
+
+func (s intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(s))
+}
+
+// cronSchedule activates on the minutes matching all five cron fields
+type cronSchedule struct {
+	minute, hour, dom, month, dow []bool
+	domStar, dowStar              bool
+}
+
+// cronField describes the bounds of a cron field
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// parseCron parses a five field cron expression
+func parseCron(expr string) (Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression '%s': expected 5 fields, got %d", expr, len(fields))
+	}
+
+	var sets [5][]bool
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression '%s': %v", expr, err)
+		}
+		sets[i] = set
+	}
+
+	schedule := &cronSchedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+
+	// Day and month combinations such as "0 0 30 2 *" parse but never occur
+	if schedule.Next(neverFiresCheck).IsZero() {
+		return nil, fmt.Errorf("invalid cron expression '%s': it never fires", expr)
+	}
+	return schedule, nil
+}
+
+// neverFiresCheck is the start of a leap year, so a search from it covers
+// every day of the year within the five year limit of Next
+var neverFiresCheck = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// parseCronField parses a comma separated list of values, ranges and steps
+func parseCronField(field string, bounds cronField) ([]bool, error) {
+	max := bounds.max
+	if bounds.name == "day of week" {
+		max = 7
+	}
+	set := make([]bool, max+1)
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx != -1 {
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step in %s field '%s'", bounds.name, part)
+			}
+			step = n
+			part = part[:idx]
+		}
+
+		lo, hi := bounds.min, bounds.max
+		if part != "*" {
+			rangeParts := strings.SplitN(part, "-", 2)
+			n, err := strconv.Atoi(rangeParts[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s field '%s'", bounds.name, part)
+			}
+			lo, hi = n, n
+			if len(rangeParts) == 2 {
+				if hi, err = strconv.Atoi(rangeParts[1]); err != nil {
+					return nil, fmt.Errorf("invalid %s field '%s'", bounds.name, part)
+				}
+			} else if step > 1 {
+				hi = bounds.max
+			}
+		}
+
+		if lo < bounds.min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%s field '%s' out of range %d-%d", bounds.name, part, bounds.min, bounds.max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+
+	// Sunday may be written as 7
+	if max > bounds.max && set[max] {
+		set[0] = true
+	}
+
+	return set[:bounds.max+1], nil
+}
+
+// Next returns the first matching minute strictly after the given time, or
+// the zero time if none matches within five years. Steps are taken on the
+// wall clock of after's location, so zones with offsets that are not whole
+// hours still land on the hour.
+func (s *cronSchedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute()+1, 0, 0, loc)
+
+	// Five years covers every valid combination, including Feb 29
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !s.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.hour[t.Hour()] {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if !next.After(t) {
+				// The next wall clock hour repeats this one as clocks go back
+				next = t.Add(time.Hour)
+			}
+			t = next
+			continue
+		}
+		if !s.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches applies the cron rule that when both day fields are
+// restricted, a day matching either of them is enough
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom[t.Day()]
+	dow := s.dow[int(t.Weekday())]
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC) // Monday
+
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"0 3 * * *", time.Date(2024, 1, 16, 3, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC)},
+		{"30 2 1 * *", time.Date(2024, 2, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"@every 90m", base.Add(90 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		schedule, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", tt.expr, err)
+		}
+		if got := schedule.Next(base); !got.Equal(tt.expected) {
+			t.Errorf("For %q, expected %v, got %v", tt.expr, tt.expected, got)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * * 8", "*/0 * * * *", "@every 10ms", "@every soon", "0 0 30 2 *", "0 0 31 4,6 *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Expected error for %q", expr)
+		}
+	}
+}
+
+func TestParseCronHalfHourZone(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("Time zone data not available: %v", err)
+	}
+
+	schedule, err := Parse("0 3 * * *")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	base := time.Date(2024, 1, 15, 10, 30, 0, 0, kolkata)
+	expected := time.Date(2024, 1, 16, 3, 0, 0, 0, kolkata)
+	if got := schedule.Next(base); !got.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
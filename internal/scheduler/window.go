@@ -0,0 +1,225 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdays maps day names to cron day-of-week numbers
+var weekdays = map[string]string{
+	"sun": "0", "mon": "1", "tue": "2", "wed": "3", "thu": "4", "fri": "5", "sat": "6",
+}
+
+// window is a recurring period that opens on a schedule and stays open for
+// a fixed duration
+type window struct {
+	expr     string
+	opens    Schedule
+	duration time.Duration
+}
+
+// Windows is a set of recurring maintenance windows in a fixed time zone
+type Windows struct {
+	windows  []window
+	location *time.Location
+}
+
+// ParseWindows parses maintenance window expressions in the named time
+// zone. Each expression is either a weekday and time range such as
+// "Mon-Fri 22:00-06:00", "Sat,Sun 00:00-24:00" or "daily 01:00-05:00", or
+// a five field cron expression for the opening time followed by how long
+// the window stays open, such as "0 22 * * 1-5 8h".
+func ParseWindows(exprs []string, timezone string) (*Windows, error) {
+	if timezone == "" {
+		return nil, fmt.Errorf("a time zone is required for maintenance windows")
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone '%s': %v", timezone, err)
+	}
+
+	w := &Windows{location: location}
+	for _, expr := range exprs {
+		parsed, err := parseWindow(strings.TrimSpace(expr))
+		if err != nil {
+			return nil, err
+		}
+		w.windows = append(w.windows, parsed)
+	}
+	return w, nil
+}
+
+// parseWindow parses a single window expression
+func parseWindow(expr string) (window, error) {
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 2:
+		return parseDayWindow(expr, fields[0], fields[1])
+	case 6:
+		opens, err := parseCron(strings.Join(fields[:5], " "))
+		if err != nil {
+			return window{}, fmt.Errorf("invalid window '%s': %v", expr, err)
+		}
+		duration, err := time.ParseDuration(fields[5])
+		if err != nil || duration <= 0 || duration > 7*24*time.Hour {
+			return window{}, fmt.Errorf("invalid window '%s': duration must be between 1m and 168h", expr)
+		}
+		return window{expr: expr, opens: opens, duration: duration}, nil
+	default:
+		return window{}, fmt.Errorf("invalid window '%s': expected 'Mon-Fri 22:00-06:00' or a cron expression followed by a duration", expr)
+	}
+}
+
+// parseDayWindow parses the weekday and time range form
+func parseDayWindow(expr, days, hours string) (window, error) {
+	dow, err := parseWeekdays(days)
+	if err != nil {
+		return window{}, fmt.Errorf("invalid window '%s': %v", expr, err)
+	}
+
+	times := strings.SplitN(hours, "-", 2)
+	if len(times) != 2 {
+		return window{}, fmt.Errorf("invalid window '%s': time range must look like 22:00-06:00", expr)
+	}
+	start, err := parseClock(times[0])
+	if err != nil {
+		return window{}, fmt.Errorf("invalid window '%s': %v", expr, err)
+	}
+	end, err := parseClock(times[1])
+	if err != nil {
+		return window{}, fmt.Errorf("invalid window '%s': %v", expr, err)
+	}
+
+	// A range ending before it starts wraps past midnight
+	duration := end - start
+	if duration <= 0 {
+		duration += 24 * time.Hour
+	}
+
+	hour, minute := int(start.Hours()), int(start.Minutes())%60
+	opens, err := parseCron(fmt.Sprintf("%d %d * * %s", minute, hour, dow))
+	if err != nil {
+		return window{}, fmt.Errorf("invalid window '%s': %v", expr, err)
+	}
+	return window{expr: expr, opens: opens, duration: duration}, nil
+}
+
+// parseWeekdays converts "Mon-Fri", "Sat,Sun" or "daily" into a cron
+// day-of-week field
+func parseWeekdays(days string) (string, error) {
+	lower := strings.ToLower(days)
+	if lower == "daily" || lower == "*" {
+		return "*", nil
+	}
+
+	var parts []string
+	for _, part := range strings.Split(lower, ",") {
+		var converted []string
+		for _, day := range strings.SplitN(part, "-", 2) {
+			n, ok := weekdays[day]
+			if !ok {
+				return "", fmt.Errorf("unknown weekday '%s'", day)
+			}
+			converted = append(converted, n)
+		}
+		// Ranges that wrap past Saturday, such as Fri-Mon, are split in two
+		if len(converted) == 2 && converted[0] > converted[1] {
+			parts = append(parts, converted[0]+"-6", "0-"+converted[1])
+			continue
+		}
+		parts = append(parts, strings.Join(converted, "-"))
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// parseClock parses HH:MM as an offset from midnight; 24:00 is allowed as
+// the end of a range
+func parseClock(value string) (time.Duration, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hour, &minute); err != nil {
+		return 0, fmt.Errorf("invalid time '%s'", value)
+	}
+	if hour < 0 || hour > 24 || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time '%s'", value)
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+// openAt returns when the occurrence of w containing t opened and closes,
+// and whether t falls inside one
+func (w window) openAt(t time.Time) (time.Time, time.Time, bool) {
+	// Walk the openings that could still be open at t
+	for opens := w.opens.Next(t.Add(-w.duration - time.Minute)); !opens.IsZero() && !opens.After(t); opens = w.opens.Next(opens) {
+		if closes := opens.Add(w.duration); t.Before(closes) {
+			return opens, closes, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// IsOpen reports whether any window is open at t. A nil Windows is always
+// open.
+func (w *Windows) IsOpen(t time.Time) bool {
+	_, open := w.ClosesAt(t)
+	return open
+}
+
+// maxLookahead bounds how far ClosesAt follows chained windows, so windows
+// that are always open still report a closing time
+const maxLookahead = 7 * 24 * time.Hour
+
+// ClosesAt returns when the open windows covering t close, following
+// windows that overlap or touch. It reports false when no window is open.
+func (w *Windows) ClosesAt(t time.Time) (time.Time, bool) {
+	if w == nil {
+		return time.Time{}, true
+	}
+
+	t = t.In(w.location)
+	var closes time.Time
+	open := false
+	for extended := true; extended && (!open || closes.Sub(t) < maxLookahead); {
+		extended = false
+		for _, win := range w.windows {
+			at := t
+			if open {
+				at = closes
+			}
+			if _, end, ok := win.openAt(at); ok && end.After(closes) {
+				closes, open, extended = end, true, true
+			}
+		}
+	}
+	return closes, open
+}
+
+// NextOpen returns t when a window is open at t, or the time the next
+// window opens. It returns the zero time when no window opens within the
+// five years a schedule looks ahead.
+func (w *Windows) NextOpen(t time.Time) time.Time {
+	if w.IsOpen(t) {
+		return t
+	}
+
+	t = t.In(w.location)
+	var next time.Time
+	for _, win := range w.windows {
+		if opens := win.opens.Next(t); !opens.IsZero() && (next.IsZero() || opens.Before(next)) {
+			next = opens
+		}
+	}
+	return next
+}
+
+// String lists the window expressions and time zone
+func (w *Windows) String() string {
+	if w == nil {
+		return "always"
+	}
+	var exprs []string
+	for _, win := range w.windows {
+		exprs = append(exprs, win.expr)
+	}
+	return fmt.Sprintf("%s (%s)", strings.Join(exprs, "; "), w.location)
+}
@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowsIsOpen(t *testing.T) {
+	windows, err := ParseWindows([]string{"Mon-Fri 22:00-06:00", "Sat,Sun 00:00-24:00"}, "Europe/Berlin")
+	if err != nil {
+		t.Fatalf("Expected windows to parse, got %v", err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	tests := []struct {
+		name string
+		at   time.Time
+		open bool
+	}{
+		{"weekday evening", time.Date(2024, 3, 5, 23, 0, 0, 0, berlin), true},
+		{"weekday early morning", time.Date(2024, 3, 6, 5, 59, 0, 0, berlin), true},
+		{"weekday working hours", time.Date(2024, 3, 6, 12, 0, 0, 0, berlin), false},
+		{"window end is exclusive", time.Date(2024, 3, 6, 6, 0, 0, 0, berlin), false},
+		{"saturday afternoon", time.Date(2024, 3, 9, 15, 0, 0, 0, berlin), true},
+		{"same instant in UTC", time.Date(2024, 3, 5, 22, 0, 0, 0, time.UTC), true},
+		{"before the window in UTC", time.Date(2024, 3, 5, 20, 30, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := windows.IsOpen(tt.at); got != tt.open {
+				t.Errorf("IsOpen(%s) = %v, want %v", tt.at, got, tt.open)
+			}
+		})
+	}
+}
+
+func TestWindowsClosesAtAndNextOpen(t *testing.T) {
+	windows, err := ParseWindows([]string{"0 1 * * * 2h", "Fri-Mon 02:30-04:00"}, "UTC")
+	if err != nil {
+		t.Fatalf("Expected windows to parse, got %v", err)
+	}
+
+	// Friday: the cron window and the weekday window overlap
+	friday := time.Date(2024, 3, 8, 1, 30, 0, 0, time.UTC)
+	closes, open := windows.ClosesAt(friday)
+	if !open || !closes.Equal(time.Date(2024, 3, 8, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected overlapping windows to close at 04:00, got %s (open %v)", closes, open)
+	}
+
+	// Wednesday: only the cron window applies
+	wednesday := time.Date(2024, 3, 6, 3, 30, 0, 0, time.UTC)
+	if windows.IsOpen(wednesday) {
+		t.Error("Expected no window open on Wednesday at 03:30")
+	}
+	if next := windows.NextOpen(wednesday); !next.Equal(time.Date(2024, 3, 7, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected next window on Thursday at 01:00, got %s", next)
+	}
+
+	var always *Windows
+	if !always.IsOpen(wednesday) || !always.NextOpen(wednesday).Equal(wednesday) {
+		t.Error("Expected nil windows to always be open")
+	}
+}
+
+func TestAlwaysOpenWindowTerminates(t *testing.T) {
+	windows, err := ParseWindows([]string{"daily 00:00-24:00"}, "UTC")
+	if err != nil {
+		t.Fatalf("Expected window to parse, got %v", err)
+	}
+	if _, open := windows.ClosesAt(time.Now()); !open {
+		t.Error("Expected an all-day window to be open")
+	}
+}
+
+func TestParseWindowsErrors(t *testing.T) {
+	invalid := [][]string{
+		{"Mon-Fri"},
+		{"Funday 01:00-02:00"},
+		{"Mon 25:00-02:00"},
+		{"Mon 01:00"},
+		{"0 1 * * * forever"},
+		{"0 1 * * 9 2h"},
+	}
+	for _, exprs := range invalid {
+		if _, err := ParseWindows(exprs, "UTC"); err == nil {
+			t.Errorf("Expected error for %v", exprs)
+		}
+	}
+
+	if _, err := ParseWindows([]string{"daily 01:00-02:00"}, ""); err == nil {
+		t.Error("Expected error without a time zone")
+	}
+	if _, err := ParseWindows([]string{"daily 01:00-02:00"}, "Mars/Olympus"); err == nil {
+		t.Error("Expected error for unknown time zone")
+	}
+}
+
+func TestWindowsHalfHourZone(t *testing.T) {
+	windows, err := ParseWindows([]string{"Mon-Fri 22:00-06:00"}, "Asia/Kolkata")
+	if err != nil {
+		t.Skipf("Time zone data not available: %v", err)
+	}
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+
+	if !windows.IsOpen(time.Date(2024, 3, 5, 23, 0, 0, 0, kolkata)) {
+		t.Error("Expected the window to be open on a weekday evening")
+	}
+	noon := time.Date(2024, 3, 6, 12, 0, 0, 0, kolkata)
+	if windows.IsOpen(noon) {
+		t.Error("Expected the window to be closed at noon")
+	}
+	expected := time.Date(2024, 3, 6, 22, 0, 0, 0, kolkata)
+	if got := windows.NextOpen(noon); !got.Equal(expected) {
+		t.Errorf("Expected next opening at %v, got %v", expected, got)
+	}
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
 	"time"
 
@@ -62,35 +64,45 @@ func PrintBanner(version string) {
 
 // PrintTableHeader prints a formatted table header
 func PrintTableHeader(headers []string, widths []int) {
-	for i, header := range headers {
-		fmt.Printf("%-*s ", widths[i], header)
-	}
-	fmt.Println()
+	FprintTableHeader(os.Stdout, headers, widths)
+}
 
-	totalWidth := 0
-	for _, width := range widths {
-		totalWidth += width + 1 // +1 for space
+// FprintTableHeader writes a formatted table header to w
+func FprintTableHeader(w io.Writer, headers []string, widths []int) {
+	for i, header := range headers {
+		fmt.Fprintf(w, "%-*s ", widths[i], header)
 	}
-	fmt.Println(strings.Repeat("-", totalWidth-1))
+	fmt.Fprintln(w)
+	FprintTableFooter(w, widths)
 }
 
 // PrintTableRow prints a formatted table row
 func PrintTableRow(values []string, widths []int) {
+	FprintTableRow(os.Stdout, values, widths)
+}
+
+// FprintTableRow writes a formatted table row to w
+func FprintTableRow(w io.Writer, values []string, widths []int) {
 	for i, value := range values {
 		if i < len(widths) {
-			fmt.Printf("%-*s ", widths[i], value)
+			fmt.Fprintf(w, "%-*s ", widths[i], value)
 		} else {
-			fmt.Printf("%s ", value)
+			fmt.Fprintf(w, "%s ", value)
 		}
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 }
 
 // PrintTableFooter prints a table footer separator
 func PrintTableFooter(widths []int) {
+	FprintTableFooter(os.Stdout, widths)
+}
+
+// FprintTableFooter writes a table separator to w
+func FprintTableFooter(w io.Writer, widths []int) {
 	totalWidth := 0
 	for _, width := range widths {
 		totalWidth += width + 1 // +1 for space
 	}
-	fmt.Println(strings.Repeat("-", totalWidth-1))
+	fmt.Fprintln(w, strings.Repeat("-", totalWidth-1))
 }
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"testing"
 	"time"
 )
@@ -74,3 +75,16 @@ func TestPrintFunctions(t *testing.T) {
 	PrintTableRow([]string{"test", "100MB"}, []int{20, 10})
 	PrintTableFooter([]int{20, 10})
 }
+
+func TestFprintTable(t *testing.T) {
+	var out bytes.Buffer
+	widths := []int{4, 6}
+	FprintTableHeader(&out, []string{"#", "NAME"}, widths)
+	FprintTableRow(&out, []string{"1", "logs"}, widths)
+	FprintTableFooter(&out, widths)
+
+	expected := "#    NAME   \n-----------\n1    logs   \n-----------\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected table:\n%q\nwant\n%q", out.String(), expected)
+	}
+}